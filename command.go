@@ -0,0 +1,215 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/APRSCN/aprsgo/internal/capture"
+	"github.com/APRSCN/aprsgo/internal/infra/config"
+	"github.com/APRSCN/aprsgo/internal/infra/logger"
+	"github.com/APRSCN/aprsgo/internal/network/uplink"
+	"go.uber.org/zap"
+)
+
+// usage is printed for -h and for unknown subcommands.
+const usage = `Usage: aprsgo [-config FILE] [command] [flags]
+
+Options:
+  -config FILE   config file (default config.yaml); *.yaml fragments in the
+                 conf.d directory next to it are merged in, and APRSGO_*
+                 environment variables override single fields
+
+Commands:
+  (none)    run the server
+  record    run the server and capture the distribution stream to rotating files
+  replay    run the server offline, feeding captured streams back into it
+
+  check-config        validate the config without starting the server
+  passcode CALLSIGN   print the APRS-IS passcode of a callsign
+  status              print the status of a running server
+  reload              make a running server reload its configuration
+
+Run 'aprsgo <command> -h' for the flags of a command.
+`
+
+// command is the parsed command line.
+type command struct {
+	// config is the config file.
+	config string
+	// name is the subcommand; empty runs the plain server.
+	name string
+
+	// record
+	out      string
+	maxSize  int
+	maxFiles int
+
+	// replay
+	speed float64
+	exit  bool
+	files []string
+
+	// passcode
+	call string
+	// status
+	url string
+	// reload
+	pid int
+}
+
+// tool reports whether the command runs once and exits instead of serving.
+func (cmd command) tool() bool {
+	switch cmd.name {
+	case "check-config", "passcode", "status", "reload":
+		return true
+	}
+	return false
+}
+
+// parseCommand parses the command line (without the program name).
+func parseCommand(args []string) (command, error) {
+	cmd := command{config: "config.yaml"}
+	global := flag.NewFlagSet("aprsgo", flag.ContinueOnError)
+	global.SetOutput(io.Discard)
+	global.StringVar(&cmd.config, "config", cmd.config, "config file")
+	if err := global.Parse(args); err != nil {
+		return command{}, err
+	}
+	args = global.Args()
+	if len(args) == 0 {
+		return cmd, nil
+	}
+	if args[0] == "help" {
+		return command{}, flag.ErrHelp
+	}
+
+	cmd.name = args[0]
+	fs := flag.NewFlagSet("aprsgo "+cmd.name, flag.ContinueOnError)
+	switch cmd.name {
+	case "record":
+		fs.StringVar(&cmd.out, "o", "capture/stream.jsonl", "capture file; rotated files are kept next to it")
+		fs.IntVar(&cmd.maxSize, "max-size", 100, "rotate the capture after this many megabytes")
+		fs.IntVar(&cmd.maxFiles, "max-files", 10, "rotated captures to keep (0 keeps all)")
+	case "replay":
+		fs.Float64Var(&cmd.speed, "speed", 1, "replay speed factor; 0 replays as fast as possible")
+		fs.BoolVar(&cmd.exit, "exit", false, "shut down once the replay is finished")
+	case "check-config", "passcode":
+	case "status":
+		fs.StringVar(&cmd.url, "url", "", "status API URL (default: the status port in the config)")
+	case "reload":
+		fs.IntVar(&cmd.pid, "pid", 0, "process id to signal (default: read from pid_file)")
+	default:
+		return command{}, fmt.Errorf("unknown command %q", cmd.name)
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		return command{}, err
+	}
+	cmd.files = fs.Args()
+
+	switch cmd.name {
+	case "passcode":
+		if len(cmd.files) != 1 || cmd.files[0] == "" {
+			return command{}, errors.New("passcode: expected one callsign")
+		}
+		cmd.call = cmd.files[0]
+	case "check-config", "status", "reload":
+		if len(cmd.files) > 0 {
+			return command{}, fmt.Errorf("%s: unexpected arguments %q", cmd.name, cmd.files)
+		}
+	case "record":
+		if len(cmd.files) > 0 {
+			return command{}, fmt.Errorf("record: unexpected arguments %q", cmd.files)
+		}
+		if cmd.out == "" || cmd.maxSize <= 0 || cmd.maxFiles < 0 {
+			return command{}, errors.New("record: -o must be set, -max-size positive and -max-files not negative")
+		}
+	case "replay":
+		if len(cmd.files) == 0 {
+			return command{}, errors.New("replay: no capture files given")
+		}
+		if cmd.speed < 0 {
+			return command{}, errors.New("replay: -speed must not be negative")
+		}
+	}
+	return cmd, nil
+}
+
+// offline strips the uplinks and core peers from the configuration, so a
+// replay neither pulls live traffic in nor pushes captured traffic out, and
+// the state file, so it neither starts from nor overwrites the live state.
+func offline() {
+	c := config.Get()
+	c.Server.StateFile = ""
+	c.Server.Uplinks = nil
+	c.Server.Peer = config.PeerGroupConfig{}
+	c.Server.PeerGroups = nil
+	config.Set(c)
+}
+
+// startRecorder starts capturing uplink.Stream as the record command asks and
+// returns a function that stops the capture.
+func startRecorder(cmd command) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(cmd.out), 0o755); err != nil {
+		return nil, err
+	}
+	w := capture.RotatingFile(cmd.out, cmd.maxSize, cmd.maxFiles)
+	rec := capture.NewRecorder(uplink.Stream, w)
+	logger.L.Info("Recording stream", zap.String("file", cmd.out))
+
+	return func() {
+		err := rec.Close()
+		if cerr := w.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			logger.L.Error("Stream capture failed", zap.Error(err))
+		}
+		logger.L.Info("Stream capture closed", zap.Uint64("records", rec.Records()))
+	}, nil
+}
+
+// startReplay feeds the replay command's captures, in order, into
+// uplink.Stream. The returned channel is closed when the replay is done; stop
+// ends it early.
+func startReplay(cmd command, stop <-chan struct{}) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		total := 0
+		for _, name := range cmd.files {
+			n, err := replayFile(name, cmd.speed, stop)
+			total += n
+			if err != nil {
+				logger.L.Error("Replay failed", zap.String("file", name), zap.Error(err))
+			}
+			select {
+			case <-stop:
+				return
+			default:
+			}
+		}
+		logger.L.Info("Replay finished", zap.Int("records", total))
+	}()
+	return done
+}
+
+// replayFile replays one capture file.
+func replayFile(name string, speed float64, stop <-chan struct{}) (int, error) {
+	var r io.Reader
+	if name == "-" {
+		r = os.Stdin
+	} else {
+		f, err := os.Open(name)
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+		r = f
+	}
+	logger.L.Info("Replaying capture", zap.String("file", name), zap.Float64("speed", speed))
+	return capture.Replay(r, uplink.Stream, speed, stop)
+}
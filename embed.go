@@ -43,12 +43,12 @@ func WebFS() fs.FS {
 	return embeddedWebFS()
 }
 
-// InitEmbed writes the default configuration file and releases the web bundle
-// to disk on first run.
-func InitEmbed() {
+// InitEmbed writes the default configuration file (to configFile) and releases
+// the web bundle to disk on first run.
+func InitEmbed(configFile string) {
 	// Default config
-	if _, err := os.Stat("config.yaml"); os.IsNotExist(err) {
-		if err = os.WriteFile("config.yaml", defaultConfig, 0644); err != nil {
+	if _, err := os.Stat(configFile); os.IsNotExist(err) {
+		if err = os.WriteFile(configFile, defaultConfig, 0644); err != nil {
 			panic(err)
 		}
 	}
@@ -0,0 +1,94 @@
+package capture
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/APRSCN/aprsgo/internal/infra/logger"
+	"github.com/APRSCN/aprsgo/internal/network/uplink"
+	"github.com/APRSCN/aprsutils/parser"
+	"go.uber.org/zap"
+)
+
+const (
+	pkt1 = "N0CALL>APRS,TCPIP*:>status one"
+	pkt2 = "N0CALL-1>APRS,TCPIP*:>status two"
+)
+
+func mustParse(t *testing.T, raw string) parser.Parsed {
+	t.Helper()
+	p, err := parser.Parse(raw)
+	if err != nil {
+		t.Fatalf("parse %q: %v", raw, err)
+	}
+	return p
+}
+
+// TestRecordReplayRoundTrip records a stream and replays the capture into a
+// fresh stream, which must see the same lines, writer tags and dupe flags.
+func TestRecordReplayRoundTrip(t *testing.T) {
+	logger.L = zap.NewNop()
+
+	var buf bytes.Buffer
+	src := uplink.NewDataStream(10)
+	rec := NewRecorder(src, &buf)
+	src.Write(mustParse(t, pkt1), uplink.WriterUplink)
+	src.WriteDupe(mustParse(t, pkt2), uplink.WriterPeerPrefix+"a")
+	if err := rec.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if rec.Records() != 2 {
+		t.Fatalf("Records = %d, want 2", rec.Records())
+	}
+
+	dst := uplink.NewDataStream(10)
+	ch, unsubscribe := dst.Subscribe()
+	defer unsubscribe()
+	// A malformed line in the capture is skipped.
+	in := strings.NewReader("not json\n" + buf.String())
+	n, err := Replay(in, dst, 0, nil)
+	if err != nil || n != 2 {
+		t.Fatalf("Replay = %d, %v; want 2, nil", n, err)
+	}
+
+	want := []uplink.StreamData{
+		{Writer: uplink.WriterUplink, Dupe: false},
+		{Writer: uplink.WriterPeerPrefix + "a", Dupe: true},
+	}
+	for i, raw := range []string{pkt1, pkt2} {
+		got := <-ch
+		if got.Data.Raw != raw || got.Writer != want[i].Writer || got.Dupe != want[i].Dupe {
+			t.Errorf("item %d = {%q %q %v}, want {%q %q %v}", i,
+				got.Data.Raw, got.Writer, got.Dupe, raw, want[i].Writer, want[i].Dupe)
+		}
+	}
+}
+
+// TestReplayPacing verifies that the capture's spacing is scaled by speed and
+// that closing stop ends a replay that is waiting.
+func TestReplayPacing(t *testing.T) {
+	logger.L = zap.NewNop()
+
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	data := `{"time":"` + t0.Format(time.RFC3339Nano) + `","writer":"uplink","raw":"` + pkt1 + `"}
+{"time":"` + t0.Add(time.Second).Format(time.RFC3339Nano) + `","writer":"uplink","raw":"` + pkt2 + `"}
+{"time":"` + t0.Add(time.Hour).Format(time.RFC3339Nano) + `","writer":"uplink","raw":"` + pkt1 + `"}
+`
+	ds := uplink.NewDataStream(10)
+	stop := make(chan struct{})
+	start := time.Now()
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		close(stop)
+	}()
+	n, err := Replay(strings.NewReader(data), ds, 10, stop)
+	elapsed := time.Since(start)
+	if err != nil || n != 2 {
+		t.Fatalf("Replay = %d, %v; want 2 records before stop", n, err)
+	}
+	if elapsed < 100*time.Millisecond || elapsed > 2*time.Second {
+		t.Errorf("replay took %v, want about 300ms (1s gap at 10x, then stopped)", elapsed)
+	}
+}
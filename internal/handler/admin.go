@@ -0,0 +1,176 @@
+package handler
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/APRSCN/aprsgo/internal/model"
+	"github.com/APRSCN/aprsgo/internal/network/listener"
+	"github.com/APRSCN/aprsgo/internal/security"
+	"github.com/gofiber/fiber/v3"
+)
+
+// adminFilterReq is the body of a filter change.
+type adminFilterReq struct {
+	Filter string `json:"filter"`
+}
+
+// adminReadOnlyReq is the body of a read-only change.
+type adminReadOnlyReq struct {
+	ReadOnly *bool `json:"read_only" validate:"required"`
+}
+
+// adminMessageReq is the body of a server comment sent to a client.
+type adminMessageReq struct {
+	Text string `json:"text" validate:"required,max=200"`
+}
+
+// adminBanReq is the body of a new ban. Duration is in seconds (0 =
+// permanent).
+type adminBanReq struct {
+	Kind     string `json:"kind" validate:"required,oneof=login source ip"`
+	Pattern  string `json:"pattern" validate:"required"`
+	Reason   string `json:"reason"`
+	Duration int64  `json:"duration" validate:"min=0"`
+}
+
+// sessionParam parses the ":session" route parameter.
+func sessionParam(c fiber.Ctx) (uint64, bool) {
+	id, err := strconv.ParseUint(c.Params("session"), 10, 64)
+	return id, err == nil
+}
+
+// AdminClients lists every connected TCP client, read live from the servers,
+// ordered by session id.
+func AdminClients(c fiber.Ctx) error {
+	live := listener.LiveClients()
+	sort.Slice(live, func(i, j int) bool { return live[i].Session < live[j].Session })
+
+	clients := make([]*model.ReturnAdminClient, 0, len(live))
+	for _, v := range live {
+		clients = append(clients, &model.ReturnAdminClient{
+			Session:      v.Session,
+			ReadOnly:     v.ReadOnly,
+			ReturnClient: returnClient(v),
+		})
+	}
+	return model.RespSuccess(c, clients)
+}
+
+// AdminKickClient disconnects a client.
+func AdminKickClient(c fiber.Ctx) error {
+	id, ok := sessionParam(c)
+	if !ok {
+		return model.RespBadRequest(c)
+	}
+	if !listener.KickClient(id) {
+		return model.RespNotFound(c)
+	}
+	return model.RespSuccess(c, any(nil))
+}
+
+// AdminSetClientFilter replaces a client's filter (empty clears it).
+func AdminSetClientFilter(c fiber.Ctx) error {
+	id, ok := sessionParam(c)
+	var req adminFilterReq
+	if !ok || c.Bind().Body(&req) != nil {
+		return model.RespBadRequest(c)
+	}
+	if !listener.SetClientFilter(id, req.Filter) {
+		return model.RespNotFound(c)
+	}
+	return model.RespSuccess(c, any(nil))
+}
+
+// AdminSetClientReadOnly makes a client read-only (its input is dropped) or
+// lifts that again.
+func AdminSetClientReadOnly(c fiber.Ctx) error {
+	id, ok := sessionParam(c)
+	var req adminReadOnlyReq
+	if !ok || c.Bind().Body(&req) != nil {
+		return model.RespBadRequest(c)
+	}
+	if !listener.SetClientReadOnly(id, *req.ReadOnly) {
+		return model.RespNotFound(c)
+	}
+	return model.RespSuccess(c, any(nil))
+}
+
+// AdminSendClient sends a server "#" comment line to a client.
+func AdminSendClient(c fiber.Ctx) error {
+	id, ok := sessionParam(c)
+	var req adminMessageReq
+	if !ok || c.Bind().Body(&req) != nil {
+		return model.RespBadRequest(c)
+	}
+	found, err := listener.SendClientComment(id, req.Text)
+	if !found {
+		return model.RespNotFound(c)
+	}
+	if err != nil {
+		return model.RespServiceUnavailable(c)
+	}
+	return model.RespSuccess(c, any(nil))
+}
+
+// returnBan converts a ban to its API form.
+func returnBan(b security.Ban) model.ReturnBan {
+	r := model.ReturnBan{
+		ID:      b.ID,
+		Kind:    string(b.Kind),
+		Pattern: b.Pattern,
+		Reason:  b.Reason,
+		Created: b.Created,
+	}
+	if !b.Expires.IsZero() {
+		r.Expires = &b.Expires
+	}
+	return r
+}
+
+// AdminBans lists the bans in force.
+func AdminBans(c fiber.Ctx) error {
+	bans := security.Bans.List()
+	out := make([]model.ReturnBan, 0, len(bans))
+	for _, b := range bans {
+		out = append(out, returnBan(b))
+	}
+	return model.RespSuccess(c, out)
+}
+
+// AdminAddBan adds a ban (replacing one with the same kind and pattern) and
+// disconnects the clients it matches.
+func AdminAddBan(c fiber.Ctx) error {
+	var req adminBanReq
+	if c.Bind().Body(&req) != nil {
+		return model.RespBadRequest(c)
+	}
+	b, err := security.Bans.Add(security.BanKind(req.Kind), req.Pattern, req.Reason,
+		time.Duration(req.Duration)*time.Second)
+	if errors.Is(err, security.ErrInvalidBan) {
+		return model.RespBadRequest(c)
+	}
+	if err != nil {
+		// Stored, but the ban file could not be written.
+		return model.RespInternalServerError(c, err)
+	}
+	return model.RespSuccess(c, returnBan(b))
+}
+
+// AdminRemoveBan lifts a ban.
+func AdminRemoveBan(c fiber.Ctx) error {
+	id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+	if err != nil {
+		return model.RespBadRequest(c)
+	}
+	found, err := security.Bans.Remove(id)
+	if !found {
+		return model.RespNotFound(c)
+	}
+	if err != nil {
+		return model.RespInternalServerError(c, err)
+	}
+	return model.RespSuccess(c, any(nil))
+}
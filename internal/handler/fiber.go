@@ -56,7 +56,7 @@ func fiberAPP(webFS fs.FS) *fiber.App {
 	// Use global logger
 	app.Use(fiberzap.New(fiberzap.Config{
 		Logger:   logger.L,
-		SkipURIs: []string{"/api/ping", "/api/status", "/api/stats"},
+		SkipURIs: []string{"/api/ping", "/api/status", "/api/stats", "/api/stream", "/metrics"},
 		Fields:   []string{"ip", "ips", "latency", "status", "method", "url", "requestId", "ua"},
 		FieldsFunc: func(c fiber.Ctx) []zap.Field {
 			return []zap.Field{
@@ -69,6 +69,8 @@ func fiberAPP(webFS fs.FS) *fiber.App {
 	app.Use(middleware.CustomHeader)
 
 	registerAPI(app)
+	registerAdmin(app)
+	registerMetrics(app)
 	registerSubmit(app)
 	registerStatic(app, webFS)
 
@@ -95,6 +97,30 @@ func registerAPI(app *fiber.App) {
 	})
 	api.Get("/status", Status)
 	api.Get("/stats", Stats)
+	api.Get("/stations/:call", Station)
+	api.Get("/stations/:call/packets", StationPackets)
+	api.Get("/positions", Positions)
+	api.Get("/stream", Stream)
+}
+
+// registerAdmin wires the token-protected operator API under /api/admin.
+func registerAdmin(app *fiber.App) {
+	admin := app.Group("/api/admin", middleware.AdminAuth)
+
+	admin.Get("/clients", AdminClients)
+	admin.Delete("/clients/:session", AdminKickClient)
+	admin.Put("/clients/:session/filter", AdminSetClientFilter)
+	admin.Put("/clients/:session/readonly", AdminSetClientReadOnly)
+	admin.Post("/clients/:session/message", AdminSendClient)
+
+	admin.Get("/bans", AdminBans)
+	admin.Post("/bans", AdminAddBan)
+	admin.Delete("/bans/:id", AdminRemoveBan)
+}
+
+// registerMetrics wires the Prometheus scrape endpoint.
+func registerMetrics(app *fiber.App) {
+	app.Get("/metrics", Metrics)
 }
 
 // registerSubmit wires the HTTP packet submit endpoints.
@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/APRSCN/aprsgo/internal/meta"
+	"github.com/APRSCN/aprsgo/internal/model"
+	"github.com/APRSCN/aprsgo/internal/network/listener"
+	"github.com/APRSCN/aprsgo/internal/network/peer"
+	"github.com/APRSCN/aprsgo/internal/network/uplink"
+	"github.com/APRSCN/aprsgo/internal/pkg/historydb"
+	"github.com/APRSCN/aprsgo/internal/system"
+	"github.com/gofiber/fiber/v3"
+)
+
+// metricsContentType is the Prometheus text exposition format version served
+// by Metrics (also understood by OpenMetrics scrapers).
+const metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// metricsPrefix namespaces every exported metric family.
+const metricsPrefix = "aprsgo_"
+
+// counterField maps one cumulative model.Statistics total to a metric name
+// suffix and help text.
+type counterField struct {
+	name string
+	help string
+	get  func(model.Statistics) uint64
+}
+
+// counterFields lists the cumulative totals exported for every Counters scope.
+var counterFields = []counterField{
+	{"packets_received_total", "Packets received.", func(s model.Statistics) uint64 { return s.ReceivedPackets }},
+	{"packets_sent_total", "Packets sent.", func(s model.Statistics) uint64 { return s.SentPackets }},
+	{"packets_duplicate_total", "Duplicate packets dropped.", func(s model.Statistics) uint64 { return s.ReceivedDups }},
+	{"packets_error_total", "Packets rejected as invalid.", func(s model.Statistics) uint64 { return s.ReceivedErrors }},
+	{"packets_qdrop_total", "Packets dropped by q-construct processing.", func(s model.Statistics) uint64 { return s.ReceivedQDrop }},
+	{"packets_ratelimited_total", "Packets dropped for exceeding a rate limit.", func(s model.Statistics) uint64 { return s.ReceivedRateLimited }},
+	{"bytes_received_total", "Bytes received.", func(s model.Statistics) uint64 { return s.ReceivedBytes }},
+	{"bytes_sent_total", "Bytes sent.", func(s model.Statistics) uint64 { return s.SentBytes }},
+}
+
+// labelled is one sample's label set and statistics for a Counters scope.
+type labelled struct {
+	labels []string // alternating name, value
+	stats  model.Statistics
+}
+
+// metricsWriter renders Prometheus text exposition format. Samples of one
+// family must be contiguous, so callers emit a family header and then all of
+// its samples before moving on.
+type metricsWriter struct {
+	buf bytes.Buffer
+}
+
+// family writes the HELP and TYPE header for a metric family.
+func (w *metricsWriter) family(name, typ, help string) {
+	w.buf.WriteString("# HELP " + metricsPrefix + name + " " + help + "\n")
+	w.buf.WriteString("# TYPE " + metricsPrefix + name + " " + typ + "\n")
+}
+
+// sample writes a single sample line. labels alternates name and value.
+func (w *metricsWriter) sample(name string, labels []string, value float64) {
+	w.buf.WriteString(metricsPrefix + name)
+	if len(labels) > 0 {
+		w.buf.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				w.buf.WriteByte(',')
+			}
+			w.buf.WriteString(labels[i] + `="` + escapeLabel(labels[i+1]) + `"`)
+		}
+		w.buf.WriteByte('}')
+	}
+	w.buf.WriteByte(' ')
+	w.buf.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	w.buf.WriteByte('\n')
+}
+
+// gauge writes a single-sample gauge family.
+func (w *metricsWriter) gauge(name, help string, value float64) {
+	w.family(name, "gauge", help)
+	w.sample(name, nil, value)
+}
+
+// counters writes one counter family per counterField for a scope (e.g.
+// "listener"), with one sample per labelled entry.
+func (w *metricsWriter) counters(scope string, entries []labelled) {
+	if len(entries) == 0 {
+		return
+	}
+	for _, f := range counterFields {
+		name := scope + "_" + f.name
+		w.family(name, "counter", f.help)
+		for _, e := range entries {
+			w.sample(name, e.labels, float64(f.get(e.stats)))
+		}
+	}
+}
+
+// labelEscaper escapes label values per the exposition format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a label value.
+func escapeLabel(v string) string { return labelEscaper.Replace(v) }
+
+// boolValue converts a boolean to a 0/1 sample value.
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Metrics exposes every server counter in Prometheus text format: the global
+// client-port totals, each listener, each uplink group, core peers and the
+// system figures.
+func Metrics(c fiber.Ctx) error {
+	w := &metricsWriter{}
+
+	// Server identity and uptime.
+	w.family("build_info", "gauge", "Build information.")
+	w.sample("build_info", []string{"version", meta.Version, "codename", meta.Nickname}, 1)
+	w.gauge("uptime_seconds", "Seconds since the server started.", time.Since(meta.StartAt).Seconds())
+	w.gauge("clients", "Connected TCP clients across all listeners.", float64(listener.GlobalClientCount()))
+	w.gauge("position_cache_entries", "Stations in the position cache.", float64(historydb.Positions.Len()))
+	w.gauge("message_spool_entries", "Messages queued for offline addressees.", float64(listener.SpooledMessages()))
+
+	// Process-wide totals of the client ports.
+	w.counters("global", []labelled{{stats: listener.GlobalStats()}})
+
+	// Duplicates dropped by the server-wide filter, by the path they came from.
+	if uplink.Stream != nil {
+		w.family("dupes_total", "counter", "Duplicate packets dropped by source.")
+		for _, src := range []string{uplink.SourceClient, uplink.SourceUplink, uplink.SourcePeer} {
+			w.sample("dupes_total", []string{"source", src}, float64(uplink.Stream.Dedup().Dupes()[src]))
+		}
+	}
+
+	// Per-listener counters and client counts.
+	listeners := listener.ListenersSnapshot()
+	entries := make([]labelled, 0, len(listeners))
+	for _, l := range listeners {
+		entries = append(entries, labelled{
+			labels: []string{
+				"listener", l.Name, "protocol", l.Protocol, "mode", l.Type,
+				"port", strconv.Itoa(l.Port),
+			},
+			stats: l.Stats(),
+		})
+	}
+	if len(entries) > 0 {
+		w.family("listener_clients", "gauge", "Connected clients per listener.")
+		for i, l := range listeners {
+			w.sample("listener_clients", entries[i].labels, float64(l.OnlineClient()))
+		}
+		w.family("listener_clients_peak", "gauge", "Peak simultaneous clients per listener.")
+		for i, l := range listeners {
+			w.sample("listener_clients_peak", entries[i].labels, float64(l.PeakClient()))
+		}
+	}
+	w.counters("listener", entries)
+
+	// Per-group uplink counters. Groups are sorted for a stable output order.
+	groups := uplink.GroupStats()
+	names := make([]string, 0, len(groups))
+	for g := range groups {
+		names = append(names, g)
+	}
+	sort.Strings(names)
+	active := uplink.Clients()
+	entries = entries[:0]
+	for _, g := range names {
+		entries = append(entries, labelled{labels: []string{"group", g}, stats: groups[g]})
+	}
+	if len(names) > 0 {
+		w.family("uplink_up", "gauge", "Whether the uplink group has an active link.")
+		for _, g := range names {
+			uc := active[g]
+			w.sample("uplink_up", []string{"group", g}, boolValue(uc != nil && uc.Up()))
+		}
+		w.family("uplink_link_bytes_received_total", "counter", "Bytes received on the active uplink connection.")
+		for _, g := range names {
+			if uc := active[g]; uc != nil {
+				w.sample("uplink_link_bytes_received_total", []string{"group", g, "host", uc.Host()},
+					float64(uc.GetStats().TotalRecvBytes))
+			}
+		}
+		w.family("uplink_link_bytes_sent_total", "counter", "Bytes sent on the active uplink connection.")
+		for _, g := range names {
+			if uc := active[g]; uc != nil {
+				w.sample("uplink_link_bytes_sent_total", []string{"group", g, "host", uc.Host()},
+					float64(uc.GetStats().TotalSentBytes))
+			}
+		}
+	}
+	w.counters("uplink", entries)
+
+	// Core peers.
+	peers := peer.List()
+	w.gauge("peers", "Configured core peers.", float64(len(peers)))
+	entries = entries[:0]
+	if len(peers) > 0 {
+		w.family("peer_info", "gauge", "Configured core peer.")
+		for _, p := range peers {
+			w.sample("peer_info", []string{"name", p.Name, "id", p.ID, "addr", p.Addr}, 1)
+		}
+		for _, p := range peers {
+			entries = append(entries, labelled{labels: []string{"group", p.Group, "name", p.Name}, stats: p.Stats})
+		}
+		w.family("peer_up", "gauge", "Whether the core peer link is up.")
+		for i, p := range peers {
+			w.sample("peer_up", entries[i].labels, boolValue(p.Up))
+		}
+		w.family("peer_send_errors_total", "counter", "Failed sends to the core peer.")
+		for i, p := range peers {
+			w.sample("peer_send_errors_total", entries[i].labels, float64(p.SendErrors))
+		}
+		w.family("peer_reconnects_total", "counter", "Outbound TCP reconnects to the core peer.")
+		for i, p := range peers {
+			w.sample("peer_reconnects_total", entries[i].labels, float64(p.Reconnects))
+		}
+	}
+	w.counters("peer", entries)
+
+	// System figures (memory values are published in MB).
+	sys := system.Snapshot()
+	w.gauge("system_cpu_percent", "Host CPU utilisation in percent.", sys.Percent)
+	w.gauge("system_memory_total_bytes", "Host memory total.", sys.Memory.Total*1024*1024)
+	w.gauge("system_memory_used_bytes", "Host memory in use.", sys.Memory.Used*1024*1024)
+	w.gauge("process_resident_memory_bytes", "Resident memory of the server process.", sys.Memory.Self*1024*1024)
+	w.gauge("process_heap_bytes", "Go heap in use.", sys.Memory.Heap*1024*1024)
+	w.family("process_gc_total", "counter", "Completed garbage collection cycles.")
+	w.sample("process_gc_total", nil, float64(sys.Memory.NumGC))
+
+	c.Set(fiber.HeaderContentType, metricsContentType)
+	return c.Send(w.buf.Bytes())
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/APRSCN/aprsgo/internal/model"
+	"github.com/APRSCN/aprsgo/internal/pkg/historydb"
+	"github.com/gofiber/fiber/v3"
+)
+
+// maxPositions caps the stations returned by one area query.
+const maxPositions = 5000
+
+// Positions returns the last-known positions of the stations in an area:
+// "bbox=minLon,minLat,maxLon,maxLat" (a west/south/east/north box, crossing
+// the antimeridian when west > east) or "near=lat,lon,km" (nearest first).
+// The optional "limit" query parameter caps the count.
+func Positions(c fiber.Ctx) error {
+	var (
+		found []historydb.Position
+		near  bool
+	)
+	if q := c.Query("bbox"); q != "" {
+		v, ok := parseFloats(q, 4)
+		if !ok || !validLat(v[1]) || !validLat(v[3]) || v[1] > v[3] || !validLon(v[0]) || !validLon(v[2]) {
+			return model.RespBadRequest(c)
+		}
+		found = historydb.Positions.InBox(v[1], v[0], v[3], v[2])
+	} else if q := c.Query("near"); q != "" {
+		v, ok := parseFloats(q, 3)
+		if !ok || !validLat(v[0]) || !validLon(v[1]) || v[2] < 0 {
+			return model.RespBadRequest(c)
+		}
+		found, near = historydb.Positions.Within(v[0], v[1], v[2]), true
+	} else {
+		return model.RespBadRequest(c)
+	}
+
+	limit := fiber.Query[int](c, "limit")
+	if limit <= 0 || limit > maxPositions {
+		limit = maxPositions
+	}
+	found = found[:min(len(found), limit)]
+
+	positions := make([]model.ReturnPosition, 0, len(found))
+	for _, p := range found {
+		rp := model.ReturnPosition{Call: p.Call, Lat: p.Lat, Lon: p.Lon, Time: p.At}
+		if near {
+			rp.Km = &p.Km
+		}
+		positions = append(positions, rp)
+	}
+	return model.RespSuccess(c, positions)
+}
+
+// parseFloats parses a comma-separated list of exactly n numbers.
+func parseFloats(s string, n int) ([]float64, bool) {
+	parts := strings.Split(s, ",")
+	if len(parts) != n {
+		return nil, false
+	}
+	out := make([]float64, n)
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, false
+		}
+		out[i] = f
+	}
+	return out, true
+}
+
+func validLat(lat float64) bool { return lat >= -90 && lat <= 90 }
+
+func validLon(lon float64) bool { return lon >= -180 && lon <= 180 }
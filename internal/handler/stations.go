@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/APRSCN/aprsgo/internal/model"
+	"github.com/APRSCN/aprsgo/internal/pkg/historydb"
+	"github.com/gofiber/fiber/v3"
+)
+
+// Station returns when a station or object was last heard, its latest packet
+// and its last-known position.
+func Station(c fiber.Ctx) error {
+	call := strings.ToUpper(strings.TrimSpace(c.Params("call")))
+	last, count, ok := historydb.Packets.Last(call)
+	if !ok {
+		return model.RespNotFound(c)
+	}
+
+	st := model.ReturnStation{
+		Call:       call,
+		LastHeard:  last.Time,
+		LastPacket: last.Raw,
+		Packets:    count,
+	}
+	if lat, lon, ok := historydb.Positions.Get(call); ok {
+		st.HasPosition, st.Lat, st.Lon = true, lat, lon
+	}
+	return model.RespSuccess(c, st)
+}
+
+// StationPackets returns the stored packets of a station or object, newest
+// first. The optional "limit" query parameter caps the count.
+func StationPackets(c fiber.Ctx) error {
+	recs := historydb.Packets.Get(c.Params("call"), fiber.Query[int](c, "limit"))
+	if recs == nil {
+		return model.RespNotFound(c)
+	}
+
+	packets := make([]model.ReturnPacket, 0, len(recs))
+	for _, r := range recs {
+		packets = append(packets, model.ReturnPacket{Time: r.Time, Raw: r.Raw})
+	}
+	return model.RespSuccess(c, packets)
+}
@@ -2,6 +2,7 @@ package handler
 
 import (
 	"github.com/APRSCN/aprsgo/internal/model"
+	"github.com/APRSCN/aprsgo/internal/network/peer"
 	"github.com/APRSCN/aprsgo/internal/network/uplink"
 	"github.com/APRSCN/aprsgo/internal/system"
 	"github.com/gofiber/fiber/v3"
@@ -15,6 +16,10 @@ func Stats(c fiber.Ctx) error {
 		UplinkPacketTX: uplink.StatsPacketTX.ToSlice(),
 		UplinkBytesRX:  uplink.StatsBytesRX.ToSlice(),
 		UplinkBytesTX:  uplink.StatsBytesTX.ToSlice(),
+		PeerPacketRX:   peer.StatsPacketRX.ToSlice(),
+		PeerPacketTX:   peer.StatsPacketTX.ToSlice(),
+		PeerBytesRX:    peer.StatsBytesRX.ToSlice(),
+		PeerBytesTX:    peer.StatsBytesTX.ToSlice(),
 	}
 
 	return model.RespSuccess(c, stats)
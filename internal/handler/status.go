@@ -3,6 +3,7 @@ package handler
 import (
 	"fmt"
 	"runtime"
+	"slices"
 	"time"
 
 	"github.com/APRSCN/aprsgo/internal/infra/config"
@@ -46,18 +47,27 @@ func Status(c fiber.Ctx) error {
 		cpuModel = config.Get().Server.Model
 	}
 
-	// Get uplink
+	// Get uplink: the first connected group, by name.
 	var up *model.ReturnUplink = nil
-	if uc := uplink2.GetClient(); uc != nil {
+	clients := uplink2.Clients()
+	groups := make([]string, 0, len(clients))
+	for g := range clients {
+		groups = append(groups, g)
+	}
+	slices.Sort(groups)
+	if len(groups) > 0 {
+		g, uc := groups[0], clients[groups[0]]
 		us := uplink2.Stats.Snapshot()
 		cs := uc.GetStats()
 		up = &model.ReturnUplink{
+			Group:        g,
 			ID:           uc.Callsign(),
 			Mode:         uc.Mode(),
 			Protocol:     uc.Protocol(),
 			Host:         uc.Host(),
 			RealAddr:     uc.RemoteAddr(),
 			Port:         uc.Port(),
+			Filter:       uc.Filter(),
 			ServerID:     uc.ServerID(),
 			Server:       uc.Server(),
 			Up:           uc.Up(),
@@ -76,6 +86,31 @@ func Status(c fiber.Ctx) error {
 		}
 	}
 
+	// Get uplink targets of every group, connected or not. The client dials
+	// the resolved address; the connected target reports the configured host.
+	targets := make([]*model.ReturnUplinkTarget, 0)
+	for _, h := range uplink2.Health() {
+		if up != nil && h.Group == up.Group && h.Up && (h.Addr == up.Host || h.Host == up.Host) && h.Port == up.Port {
+			up.Host, up.Score = h.Host, h.Score
+		}
+		targets = append(targets, &model.ReturnUplinkTarget{
+			Group:      h.Group,
+			Name:       h.Name,
+			Protocol:   h.Protocol,
+			Host:       h.Host,
+			Addr:       h.Addr,
+			Port:       h.Port,
+			Preferred:  h.Preferred,
+			Up:         h.Up,
+			Score:      h.Score,
+			Connects:   h.Connects,
+			Failures:   h.Failures,
+			LatencyMs:  float64(h.Latency) / float64(time.Millisecond),
+			SessionSec: h.Session.Seconds(),
+			PacketRate: h.PacketRate,
+		})
+	}
+
 	// Get listeners
 	listeners := make([]*model.ReturnListener, 0)
 	for _, l := range listener2.ListenersSnapshot() {
@@ -104,42 +139,35 @@ func Status(c fiber.Ctx) error {
 	}
 
 	// Get clients
-	clients := make([]*model.ReturnClient, 0)
+	conns := make([]*model.ReturnClient, 0)
 	for _, v := range listener2.ClientsSnapshot() {
-		clients = append(clients, &model.ReturnClient{
-			At:           v.At,
-			Port:         v.Port,
-			ID:           v.ID,
-			Verified:     v.Verified,
-			Addr:         v.Addr,
-			Uptime:       v.Uptime,
-			Last:         v.Last,
-			LastTX:       v.LastTX,
-			Software:     v.Software,
-			Version:      v.Version,
-			Filter:       v.Filter,
-			OutQ:         v.OutQ,
-			MsgRcpts:     v.MsgRcpts,
-			PacketRX:     v.Stats.ReceivedPackets,
-			PacketRXDup:  v.Stats.ReceivedDups,
-			PacketRXErr:  v.Stats.ReceivedErrors,
-			PacketRXRate: v.Stats.RecvPacketRate,
-			PacketTX:     v.Stats.SentPackets,
-			PacketTXRate: v.Stats.SendPacketRate,
-			BytesRX:      v.Stats.ReceivedBytes,
-			BytesRXRate:  v.Stats.RecvByteRate,
-			BytesTX:      v.Stats.SentBytes,
-			BytesTXRate:  v.Stats.SendByteRate,
-		})
+		conns = append(conns, returnClient(v))
 	}
 
 	// Get core peers
 	peers := make([]*model.ReturnPeer, 0)
 	for _, p := range peer.List() {
 		peers = append(peers, &model.ReturnPeer{
-			Name: p.Name,
-			ID:   p.ID,
-			Addr: p.Addr,
+			Group:         p.Group,
+			Name:          p.Name,
+			ID:            p.ID,
+			Addr:          p.Addr,
+			Protocol:      p.Protocol,
+			Up:            p.Up,
+			Last:          p.LastHeard,
+			Reconnects:    p.Reconnects,
+			SendErrors:    p.SendErrors,
+			PacketRX:      p.Stats.ReceivedPackets,
+			PacketRXDup:   p.Stats.ReceivedDups,
+			PacketRXErr:   p.Stats.ReceivedErrors,
+			PacketRXQDrop: p.Stats.ReceivedQDrop,
+			PacketRXRate:  p.Stats.RecvPacketRate,
+			PacketTX:      p.Stats.SentPackets,
+			PacketTXRate:  p.Stats.SendPacketRate,
+			BytesRX:       p.Stats.ReceivedBytes,
+			BytesRXRate:   p.Stats.RecvByteRate,
+			BytesTX:       p.Stats.SentBytes,
+			BytesTXRate:   p.Stats.SendByteRate,
 		})
 	}
 
@@ -192,8 +220,43 @@ func Status(c fiber.Ctx) error {
 		},
 		Totals:    totals,
 		Uplink:    up,
+		Targets:   targets,
 		Peers:     peers,
 		Listeners: listeners,
-		Clients:   clients,
+		Clients:   conns,
 	})
 }
+
+// returnClient converts a listener client snapshot to its API form.
+func returnClient(v *listener2.Client) *model.ReturnClient {
+	var ig *model.ReturnIGate
+	if v.IGate != nil {
+		ig = &model.ReturnIGate{RFHeard: v.IGate.RFHeard, Messages: v.IGate.Messages, Courtesy: v.IGate.Courtesy}
+	}
+	return &model.ReturnClient{
+		At:           v.At,
+		Port:         v.Port,
+		ID:           v.ID,
+		Verified:     v.Verified,
+		Addr:         v.Addr,
+		Uptime:       v.Uptime,
+		Last:         v.Last,
+		LastTX:       v.LastTX,
+		Software:     v.Software,
+		Version:      v.Version,
+		Filter:       v.Filter,
+		OutQ:         v.OutQ,
+		MsgRcpts:     v.MsgRcpts,
+		PacketRX:     v.Stats.ReceivedPackets,
+		PacketRXDup:  v.Stats.ReceivedDups,
+		PacketRXErr:  v.Stats.ReceivedErrors,
+		PacketRXRate: v.Stats.RecvPacketRate,
+		PacketTX:     v.Stats.SentPackets,
+		PacketTXRate: v.Stats.SendPacketRate,
+		BytesRX:      v.Stats.ReceivedBytes,
+		BytesRXRate:  v.Stats.RecvByteRate,
+		BytesTX:      v.Stats.SentBytes,
+		BytesTXRate:  v.Stats.SendByteRate,
+		IGate:        ig,
+	}
+}
@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"bufio"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/APRSCN/aprsgo/internal/model"
+	"github.com/APRSCN/aprsgo/internal/network/listener"
+	"github.com/APRSCN/aprsgo/internal/network/uplink"
+	"github.com/APRSCN/aprsutils/filter"
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v3"
+	"github.com/valyala/fasthttp"
+	"go.gh.ink/json"
+)
+
+const (
+	// maxStreams caps the number of concurrent live stream connections.
+	maxStreams = 256
+	// streamKeepAlive is the interval of WebSocket pings / SSE comments.
+	streamKeepAlive = 30 * time.Second
+	// streamWriteTimeout bounds a single WebSocket write.
+	streamWriteTimeout = 10 * time.Second
+)
+
+// streams counts the open live stream connections.
+var streams atomic.Int64
+
+// wsUpgrader upgrades /api/stream requests to WebSocket, from any origin.
+var wsUpgrader = websocket.FastHTTPUpgrader{
+	CheckOrigin: func(*fasthttp.RequestCtx) bool { return true },
+}
+
+// packetStream is one live stream subscriber and its filter. A nil filter
+// passes every packet.
+type packetStream struct {
+	mu  sync.RWMutex
+	f   *filter.Filter
+	ctx filter.Context
+}
+
+// newPacketStream creates a stream subscriber with the given APRS-IS filter
+// (empty = everything).
+func newPacketStream(spec string) *packetStream {
+	ps := &packetStream{ctx: listener.FilterContext("")}
+	ps.setFilter(spec)
+	return ps
+}
+
+// setFilter replaces the stream's filter.
+func (ps *packetStream) setFilter(spec string) {
+	var f *filter.Filter
+	if spec = strings.TrimSpace(spec); spec != "" {
+		f = filter.Compile(spec)
+	}
+	ps.mu.Lock()
+	ps.f = f
+	ps.mu.Unlock()
+}
+
+// encode returns the JSON payload for d, or false when the packet is a
+// duplicate or does not pass the filter.
+func (ps *packetStream) encode(d uplink.StreamData) ([]byte, bool) {
+	if d.Dupe {
+		return nil, false
+	}
+	ps.mu.RLock()
+	f := ps.f
+	ps.mu.RUnlock()
+	if f != nil && !f.Match(&d.Data, ps.ctx) {
+		return nil, false
+	}
+	b, err := json.Marshal(model.ReturnStreamPacket{Time: time.Now(), Raw: d.Data.Raw, Packet: d.Data})
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// Stream pushes live packets from the distribution stream to browsers, as
+// WebSocket text frames when the request is an upgrade and as Server-Sent
+// Events otherwise. The optional "filter" query parameter is an APRS-IS
+// filter; on a WebSocket, a text frame from the client replaces it.
+func Stream(c fiber.Ctx) error {
+	if streams.Add(1) > maxStreams {
+		streams.Add(-1)
+		return model.RespServiceUnavailable(c)
+	}
+	ps := newPacketStream(c.Query("filter"))
+
+	if websocket.FastHTTPIsWebSocketUpgrade(c.RequestCtx()) {
+		err := wsUpgrader.Upgrade(c.RequestCtx(), func(conn *websocket.Conn) {
+			defer streams.Add(-1)
+			ps.serveWebSocket(conn)
+		})
+		if err != nil {
+			// The upgrader has already written the error response.
+			streams.Add(-1)
+		}
+		return nil
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+	return c.SendStreamWriter(func(w *bufio.Writer) {
+		defer streams.Add(-1)
+		ps.serveSSE(w)
+	})
+}
+
+// serveWebSocket streams packets to a WebSocket until either side closes it.
+func (ps *packetStream) serveWebSocket(conn *websocket.Conn) {
+	ch, unsubscribe := uplink.Stream.Subscribe()
+	defer unsubscribe()
+	defer conn.Close()
+
+	// Reader: text frames replace the filter; a read error means the client
+	// went away.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			typ, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if typ == websocket.TextMessage {
+				ps.setFilter(string(msg))
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case d, ok := <-ch:
+			if !ok {
+				return
+			}
+			b, ok := ps.encode(d)
+			if !ok {
+				continue
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if conn.WriteMessage(websocket.TextMessage, b) != nil {
+				return
+			}
+		case <-ticker.C:
+			if conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout)) != nil {
+				return
+			}
+		}
+	}
+}
+
+// serveSSE streams packets as Server-Sent Events until a flush fails.
+func (ps *packetStream) serveSSE(w *bufio.Writer) {
+	ch, unsubscribe := uplink.Stream.Subscribe()
+	defer unsubscribe()
+
+	_, _ = w.WriteString(": connected\n\n")
+	if w.Flush() != nil {
+		return
+	}
+
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case d, ok := <-ch:
+			if !ok {
+				return
+			}
+			b, ok := ps.encode(d)
+			if !ok {
+				continue
+			}
+			_, _ = w.WriteString("data: ")
+			_, _ = w.Write(b)
+			_, _ = w.WriteString("\n\n")
+		case <-ticker.C:
+			_, _ = w.WriteString(": keepalive\n\n")
+		}
+		if w.Flush() != nil {
+			return
+		}
+	}
+}
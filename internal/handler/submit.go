@@ -4,7 +4,9 @@ import (
 	"strings"
 
 	"github.com/APRSCN/aprsgo/internal/network/listener"
+	"github.com/APRSCN/aprsgo/internal/security"
 	"github.com/gofiber/fiber/v3"
+	"go.gh.ink/toolbox/fiber/v3/ip"
 )
 
 // maxSubmitBody bounds the size of an HTTP submit POST body.
@@ -20,6 +22,11 @@ const maxSubmitBody = 4096
 // with Content-Type "application/octet-stream". On success
 // it returns 200 with body "ok\n"; injected packets receive a qAC construct.
 func Submit(c fiber.Ctx) error {
+	remote := ip.GetIP(c)
+	if !security.AddrAllowed(remote) {
+		return c.Status(fiber.StatusForbidden).SendString("banned\n")
+	}
+
 	ctype := strings.ToLower(c.Get(fiber.HeaderContentType))
 	if !strings.Contains(ctype, "application/octet-stream") {
 		return c.Status(fiber.StatusBadRequest).SendString("wrong or missing content-type\n")
@@ -32,6 +39,9 @@ func Submit(c fiber.Ctx) error {
 	if len(body) > maxSubmitBody {
 		return c.Status(fiber.StatusBadRequest).SendString("body too large\n")
 	}
+	if !listener.SubmitRateAllowed(remote, string(body)) {
+		return c.Status(fiber.StatusTooManyRequests).SendString("rate limited\n")
+	}
 
 	res, err := listener.SubmitEnvelope(string(body), listener.SubmitHTTP)
 	if err != nil {
@@ -1,15 +1,23 @@
 package handler
 
 import (
+	"io"
+	"net"
 	"net/http/httptest"
+	"slices"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/APRSCN/aprsgo/internal/infra/config"
 	"github.com/APRSCN/aprsgo/internal/infra/logger"
+	"github.com/APRSCN/aprsgo/internal/model"
 	"github.com/APRSCN/aprsgo/internal/network/uplink"
+	"github.com/APRSCN/aprsgo/internal/pkg/historydb"
+	"github.com/APRSCN/aprsutils/parser"
+	"github.com/fasthttp/websocket"
 	"github.com/gofiber/fiber/v3"
+	"go.gh.ink/json"
 	"go.uber.org/zap"
 )
 
@@ -107,3 +115,194 @@ func TestAPIPing(t *testing.T) {
 		t.Errorf("status = %d, want 200", resp.StatusCode)
 	}
 }
+
+func TestMetrics(t *testing.T) {
+	testSetup()
+	app := newTestApp()
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	if resp.StatusCode != 200 {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("content-type = %q, want text/plain exposition format", ct)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	for _, want := range []string{
+		"# TYPE aprsgo_global_packets_received_total counter",
+		"aprsgo_global_packets_received_total ",
+		"aprsgo_build_info{version=",
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics output missing %q", want)
+		}
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if got := escapeLabel("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Errorf("escapeLabel = %q", got)
+	}
+}
+
+// TestStreamWebSocket verifies /api/stream pushes the packets passing its filter.
+func TestStreamWebSocket(t *testing.T) {
+	testSetup()
+	app := newTestApp()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() { _ = app.Listener(ln, fiber.ListenConfig{DisableStartupMessage: true}) }()
+	defer func() { _ = app.Shutdown() }()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+ln.Addr().String()+"/api/stream?filter=b/WANTED", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	// The subscription is set up by the upgrade handler; retry the writes
+	// until it is in place.
+	_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	got := make(chan []byte, 1)
+	go func() {
+		_, msg, err := conn.ReadMessage()
+		if err == nil {
+			got <- msg
+		}
+	}()
+	other, _ := parser.Parse("OTHER>APRS:>skip me")
+	wanted, _ := parser.Parse("WANTED>APRS:>hello")
+	deadline := time.After(3 * time.Second)
+	for {
+		uplink.Stream.Write(other, "test")
+		uplink.Stream.Write(wanted, "test")
+		select {
+		case msg := <-got:
+			var pkt model.ReturnStreamPacket
+			if err := json.Unmarshal(msg, &pkt); err != nil {
+				t.Fatalf("decode %q: %v", msg, err)
+			}
+			if pkt.Raw != wanted.Raw || pkt.Packet.From != "WANTED" {
+				t.Fatalf("got %+v, want the WANTED packet", pkt)
+			}
+			return
+		case <-deadline:
+			t.Fatal("no packet received on the stream")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// TestAdminAuth verifies that the admin API is absent without a configured
+// token and requires it as a bearer token otherwise.
+func TestAdminAuth(t *testing.T) {
+	testSetup()
+	app := newTestApp()
+
+	get := func(auth string) int {
+		req := httptest.NewRequest("GET", "/api/admin/clients", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		resp, err := app.Test(req, fiber.TestConfig{Timeout: 3 * time.Second})
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		return resp.StatusCode
+	}
+
+	if code := get("Bearer secret"); code != 404 {
+		t.Fatalf("without admin_token: status = %d, want 404", code)
+	}
+
+	cfg := config.Get()
+	cfg.Server.Status.AdminToken = "secret"
+	config.Set(cfg)
+	if code := get(""); code != 401 {
+		t.Fatalf("no credentials: status = %d, want 401", code)
+	}
+	if code := get("Bearer wrong"); code != 401 {
+		t.Fatalf("wrong token: status = %d, want 401", code)
+	}
+	if code := get("Bearer secret"); code != 200 {
+		t.Fatalf("valid token: status = %d, want 200", code)
+	}
+}
+
+func TestAPIPositions(t *testing.T) {
+	testSetup()
+	app := newTestApp()
+	historydb.Positions.Update("PTEST1", 60.17, 24.94)
+	historydb.Positions.Update("PTEST2", 59.44, 24.75)
+	historydb.Positions.Update("PTEST3", 59.33, 18.07)
+
+	get := func(query string) (int, []model.ReturnPosition) {
+		resp, err := app.Test(httptest.NewRequest("GET", "/api/positions?"+query, nil))
+		if err != nil {
+			t.Fatalf("app.Test: %v", err)
+		}
+		var body struct {
+			Data []model.ReturnPosition `json:"data"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&body)
+		return resp.StatusCode, body.Data
+	}
+
+	code, got := get("bbox=24,59,26,61")
+	if code != 200 || len(got) != 2 || got[0].Call != "PTEST1" || got[1].Call != "PTEST2" {
+		t.Errorf("bbox: status %d, %+v; want PTEST1 and PTEST2", code, got)
+	}
+	code, got = get("near=60.17,24.94,500&limit=2")
+	if code != 200 || len(got) != 2 || got[0].Call != "PTEST1" || got[1].Km == nil {
+		t.Errorf("near: status %d, %+v; want the 2 nearest with distances", code, got)
+	}
+	for _, q := range []string{"", "bbox=1,2,3", "bbox=0,10,1,5", "near=91,0,10", "near=a,b,c"} {
+		if code, _ := get(q); code != 400 {
+			t.Errorf("%q: status = %d, want 400", q, code)
+		}
+	}
+}
+
+// TestStatusUplinkTargets verifies the status lists the targets of every
+// uplink group, tagged with their group, while no uplink is connected.
+func TestStatusUplinkTargets(t *testing.T) {
+	testSetup()
+	c := config.Get()
+	c.Server.Uplinks = slices.Grow(c.Server.Uplinks, 2)[:2]
+	core, region := &c.Server.Uplinks[0], &c.Server.Uplinks[1]
+	core.Name, core.Group, core.Mode, core.Protocol, core.Host, core.Port = "core", "full", "full", "tcp", "192.0.2.1", 10152
+	region.Name, region.Group, region.Mode, region.Protocol, region.Host, region.Port = "region", "regional", "ro", "tcp", "192.0.2.2", 14580
+	config.Set(c)
+	defer testSetup()
+
+	app := newTestApp()
+	resp, err := app.Test(httptest.NewRequest("GET", "/api/status", nil), fiber.TestConfig{Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("app.Test: %v", err)
+	}
+	var body struct {
+		Data model.ReturnStatus `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Data.Uplink != nil {
+		t.Fatalf("uplink = %+v with no connection", body.Data.Uplink)
+	}
+	groups := make(map[string]string)
+	for _, tg := range body.Data.Targets {
+		groups[tg.Name] = tg.Group
+		if tg.Score == 0 {
+			t.Errorf("target %q has no score", tg.Name)
+		}
+	}
+	if groups["core"] != "full" || groups["region"] != "regional" {
+		t.Fatalf("targets by group = %v", groups)
+	}
+}
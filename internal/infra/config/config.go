@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"syscall"
@@ -21,7 +22,8 @@ var debug atomic.Bool
 
 var signalStopChan chan struct{}
 
-// Debug reports whether debug mode is enabled (presence of config_debug.yaml).
+// Debug reports whether debug mode is enabled (presence of config_debug.yaml
+// next to the config file).
 func Debug() bool { return debug.Load() }
 
 // reloadHooks are invoked (in registration order) after the configuration is
@@ -50,58 +52,99 @@ func runReloadHooks() {
 	}
 }
 
-// load is constructor of static config
-func load() (*viper.Viper, error) {
-	// Init static config
-	cfg := viper.New()
+// path is the config file. Fragments in conf.d and config_debug.yaml are
+// looked up next to it.
+var path = "config.yaml"
 
-	// Set config type
-	cfg.SetConfigType("yaml")
+// SetPath sets the config file to load (default config.yaml in the working
+// directory). It must be called before Init.
+func SetPath(p string) { path = p }
 
-	// Set config path
-	cfg.AddConfigPath("./")
+// Path returns the config file in use.
+func Path() string { return path }
 
-	// Set config file
-	cfg.SetConfigName("config")
+// load reads and parses the static config without installing it, and reports
+// whether debug mode is on. The config file (or config_debug.yaml next to it
+// in debug mode) is merged with the conf.d fragments and then overridden by
+// APRSGO_* environment variables.
+func load() (StaticConfig, bool, error) {
+	var c StaticConfig
+	dir := filepath.Dir(path)
 
 	// Read the config file
-	if err := cfg.ReadInConfig(); err != nil {
-		return nil, err
+	settings, err := readFile(path)
+	if err != nil {
+		return c, false, err
 	}
 
 	// Is debug mode?
-	if _, err := os.Stat("config_debug.yaml"); err == nil {
-		// Init config file
-		cfg.SetConfigName("config_debug")
-
-		// Read the debug config file
-		if err = cfg.ReadInConfig(); err != nil {
-			return nil, err
+	isDebug := false
+	if debugFile := filepath.Join(dir, "config_debug.yaml"); fileExists(debugFile) {
+		// Read the debug config file instead
+		if settings, err = readFile(debugFile); err != nil {
+			return c, false, err
 		}
+		isDebug = true
+	}
 
-		// Set debug status only after the debug config has been read
-		// successfully, so a failed read does not leave the flag set.
-		debug.Store(true)
+	// Merge the fragments
+	if err = mergeFragments(settings, filepath.Join(dir, fragmentDir)); err != nil {
+		return c, false, err
+	}
+
+	cfg := viper.New()
+	if err = cfg.MergeConfigMap(settings); err != nil {
+		return c, false, err
+	}
+
+	// Apply environment overrides
+	if err = applyEnv(cfg); err != nil {
+		return c, false, err
 	}
 
 	// Unmarshal config
-	if err := cfg.Unmarshal(&config); err != nil {
-		return nil, err
+	if err = cfg.Unmarshal(&c); err != nil {
+		return c, false, err
 	}
 
-	return cfg, nil
+	return c, isDebug, nil
 }
 
-// reload static config
-func reload() {
+// fileExists reports whether name exists.
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+// install makes a loaded config current. The debug flag is only set once the
+// whole config has been read successfully.
+func install(c StaticConfig, isDebug bool) {
 	configRWMutex.Lock()
-	_, err := load()
+	config = c
 	configRWMutex.Unlock()
+	debug.Store(isDebug)
+}
+
+// Load reads and parses the config file without installing it, e.g. to check
+// a config before deploying it.
+func Load() (StaticConfig, error) {
+	c, _, err := load()
+	return c, err
+}
 
+// reload static config
+func reload() {
+	c, isDebug, err := load()
 	if err != nil {
 		log.Println("reload static config failed:", err)
 		return
 	}
+	// Refuse a config with errors as a whole instead of half-applying it.
+	if err = Check(c); err != nil {
+		log.Printf("reload static config refused, keeping the running config:\n%v", err)
+		return
+	}
+	install(c, isDebug)
 
 	// Apply the new configuration to subsystems (listeners, uplinks, peers).
 	// Run outside the config lock so hooks may call Get().
@@ -110,12 +153,14 @@ func reload() {
 
 // Init static config
 func Init() {
-	configRWMutex.Lock()
-	defer configRWMutex.Unlock()
-
-	if _, err := load(); err != nil {
+	c, isDebug, err := load()
+	if err != nil {
 		log.Fatal("load static config failed:", err)
 	}
+	if err = Check(c); err != nil {
+		log.Fatalf("invalid static config:\n%v", err)
+	}
+	install(c, isDebug)
 
 	// Prepare a channel to receive signals
 	sigChan := make(chan os.Signal, 1)
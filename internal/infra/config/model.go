@@ -50,10 +50,46 @@ type StaticConfig struct {
 		UplinkBindV4 string `mapstructure:"uplink_bind_v4"`
 		UplinkBindV6 string `mapstructure:"uplink_bind_v6"`
 
+		// DupeWindow is the server-wide duplicate window, in seconds: a copy
+		// of a packet arriving by any path (client, submit, uplink, core peer)
+		// within it is not distributed again. 0 keeps the default (30).
+		DupeWindow int `mapstructure:"dupe_window"`
+
+		// RateLimit is the default per-sender rate limit (per TCP client, per
+		// source IP for UDP and HTTP submits) for listeners without their own
+		// and for HTTP submits.
+		RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+
+		// BanFile persists the runtime ban list managed through the admin API
+		// (empty keeps bans in memory only).
+		BanFile string `mapstructure:"ban_file"`
+
+		// StateFile keeps station positions, the statistics time series,
+		// listener peak client counts and duplicate windows across restarts
+		// (empty disables it).
+		StateFile string `mapstructure:"state_file"`
+
+		// PIDFile records the server's process id so `aprsgo reload` can
+		// signal it (empty disables it).
+		PIDFile string `mapstructure:"pid_file"`
+
+		// Packet history store: the last packets per station/object, kept for
+		// the station API. File is an append-only journal replayed on start
+		// (empty keeps the history in memory only); Depth is packets kept per
+		// station and MaxAge hours kept after last heard (0 = defaults).
+		History struct {
+			File   string `mapstructure:"file"`
+			Depth  int    `mapstructure:"depth"`
+			MaxAge int    `mapstructure:"max_age"`
+		} `mapstructure:"history"`
+
 		// Setting of http status panel
 		Status struct {
 			Host string `mapstructure:"host"`
 			Port int    `mapstructure:"port"`
+			// AdminToken enables the /api/admin endpoints, which require it as
+			// a bearer token. Empty disables them.
+			AdminToken string `mapstructure:"admin_token"`
 		} `mapstructure:"status"`
 		// Setting of aprs server
 		// Mode: fullfeed [Everything] / igate [IGate / Client Port] /
@@ -71,6 +107,22 @@ type StaticConfig struct {
 			// active link in parallel, giving multiple simultaneous uplinks.
 			// Empty group name means the default group.
 			Group string `mapstructure:"group"`
+			// Login and Passcode replace the server id and passcode in the
+			// login line (an empty Passcode with a Login logs in unverified).
+			Login    string `mapstructure:"login"`
+			Passcode string `mapstructure:"passcode"`
+			// Filter is the APRS-IS filter requested from the upstream (not
+			// sent in the full-feed modes "full" and "fullfeed"). Vers replaces the "software version"
+			// announced in the login line.
+			Filter string `mapstructure:"filter"`
+			Vers   string `mapstructure:"vers"`
+			// Preferred makes this the group's primary: it is tried first, and
+			// while another uplink of the group is in use it is probed so the
+			// group fails back to it once it recovers (tcp only).
+			Preferred bool `mapstructure:"preferred"`
+			// Family selects the addresses of Host to use, each tried as a
+			// separate target: "" (all), "ipv4", "ipv6" or "prefer-ipv6".
+			Family string `mapstructure:"family"`
 		} `mapstructure:"uplinks"`
 		// Core peers: UDP server-to-server links exchanging raw APRS lines.
 		// 'peer' is kept for backwards compatibility (a single group); use
@@ -116,6 +168,11 @@ type ListenerConfig struct {
 	// MaxClients caps the simultaneous clients on this listener (0 =
 	// unlimited / use the global cap only).
 	MaxClients int `mapstructure:"max_clients"`
+	// MaxClientsPerIP caps the simultaneous clients from one remote address
+	// on this listener (0 = unlimited). Addresses allowed by PerIPExempt (ACL
+	// syntax, e.g. "allow 10.0.0.0/8") are not counted against it.
+	MaxClientsPerIP int      `mapstructure:"max_clients_per_ip"`
+	PerIPExempt     []string `mapstructure:"per_ip_exempt"`
 	// IBufSize / OBufSize override the global buffer size (KB) for this
 	// listener's input reader and output queue (0 = use global buff_size).
 	IBufSize int `mapstructure:"ibuf_size"`
@@ -123,6 +180,16 @@ type ListenerConfig struct {
 	// ACL is an ordered list of access-control rules, each "allow <CIDR>" or
 	// "deny <CIDR>". When non-empty the default policy is denied.
 	ACL []string `mapstructure:"acl"`
+	// RateLimit overrides the global per-sender rate limit on this listener
+	// (all zero = use the global limit).
+	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
+}
+
+// RateLimitConfig limits the packets and bytes per second a single sender may
+// inject. Excess packets are dropped. 0 leaves that dimension unlimited.
+type RateLimitConfig struct {
+	Packets int `mapstructure:"packets"`
+	Bytes   int `mapstructure:"bytes"`
 }
 
 // PeerGroupConfig describes one core-peer mesh group: a local UDP bind address
@@ -145,4 +212,19 @@ type PeerConfig struct {
 	// Protocol selects this peer's transport: "udp" (default) or "tcp". Peers
 	// within a group may mix transports.
 	Protocol string `mapstructure:"protocol"`
+	// TLS requires mutual TLS on a tcp peer link: each end presents a
+	// certificate issued by CA, in both directions. Cert and Key are this
+	// server's certificate; the peer's certificate must also be valid for
+	// Host or carry ID as its Common Name.
+	TLS  bool   `mapstructure:"tls"`
+	Cert string `mapstructure:"cert"`
+	Key  string `mapstructure:"key"`
+	CA   string `mapstructure:"ca"`
+	// Secret is a key shared with a udp peer: datagrams to it are signed with
+	// HMAC-SHA256, and unsigned or badly signed datagrams from it are dropped.
+	Secret string `mapstructure:"secret"`
+	// ReplayWindow is how far (seconds) a signed datagram's timestamp may be
+	// from this server's clock; a datagram replayed within it is dropped
+	// (0 = 30).
+	ReplayWindow int `mapstructure:"replay_window"`
 }
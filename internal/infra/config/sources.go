@@ -0,0 +1,123 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/spf13/viper"
+	"go.gh.ink/json"
+)
+
+// envPrefix prefixes the environment variables overriding config fields.
+const envPrefix = "APRSGO_"
+
+// fragmentDir is the directory, next to the config file, whose *.yaml
+// fragments are merged into the config.
+const fragmentDir = "conf.d"
+
+// readFile reads one YAML config file into its settings map.
+func readFile(file string) (map[string]any, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	v.SetConfigFile(file)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	return v.AllSettings(), nil
+}
+
+// mergeFragments merges the *.yaml files of dir, in name order, into settings.
+// A missing dir is not an error.
+func mergeFragments(settings map[string]any, dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return err
+	}
+	more, err := filepath.Glob(filepath.Join(dir, "*.yml"))
+	if err != nil {
+		return err
+	}
+	files = append(files, more...)
+	slices.Sort(files)
+	for _, file := range files {
+		frag, err := readFile(file)
+		if err != nil {
+			return err
+		}
+		mergeSettings(settings, frag)
+	}
+	return nil
+}
+
+// mergeSettings merges src into dst: nested sections are merged, lists are
+// appended (so a fragment can add one listener or peer) and other values are
+// replaced.
+func mergeSettings(dst, src map[string]any) {
+	for k, v := range src {
+		switch sv := v.(type) {
+		case map[string]any:
+			if dv, ok := dst[k].(map[string]any); ok {
+				mergeSettings(dv, sv)
+				continue
+			}
+		case []any:
+			if dv, ok := dst[k].([]any); ok {
+				dst[k] = append(dv, sv...)
+				continue
+			}
+		}
+		dst[k] = v
+	}
+}
+
+// applyEnv sets every config field that has an APRSGO_* environment variable,
+// named after its key path (server.status.port is APRSGO_SERVER_STATUS_PORT).
+// Lists of sections take a JSON array; string lists a JSON array or a
+// comma-separated list.
+func applyEnv(v *viper.Viper) error {
+	for _, f := range envFields(reflect.TypeFor[StaticConfig](), "") {
+		val, ok := os.LookupEnv(envPrefix + strings.ToUpper(strings.ReplaceAll(f.key, ".", "_")))
+		if !ok {
+			continue
+		}
+		if f.typ.Kind() == reflect.Slice && (f.typ.Elem().Kind() == reflect.Struct || strings.HasPrefix(strings.TrimSpace(val), "[")) {
+			var list []any
+			if err := json.Unmarshal([]byte(val), &list); err != nil {
+				return err
+			}
+			v.Set(f.key, list)
+			continue
+		}
+		v.Set(f.key, val)
+	}
+	return nil
+}
+
+// envField is a config field settable from the environment.
+type envField struct {
+	key string
+	typ reflect.Type
+}
+
+// envFields lists the fields of t (a config section) by key path, descending
+// into nested sections.
+func envFields(t reflect.Type, prefix string) []envField {
+	var out []envField
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		name, _, _ := strings.Cut(sf.Tag.Get("mapstructure"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		key := prefix + name
+		if sf.Type.Kind() == reflect.Struct {
+			out = append(out, envFields(sf.Type, key+".")...)
+			continue
+		}
+		out = append(out, envField{key: key, typ: sf.Type})
+	}
+	return out
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFile writes a test config file, creating its directory.
+func writeFile(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestLoadFragmentsAndEnv verifies that conf.d fragments add to the lists of
+// the config file and that APRSGO_* variables override fields.
+func TestLoadFragmentsAndEnv(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "aprsgo.yaml")
+	writeFile(t, file, `
+server:
+  id: "N0CALL"
+  status:
+    port: 14501
+  listeners:
+    - { name: "full", mode: "fullfeed", protocol: "tcp", port: 10152 }
+`)
+	writeFile(t, filepath.Join(dir, "conf.d", "10-igate.yaml"), `
+server:
+  listeners:
+    - { name: "igate", mode: "igate", protocol: "tcp", port: 14580, acl: ["allow 10.0.0.0/8"] }
+`)
+	writeFile(t, filepath.Join(dir, "conf.d", "20-peer.yaml"), `
+server:
+  id: "N1CALL"
+  peergroups:
+    - name: "mesh"
+      port: 16404
+      peers:
+        - { name: "a", host: "192.0.2.1", port: 16405 }
+`)
+	t.Setenv("APRSGO_SERVER_STATUS_PORT", "8080")
+	t.Setenv("APRSGO_SERVER_DISALLOW_LOGIN_CALL", "N0CALL,N1CALL")
+	t.Setenv("APRSGO_SERVER_UPLINKS", `[{"name":"up","mode":"full","protocol":"tcp","host":"example.org","port":10152}]`)
+
+	old := path
+	SetPath(file)
+	defer SetPath(old)
+	c, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if c.Server.ID != "N1CALL" {
+		t.Errorf("id = %q, want the fragment's N1CALL", c.Server.ID)
+	}
+	if n := len(c.Server.Listeners); n != 2 || c.Server.Listeners[1].Name != "igate" || len(c.Server.Listeners[1].ACL) != 1 {
+		t.Errorf("listeners = %+v, want full plus the igate fragment", c.Server.Listeners)
+	}
+	if len(c.Server.PeerGroups) != 1 || len(c.Server.PeerGroups[0].Peers) != 1 {
+		t.Errorf("peergroups = %+v, want the mesh fragment", c.Server.PeerGroups)
+	}
+	if c.Server.Status.Port != 8080 {
+		t.Errorf("status port = %d, want 8080 from the environment", c.Server.Status.Port)
+	}
+	if got := c.Server.DisallowLoginCall; len(got) != 2 || got[1] != "N1CALL" {
+		t.Errorf("disallow_login_call = %q, want [N0CALL N1CALL]", got)
+	}
+	if len(c.Server.Uplinks) != 1 || c.Server.Uplinks[0].Host != "example.org" {
+		t.Errorf("uplinks = %+v, want the one from the environment", c.Server.Uplinks)
+	}
+}
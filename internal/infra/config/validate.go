@@ -0,0 +1,295 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// binding is a local address a listener or peer group binds.
+type binding struct {
+	owner     string
+	transport string
+	host      string
+	port      int
+}
+
+// overlaps reports whether two bindings would claim the same socket. A
+// wildcard host overlaps every host of the same transport and port.
+func (b binding) overlaps(o binding) bool {
+	if b.transport != o.transport || b.port != o.port {
+		return false
+	}
+	return strings.Trim(b.host, "[]") == strings.Trim(o.host, "[]") ||
+		wildcardHost(b.host) || wildcardHost(o.host)
+}
+
+// wildcardHost reports whether host binds all local addresses.
+func wildcardHost(host string) bool {
+	switch strings.Trim(host, "[]") {
+	case "", "0.0.0.0", "::":
+		return true
+	}
+	return false
+}
+
+// validPort reports whether port is usable as a remote port.
+func validPort(port int) bool { return port > 0 && port <= 65535 }
+
+// Validate checks c for settings that cannot work: unknown listener modes and
+// protocols, duplicate listener ports, TLS without a certificate, peer groups
+// binding the same address, broken uplinks and an invalid q_protocol_id. It
+// reports every problem found, joined into one error, or nil.
+func Validate(c StaticConfig) error {
+	var (
+		errs  []error
+		binds []binding
+	)
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	bind := func(b binding) {
+		if b.port == 0 {
+			return // ephemeral port
+		}
+		for _, o := range binds {
+			if b.overlaps(o) {
+				fail("%s: %s port %d already bound by %s", b.owner, b.transport, b.port, o.owner)
+				return
+			}
+		}
+		binds = append(binds, b)
+	}
+
+	if id := strings.TrimSpace(c.Server.QProtocolID); id != "" && (len(id) != 1 || id[0] < 'A' || id[0] > 'Z') {
+		fail("q_protocol_id: %q is not a single letter A-Z", c.Server.QProtocolID)
+	}
+	if c.Server.DupeWindow < 0 {
+		fail("dupe_window: must not be negative")
+	}
+
+	for i, lc := range c.Server.Listeners {
+		name := fmt.Sprintf("listener %q", lc.Name)
+		if lc.Name == "" {
+			name = fmt.Sprintf("listener #%d", i)
+		}
+		switch lc.Protocol {
+		case "tcp", "sctp":
+			switch lc.Mode {
+			case "fullfeed", "igate", "dupefeed":
+			default:
+				fail("%s: unknown mode %q", name, lc.Mode)
+			}
+		case "udp":
+			switch lc.Mode {
+			case "", "fullfeed", "igate":
+			default:
+				fail("%s: unknown mode %q for udp", name, lc.Mode)
+			}
+		default:
+			fail("%s: unknown protocol %q", name, lc.Protocol)
+		}
+		if lc.Port < 0 || lc.Port > 65535 {
+			fail("%s: invalid port %d", name, lc.Port)
+		}
+		if lc.TLS {
+			if lc.Protocol != "tcp" {
+				fail("%s: tls is only supported on tcp", name)
+			}
+			if lc.Cert == "" || lc.Key == "" {
+				fail("%s: tls enabled without cert and key", name)
+			}
+		}
+		if lc.MaxClients < 0 || lc.MaxClientsPerIP < 0 {
+			fail("%s: client limits must not be negative", name)
+		}
+		bind(binding{owner: name, transport: lc.Protocol, host: lc.Host, port: lc.Port})
+	}
+
+	// Peer groups: the legacy single group and the peergroups list.
+	groups := c.Server.PeerGroups
+	if len(c.Server.Peer.Peers) > 0 {
+		legacy := c.Server.Peer
+		if legacy.Name == "" {
+			legacy.Name = "default"
+		}
+		groups = append([]PeerGroupConfig{legacy}, groups...)
+	}
+	seenGroup := make(map[string]bool)
+	for _, gc := range groups {
+		name := fmt.Sprintf("peer group %q", gc.Name)
+		if seenGroup[gc.Name] {
+			fail("%s: duplicate group name", name)
+		}
+		seenGroup[gc.Name] = true
+		if gc.Port < 0 || gc.Port > 65535 {
+			fail("%s: invalid bind port %d", name, gc.Port)
+		}
+		var udp, tcp bool
+		for _, p := range gc.Peers {
+			pname := fmt.Sprintf("%s: peer %q", name, p.Name)
+			if p.Host == "" {
+				fail("%s: missing host", pname)
+			}
+			if !validPort(p.Port) {
+				fail("%s: invalid port %d", pname, p.Port)
+			}
+			isTCP := strings.EqualFold(p.Protocol, "tcp")
+			switch strings.ToLower(p.Protocol) {
+			case "", "udp":
+				udp = true
+			case "tcp":
+				tcp = true
+			default:
+				fail("%s: unknown protocol %q", pname, p.Protocol)
+			}
+			if p.TLS {
+				if !isTCP {
+					fail("%s: tls is only supported on tcp", pname)
+				}
+				if p.Cert == "" || p.Key == "" || p.CA == "" {
+					fail("%s: tls enabled without cert, key and ca", pname)
+				}
+			}
+			if p.Secret != "" && isTCP {
+				fail("%s: secret is only supported on udp (use tls on tcp)", pname)
+			}
+			if p.ReplayWindow < 0 {
+				fail("%s: replay_window must not be negative", pname)
+			}
+		}
+		if udp {
+			bind(binding{owner: name, transport: "udp", host: gc.Host, port: gc.Port})
+		}
+		if tcp {
+			bind(binding{owner: name, transport: "tcp", host: gc.Host, port: gc.Port})
+		}
+	}
+
+	// Uplinks: each must be dialable, and a server may only appear in one
+	// group, or the same feed would be pulled in twice.
+	targetGroup := make(map[string]string)
+	preferred := make(map[string]string)
+	for i, up := range c.Server.Uplinks {
+		name := fmt.Sprintf("uplink %q", up.Name)
+		if up.Name == "" {
+			name = fmt.Sprintf("uplink #%d", i)
+		}
+		group := up.Group
+		if group == "" {
+			group = "default"
+		}
+		if up.Host == "" {
+			fail("%s: missing host", name)
+		}
+		if !validPort(up.Port) {
+			fail("%s: invalid port %d", name, up.Port)
+		}
+		switch up.Protocol {
+		case "tcp", "udp":
+		default:
+			fail("%s: unknown protocol %q", name, up.Protocol)
+		}
+		switch up.Mode {
+		case "full", "ro", "fullfeed", "igate":
+		default:
+			fail("%s: unknown mode %q", name, up.Mode)
+		}
+		if up.Filter != "" && (up.Mode == "full" || up.Mode == "fullfeed") {
+			fail("%s: a filter is not sent in %s mode", name, up.Mode)
+		}
+		if up.Passcode != "" && up.Login == "" {
+			fail("%s: passcode without login", name)
+		}
+		if v := strings.TrimSpace(up.Vers); v != "" && !strings.Contains(v, " ") {
+			fail("%s: vers %q must be \"software version\"", name, up.Vers)
+		}
+		switch up.Family {
+		case "", "ipv4", "ipv6", "prefer-ipv6":
+		default:
+			fail("%s: unknown family %q", name, up.Family)
+		}
+		if up.Preferred {
+			if other, ok := preferred[group]; ok {
+				fail("%s: group %q already prefers %s", name, group, other)
+			}
+			preferred[group] = name
+		}
+		target := fmt.Sprintf("%s/%s:%d", up.Protocol, strings.ToLower(up.Host), up.Port)
+		if g, ok := targetGroup[target]; ok && g != group {
+			fail("%s: %s:%d is already an uplink of group %q", name, up.Host, up.Port, g)
+		}
+		targetGroup[target] = group
+	}
+
+	return errors.Join(errs...)
+}
+
+// Problems splits an error returned by Validate or Check into the problems it
+// joins (nil: none).
+func Problems(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
+
+// checks are the subsystem checks registered with RegisterCheck.
+var (
+	checks   []func(StaticConfig) []error
+	checksMu sync.Mutex
+)
+
+// RegisterCheck registers a subsystem check run by Check: one that reports the
+// problems Validate cannot see, such as unreadable TLS files. Subsystems
+// register their checks from init, so they also apply to the first load.
+func RegisterCheck(fn func(StaticConfig) []error) {
+	checksMu.Lock()
+	checks = append(checks, fn)
+	checksMu.Unlock()
+}
+
+// warnings are the subsystem checks registered with RegisterWarning.
+var (
+	warnings   []func(StaticConfig) []error
+	warningsMu sync.Mutex
+)
+
+// RegisterWarning registers a subsystem check run by Warnings: one whose
+// findings depend on the environment, such as DNS, and so must not make the
+// server refuse a config.
+func RegisterWarning(fn func(StaticConfig) []error) {
+	warningsMu.Lock()
+	warnings = append(warnings, fn)
+	warningsMu.Unlock()
+}
+
+// Warnings runs every check registered with RegisterWarning on c and returns
+// what they found.
+func Warnings(c StaticConfig) []error {
+	warningsMu.Lock()
+	fns := append([]func(StaticConfig) []error(nil), warnings...)
+	warningsMu.Unlock()
+	var errs []error
+	for _, fn := range fns {
+		errs = append(errs, fn(c)...)
+	}
+	return errs
+}
+
+// Check runs Validate and every registered subsystem check on c. It reports
+// every problem found, joined into one error, or nil.
+func Check(c StaticConfig) error {
+	errs := Problems(Validate(c))
+	checksMu.Lock()
+	fns := append([]func(StaticConfig) []error(nil), checks...)
+	checksMu.Unlock()
+	for _, fn := range fns {
+		errs = append(errs, fn(c)...)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestValidateReportsEveryProblem verifies that Validate collects all the
+// problems of a config into one error.
+func TestValidateReportsEveryProblem(t *testing.T) {
+	var c StaticConfig
+	c.Server.QProtocolID = "AB"
+	c.Server.DupeWindow = -1
+	c.Server.Listeners = []ListenerConfig{
+		{Name: "full", Mode: "fullfeed", Protocol: "tcp", Host: "[::]", Port: 10152},
+		{Name: "udp", Mode: "igate", Protocol: "udp", Host: "[::]", Port: 10152},
+		{Name: "dup", Mode: "igate", Protocol: "tcp", Host: "127.0.0.1", Port: 10152},
+		{Name: "mode", Mode: "everything", Protocol: "tcp", Port: 14580},
+		{Name: "proto", Mode: "igate", Protocol: "quic", Port: 14581},
+		{Name: "tls", Mode: "igate", Protocol: "tcp", Port: 24580, TLS: true},
+	}
+	c.Server.PeerGroups = []PeerGroupConfig{
+		{Name: "a", Host: "0.0.0.0", Port: 16404, Peers: []PeerConfig{
+			{Name: "p", Host: "192.0.2.1", Port: 16405},
+			{Name: "t", Host: "192.0.2.4", Port: 16405, Protocol: "tcp", TLS: true, Cert: "peer.pem", Secret: "s3cret"},
+		}},
+		{Name: "b", Host: "192.0.2.2", Port: 16404, Peers: []PeerConfig{{Name: "q", Host: "192.0.2.3", Port: 16405}}},
+	}
+	c.Server.Uplinks = slices.Grow(c.Server.Uplinks, 3)[:3]
+	one, two, three := &c.Server.Uplinks[0], &c.Server.Uplinks[1], &c.Server.Uplinks[2]
+	one.Name, one.Mode, one.Protocol, one.Host, one.Port = "one", "full", "tcp", "rotate.aprs.net", 10152
+	one.Preferred = true
+	*two = *one
+	two.Name, two.Group, two.Filter = "two", "second", "r/60/25/100"
+	three.Name, three.Mode, three.Protocol, three.Preferred = "three", "fullfeed", "tcp", true
+	three.Filter, three.Passcode, three.Vers = "r/60/25/100", "12345", "custom"
+	three.Family = "ipv5"
+
+	err := Validate(c)
+	if err == nil {
+		t.Fatal("Validate accepted a broken config")
+	}
+	want := []string{
+		"q_protocol_id",
+		"dupe_window: must not be negative",
+		`listener "dup": tcp port 10152 already bound by listener "full"`,
+		`listener "mode": unknown mode`,
+		`listener "proto": unknown protocol`,
+		`listener "tls": tls enabled without cert and key`,
+		`peer group "a": peer "t": tls enabled without cert, key and ca`,
+		`peer group "a": peer "t": secret is only supported on udp (use tls on tcp)`,
+		`peer group "b": udp port 16404 already bound by peer group "a"`,
+		`uplink "two": a filter is not sent in full mode`,
+		`uplink "two": rotate.aprs.net:10152 is already an uplink of group "default"`,
+		`uplink "three": missing host`,
+		`uplink "three": invalid port 0`,
+		`uplink "three": a filter is not sent in fullfeed mode`,
+		`uplink "three": unknown family "ipv5"`,
+		`uplink "three": group "default" already prefers uplink "one"`,
+		`uplink "three": passcode without login`,
+		`uplink "three": vers "custom" must be "software version"`,
+	}
+	got := err.Error()
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("missing %q in:\n%s", w, got)
+		}
+	}
+	if n := strings.Count(got, "\n") + 1; n != len(want) {
+		t.Errorf("got %d problems, want %d:\n%s", n, len(want), got)
+	}
+}
+
+// TestValidateAcceptsDistinctBinds verifies that an empty config and
+// listeners on distinct addresses (or ephemeral ports) pass.
+func TestValidateAcceptsDistinctBinds(t *testing.T) {
+	var c StaticConfig
+	if err := Validate(c); err != nil {
+		t.Fatalf("empty config: %v", err)
+	}
+	c.Server.Listeners = []ListenerConfig{
+		{Name: "a", Mode: "igate", Protocol: "tcp", Host: "127.0.0.1", Port: 14580},
+		{Name: "b", Mode: "igate", Protocol: "tcp", Host: "::1", Port: 14580},
+		{Name: "c", Mode: "igate", Protocol: "udp", Host: "[::]", Port: 14580},
+		{Name: "d", Mode: "igate", Protocol: "tcp", Port: 0},
+		{Name: "e", Mode: "igate", Protocol: "tcp", Port: 0},
+	}
+	if err := Validate(c); err != nil {
+		t.Fatalf("distinct binds rejected: %v", err)
+	}
+}
+
+// TestReloadRunsRegisteredChecks verifies a reload is refused as a whole when
+// a registered subsystem check fails, so the running config is kept.
+func TestReloadRunsRegisteredChecks(t *testing.T) {
+	checksMu.Lock()
+	saved := checks
+	checksMu.Unlock()
+	defer func() {
+		checksMu.Lock()
+		checks = saved
+		checksMu.Unlock()
+	}()
+	RegisterCheck(func(c StaticConfig) []error {
+		if c.Server.ID == "BAD" {
+			return []error{errors.New("listener \"a\": tls: no such file")}
+		}
+		return nil
+	})
+
+	file := filepath.Join(t.TempDir(), "aprsgo.yaml")
+	old := path
+	SetPath(file)
+	defer SetPath(old)
+	running := Get()
+	defer Set(running)
+
+	writeFile(t, file, "server:\n  id: \"GOOD\"\n")
+	reload()
+	if Get().Server.ID != "GOOD" {
+		t.Fatalf("id = %q after a clean reload, want GOOD", Get().Server.ID)
+	}
+	writeFile(t, file, "server:\n  id: \"BAD\"\n")
+	reload()
+	if Get().Server.ID != "GOOD" {
+		t.Fatalf("id = %q, a reload failing a check was installed", Get().Server.ID)
+	}
+	if err := Check(Get()); err != nil {
+		t.Fatalf("Check on the running config: %v", err)
+	}
+}
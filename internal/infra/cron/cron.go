@@ -3,11 +3,15 @@ package cron
 import (
 	"time"
 
+	"github.com/APRSCN/aprsgo/internal/infra/config"
 	"github.com/APRSCN/aprsgo/internal/infra/logger"
 	"github.com/APRSCN/aprsgo/internal/network/listener"
 	"github.com/APRSCN/aprsgo/internal/network/uplink"
 	"github.com/APRSCN/aprsgo/internal/pkg/historydb"
+	"github.com/APRSCN/aprsgo/internal/security"
+	"github.com/APRSCN/aprsgo/internal/state"
 	"github.com/go-co-op/gocron"
+	"go.uber.org/zap"
 )
 
 var C *gocron.Scheduler
@@ -28,16 +32,48 @@ func registerDefault() {
 	// Periodically expire stale station positions used by range filters.
 	if _, err := C.Every(30).Minutes().Do(func() {
 		historydb.Positions.Cleanup()
+		if err := historydb.Packets.Cleanup(); err != nil {
+			logger.L.Warn("failed to compact packet history", zap.Error(err))
+		}
 	}); err != nil {
 		logger.L.Error("failed to register position cleanup task")
 	}
 
-	// Periodically prune the long-lived duplicate checkers (shared submit
-	// endpoint store and the uplink store) so expired keys do not accumulate.
+	// Periodically flush the packet history journal to disk.
+	if _, err := C.Every(1).Minute().Do(func() {
+		if err := historydb.Packets.Flush(); err != nil {
+			logger.L.Warn("failed to flush packet history", zap.Error(err))
+		}
+	}); err != nil {
+		logger.L.Error("failed to register packet history flush task")
+	}
+
+	// Periodically save the in-memory state, so a crash loses little of it.
 	if _, err := C.Every(5).Minutes().Do(func() {
-		listener.SweepSubmitDedup()
-		uplink.SweepDupes()
+		if err := state.Save(config.Get().Server.StateFile); err != nil {
+			logger.L.Warn("failed to save state", zap.Error(err))
+		}
+	}); err != nil {
+		logger.L.Error("failed to register state save task")
+	}
+
+	// Periodically drop expired bans.
+	if _, err := C.Every(1).Minute().Do(func() {
+		if err := security.Bans.Cleanup(); err != nil {
+			logger.L.Warn("failed to save ban list", zap.Error(err))
+		}
 	}); err != nil {
+		logger.L.Error("failed to register ban cleanup task")
+	}
+
+	// Periodically prune the server-wide duplicate filter so expired keys do
+	// not accumulate.
+	if _, err := C.Every(5).Minutes().Do(uplink.SweepDupes); err != nil {
 		logger.L.Error("failed to register dedup cleanup task")
 	}
+
+	// Forget per-IP submit rate limiters of senders that went quiet.
+	if _, err := C.Every(1).Minute().Do(listener.SweepRateLimits); err != nil {
+		logger.L.Error("failed to register rate limit cleanup task")
+	}
 }
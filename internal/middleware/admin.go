@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/APRSCN/aprsgo/internal/infra/config"
+	"github.com/APRSCN/aprsgo/internal/model"
+	"github.com/gofiber/fiber/v3"
+)
+
+// AdminAuth guards the admin API: requests must carry the configured admin
+// token as "Authorization: Bearer <token>". Without a configured token the
+// admin API does not exist.
+func AdminAuth(c fiber.Ctx) error {
+	token := config.Get().Server.Status.AdminToken
+	if token == "" {
+		return model.RespNotFound(c)
+	}
+
+	auth := c.Get(fiber.HeaderAuthorization)
+	given, ok := strings.CutPrefix(auth, "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(given)), []byte(token)) != 1 {
+		return model.RespUnauthorized(c)
+	}
+
+	return c.Next()
+}
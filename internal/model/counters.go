@@ -14,8 +14,10 @@ type Counters struct {
 	receivedDups    atomic.Uint64
 	receivedErrors  atomic.Uint64
 	receivedQDrop   atomic.Uint64
-	sentBytes       atomic.Uint64
-	receivedBytes   atomic.Uint64
+	// receivedRateLimited counts packets dropped for exceeding a rate limit.
+	receivedRateLimited atomic.Uint64
+	sentBytes           atomic.Uint64
+	receivedBytes       atomic.Uint64
 
 	// Per-second rates. Written only by UpdateRates (a single goroutine) and
 	// read by Snapshot from other goroutines, so they are atomic.
@@ -46,6 +48,9 @@ func (c *Counters) AddReceivedErrors(n uint64) { c.receivedErrors.Add(n) }
 // AddReceivedQDrop atomically adds to the q-drop total.
 func (c *Counters) AddReceivedQDrop(n uint64) { c.receivedQDrop.Add(n) }
 
+// AddReceivedRateLimited atomically adds to the rate-limited drop total.
+func (c *Counters) AddReceivedRateLimited(n uint64) { c.receivedRateLimited.Add(n) }
+
 // AddSentBytes atomically adds to the sent-byte total.
 func (c *Counters) AddSentBytes(n uint64) { c.sentBytes.Add(n) }
 
@@ -75,16 +80,17 @@ func (c *Counters) UpdateRates() {
 // JSON serialisation and display.
 func (c *Counters) Snapshot() Statistics {
 	return Statistics{
-		SentPackets:     c.sentPackets.Load(),
-		ReceivedPackets: c.receivedPackets.Load(),
-		ReceivedDups:    c.receivedDups.Load(),
-		ReceivedErrors:  c.receivedErrors.Load(),
-		ReceivedQDrop:   c.receivedQDrop.Load(),
-		SentBytes:       c.sentBytes.Load(),
-		ReceivedBytes:   c.receivedBytes.Load(),
-		SendPacketRate:  c.sendPacketRate.Load(),
-		RecvPacketRate:  c.recvPacketRate.Load(),
-		SendByteRate:    c.sendByteRate.Load(),
-		RecvByteRate:    c.recvByteRate.Load(),
+		SentPackets:         c.sentPackets.Load(),
+		ReceivedPackets:     c.receivedPackets.Load(),
+		ReceivedDups:        c.receivedDups.Load(),
+		ReceivedErrors:      c.receivedErrors.Load(),
+		ReceivedQDrop:       c.receivedQDrop.Load(),
+		ReceivedRateLimited: c.receivedRateLimited.Load(),
+		SentBytes:           c.sentBytes.Load(),
+		ReceivedBytes:       c.receivedBytes.Load(),
+		SendPacketRate:      c.sendPacketRate.Load(),
+		RecvPacketRate:      c.recvPacketRate.Load(),
+		SendByteRate:        c.sendByteRate.Load(),
+		RecvByteRate:        c.recvByteRate.Load(),
 	}
 }
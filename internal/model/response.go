@@ -28,12 +28,24 @@ func RespSuccess[T any](c fiber.Ctx, data T) error {
 
 // --------------- 400 ---------------
 
+func RespBadRequest(c fiber.Ctx) error {
+	return Resp(c, http.StatusBadRequest, 0, any(nil), "bad request")
+}
+
+func RespUnauthorized(c fiber.Ctx) error {
+	return Resp(c, http.StatusUnauthorized, 0, any(nil), "unauthorized")
+}
+
 func RespNotFound(c fiber.Ctx) error {
 	return Resp(c, http.StatusNotFound, 0, any(nil), "not found")
 }
 
 // --------------- 500 ---------------
 
+func RespServiceUnavailable(c fiber.Ctx) error {
+	return Resp(c, http.StatusServiceUnavailable, 0, any(nil), "service unavailable")
+}
+
 func RespInternalServerError(c fiber.Ctx, err error) error {
 	requestID := requestid.FromContext(c)
 	logger.L.Error(
@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// ReturnStation summarises when a station (or object/item) was last heard.
+type ReturnStation struct {
+	Call        string    `json:"call"`
+	LastHeard   time.Time `json:"last_heard"`
+	LastPacket  string    `json:"last_packet"`
+	Packets     int       `json:"packets"` // packets held in the history
+	HasPosition bool      `json:"has_position"`
+	Lat         float64   `json:"lat"`
+	Lon         float64   `json:"lon"`
+}
+
+// ReturnPacket is one stored packet of a station.
+type ReturnPacket struct {
+	Time time.Time `json:"time"`
+	Raw  string    `json:"raw"`
+}
+
+// ReturnPosition is a station's last-known position in an area query.
+type ReturnPosition struct {
+	Call string    `json:"call"`
+	Lat  float64   `json:"lat"`
+	Lon  float64   `json:"lon"`
+	Time time.Time `json:"time"`
+	Km   *float64  `json:"km,omitempty"` // distance from the near= point
+}
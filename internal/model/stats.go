@@ -8,13 +8,14 @@ import (
 
 // Statistics holds all statistics data
 type Statistics struct {
-	SentPackets     uint64 `json:"sent_packets"`
-	ReceivedPackets uint64 `json:"received_packets"`
-	ReceivedDups    uint64 `json:"received_dups"`
-	ReceivedErrors  uint64 `json:"received_errors"`
-	ReceivedQDrop   uint64 `json:"received_q_drop"`
-	SentBytes       uint64 `json:"sent_bytes"`
-	ReceivedBytes   uint64 `json:"received_bytes"`
+	SentPackets         uint64 `json:"sent_packets"`
+	ReceivedPackets     uint64 `json:"received_packets"`
+	ReceivedDups        uint64 `json:"received_dups"`
+	ReceivedErrors      uint64 `json:"received_errors"`
+	ReceivedQDrop       uint64 `json:"received_q_drop"`
+	ReceivedRateLimited uint64 `json:"received_rate_limited"`
+	SentBytes           uint64 `json:"sent_bytes"`
+	ReceivedBytes       uint64 `json:"received_bytes"`
 
 	// Rates (packets per second)
 	SendPacketRate uint64 `json:"send_packet_rate"`
@@ -31,6 +32,10 @@ type StatsReturn struct {
 	UplinkPacketTX [][2]any `json:"uplink_packet_tx"`
 	UplinkBytesRX  [][2]any `json:"uplink_bytes_rx"`
 	UplinkBytesTX  [][2]any `json:"uplink_bytes_tx"`
+	PeerPacketRX   [][2]any `json:"peer_packet_rx"`
+	PeerPacketTX   [][2]any `json:"peer_packet_tx"`
+	PeerBytesRX    [][2]any `json:"peer_bytes_rx"`
+	PeerBytesTX    [][2]any `json:"peer_bytes_tx"`
 }
 
 func init() {
@@ -26,14 +26,16 @@ type ReturnServer struct {
 // ReturnUplink is uplink info. An uplink is a one-way link to an upstream
 // server (this server is the child).
 type ReturnUplink struct {
+	Group        string          `json:"group"`
 	ID           string          `json:"id"`
 	Mode         client.Mode     `json:"mode"`
 	Protocol     client.Protocol `json:"protocol"`
 	Host         string          `json:"host"`      // configured hostname
 	RealAddr     string          `json:"real_addr"` // resolved remote IP:port
 	Port         int             `json:"port"`
-	ServerID     string          `json:"server_id"` // upstream server callsign
-	Server       string          `json:"server"`    // upstream software banner
+	Filter       string          `json:"filter,omitempty"` // filter requested upstream
+	ServerID     string          `json:"server_id"`        // upstream server callsign
+	Server       string          `json:"server"`           // upstream software banner
 	Up           bool            `json:"up"`
 	Uptime       time.Time       `json:"uptime"`
 	Last         time.Time       `json:"last"`
@@ -47,13 +49,51 @@ type ReturnUplink struct {
 	BytesRXRate  uint64          `json:"bytes_rx_rate"`
 	BytesTX      uint64          `json:"bytes_tx"`
 	BytesTXRate  uint64          `json:"bytes_tx_rate"`
+
+	Score float64 `json:"score"` // health score of the connected target
+}
+
+// ReturnUplinkTarget is the health of one configured uplink target, whether or
+// not its group is connected.
+type ReturnUplinkTarget struct {
+	Group      string  `json:"group"`
+	Name       string  `json:"name"`
+	Protocol   string  `json:"protocol"`
+	Host       string  `json:"host"`
+	Addr       string  `json:"addr"` // resolved address of host
+	Port       int     `json:"port"`
+	Preferred  bool    `json:"preferred"`
+	Up         bool    `json:"up"`
+	Score      float64 `json:"score"`
+	Connects   uint64  `json:"connects"`
+	Failures   int     `json:"failures"`    // failed connects and short sessions, last 30 minutes
+	LatencyMs  float64 `json:"latency_ms"`  // average connect latency
+	SessionSec float64 `json:"session_sec"` // average session length
+	PacketRate float64 `json:"packet_rate"` // packets per second of the current or last session
 }
 
 // ReturnPeer is core-peer info. A peer is a symmetric two-way server link.
 type ReturnPeer struct {
-	Name string `json:"name"`
-	ID   string `json:"id"`
-	Addr string `json:"addr"`
+	Group         string    `json:"group"`
+	Name          string    `json:"name"`
+	ID            string    `json:"id"`
+	Addr          string    `json:"addr"`
+	Protocol      string    `json:"protocol"`
+	Up            bool      `json:"up"`   // connected (tcp) or heard recently (udp)
+	Last          time.Time `json:"last"` // last heard from the peer
+	Reconnects    uint64    `json:"reconnects"`
+	SendErrors    uint64    `json:"send_errors"`
+	PacketRX      uint64    `json:"packet_rx"`
+	PacketRXDup   uint64    `json:"packet_rx_dup"`
+	PacketRXErr   uint64    `json:"packet_rx_err"`
+	PacketRXQDrop uint64    `json:"packet_rx_qdrop"`
+	PacketRXRate  uint64    `json:"packet_rx_rate"`
+	PacketTX      uint64    `json:"packet_tx"`
+	PacketTXRate  uint64    `json:"packet_tx_rate"`
+	BytesRX       uint64    `json:"bytes_rx"`
+	BytesRXRate   uint64    `json:"bytes_rx_rate"`
+	BytesTX       uint64    `json:"bytes_tx"`
+	BytesTXRate   uint64    `json:"bytes_tx_rate"`
 }
 
 // ReturnListener provides a struct to return listener info
@@ -101,6 +141,16 @@ type ReturnClient struct {
 	BytesRXRate  uint64    `json:"bytes_rx_rate"`
 	BytesTX      uint64    `json:"bytes_tx"`
 	BytesTXRate  uint64    `json:"bytes_tx_rate"`
+	// IGate is the gating activity of a client in igate-assist mode.
+	IGate *ReturnIGate `json:"igate,omitempty"`
+}
+
+// ReturnIGate provides a struct to return the gating activity of an igate
+// connected in igate-assist mode.
+type ReturnIGate struct {
+	RFHeard  int    `json:"rf_heard"` // stations in its RF heard list
+	Messages uint64 `json:"messages"` // messages pushed for gating to RF
+	Courtesy uint64 `json:"courtesy"` // courtesy positions pushed
 }
 
 // ReturnTotals aggregates process-wide counters for the status page.
@@ -129,11 +179,30 @@ type ReturnTotals struct {
 
 // ReturnStatus provides a struct to return status of server
 type ReturnStatus struct {
-	Msg       string            `json:"msg"`
-	Server    ReturnServer      `json:"server"`
-	Totals    ReturnTotals      `json:"totals"`
-	Uplink    *ReturnUplink     `json:"uplink"`
-	Peers     []*ReturnPeer     `json:"peers"`
-	Listeners []*ReturnListener `json:"listeners"`
-	Clients   []*ReturnClient   `json:"clients"`
+	Msg       string                `json:"msg"`
+	Server    ReturnServer          `json:"server"`
+	Totals    ReturnTotals          `json:"totals"`
+	Uplink    *ReturnUplink         `json:"uplink"`
+	Targets   []*ReturnUplinkTarget `json:"uplink_targets"` // by group, in the order tried
+	Peers     []*ReturnPeer         `json:"peers"`
+	Listeners []*ReturnListener     `json:"listeners"`
+	Clients   []*ReturnClient       `json:"clients"`
+}
+
+// ReturnAdminClient is a live client as seen by the admin API: the status view
+// plus the session id used to act on it.
+type ReturnAdminClient struct {
+	Session  uint64 `json:"session"`
+	ReadOnly bool   `json:"read_only"`
+	*ReturnClient
+}
+
+// ReturnBan is a runtime ban. Expires is null for a permanent ban.
+type ReturnBan struct {
+	ID      uint64     `json:"id"`
+	Kind    string     `json:"kind"`
+	Pattern string     `json:"pattern"`
+	Reason  string     `json:"reason"`
+	Created time.Time  `json:"created"`
+	Expires *time.Time `json:"expires"`
 }
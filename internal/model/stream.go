@@ -0,0 +1,15 @@
+package model
+
+import (
+	"time"
+
+	"github.com/APRSCN/aprsutils/parser"
+)
+
+// ReturnStreamPacket is one packet pushed on the live stream: the raw line plus
+// every parsed field.
+type ReturnStreamPacket struct {
+	Time   time.Time     `json:"time"`
+	Raw    string        `json:"raw"`
+	Packet parser.Parsed `json:"packet"`
+}
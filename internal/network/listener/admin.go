@@ -0,0 +1,128 @@
+package listener
+
+import (
+	"strings"
+
+	"github.com/APRSCN/aprsgo/internal/infra/logger"
+	"github.com/APRSCN/aprsgo/internal/security"
+	"go.uber.org/zap"
+)
+
+// liveClients returns every TCP client currently registered on any listener,
+// each with the status view taken now (unlike ClientsSnapshot, which lags by up
+// to a second). Clients whose connection is already gone are skipped.
+func liveClients() map[*TCPAPRSClient]*Client {
+	out := make(map[*TCPAPRSClient]*Client)
+	for _, l := range snapshotListeners() {
+		if l.s == nil {
+			continue
+		}
+		l.s.mu.RLock()
+		addr := ""
+		if l.s.listener != nil {
+			addr = l.s.listener.Addr().String()
+		}
+		clients := make([]*TCPAPRSClient, 0, len(l.s.clients))
+		for c := range l.s.clients {
+			clients = append(clients, c)
+		}
+		l.s.mu.RUnlock()
+
+		for _, c := range clients {
+			if v := c.snapshot(addr, l.Port); v != nil {
+				out[c] = v
+			}
+		}
+	}
+	return out
+}
+
+// LiveClients returns the status view of every connected TCP client, read
+// directly from the servers.
+func LiveClients() []*Client {
+	live := liveClients()
+	out := make([]*Client, 0, len(live))
+	for _, v := range live {
+		out = append(out, v)
+	}
+	return out
+}
+
+// clientBySession finds a connected client by its session id.
+func clientBySession(id uint64) (*TCPAPRSClient, *Client) {
+	for c, v := range liveClients() {
+		if c.id == id {
+			return c, v
+		}
+	}
+	return nil, nil
+}
+
+// KickClient disconnects the client with the given session id. It reports
+// whether the client was found.
+func KickClient(id uint64) bool {
+	c, v := clientBySession(id)
+	if c == nil {
+		return false
+	}
+	logger.L.Info("Client kicked by operator",
+		zap.Uint64("session", id), zap.String("callsign", v.ID), zap.String("addr", v.Addr))
+	c.Close()
+	return true
+}
+
+// SetClientFilter replaces the filter of the client with the given session id,
+// exactly as a "#filter" line from the client would. An empty spec clears it.
+func SetClientFilter(id uint64, spec string) bool {
+	c, v := clientBySession(id)
+	if c == nil {
+		return false
+	}
+	spec = strings.TrimSpace(spec)
+	c.mu.Lock()
+	c.setFilter(spec)
+	c.mu.Unlock()
+	logger.L.Info("Client filter set by operator",
+		zap.Uint64("session", id), zap.String("callsign", v.ID), zap.String("filter", spec))
+	return true
+}
+
+// SetClientReadOnly marks the client with the given session id read-only (all
+// its input is dropped) or lifts that again.
+func SetClientReadOnly(id uint64, readOnly bool) bool {
+	c, v := clientBySession(id)
+	if c == nil {
+		return false
+	}
+	c.readOnly.Store(readOnly)
+	logger.L.Info("Client read-only changed by operator",
+		zap.Uint64("session", id), zap.String("callsign", v.ID), zap.Bool("readOnly", readOnly))
+	return true
+}
+
+// SendClientComment sends a server comment ("# <text>") to the client with the
+// given session id. Line breaks in text are replaced so it stays one line.
+func SendClientComment(id uint64, text string) (found bool, err error) {
+	c, _ := clientBySession(id)
+	if c == nil {
+		return false, nil
+	}
+	text = strings.NewReplacer("\r", " ", "\n", " ").Replace(strings.TrimSpace(text))
+	return true, c.Send("# " + text)
+}
+
+// kickBanned disconnects the connected clients a newly added login or IP ban
+// now rejects. Source bans only drop packets and leave connections alone.
+func kickBanned(b security.Ban) {
+	if b.Kind == security.BanSource {
+		return
+	}
+	for c, v := range liveClients() {
+		if (v.ID != "" && !security.LoginAllowed(v.ID)) || !security.AddrAllowed(v.Addr) {
+			logger.L.Info("Kicking banned client",
+				zap.Uint64("session", v.Session), zap.String("callsign", v.ID),
+				zap.String("addr", v.Addr), zap.String("reason", b.Reason))
+			c.Close()
+		}
+	}
+}
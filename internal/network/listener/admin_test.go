@@ -0,0 +1,94 @@
+package listener
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/APRSCN/aprsgo/internal/infra/config"
+	"github.com/APRSCN/aprsgo/internal/infra/logger"
+	"github.com/APRSCN/aprsgo/internal/network/uplink"
+	"github.com/APRSCN/aprsutils"
+	"github.com/APRSCN/aprsutils/client"
+	"go.uber.org/zap"
+)
+
+// TestAdminClientControl exercises the operator controls on a live client:
+// listing, sending a comment, setting the filter, read-only and kicking.
+func TestAdminClientControl(t *testing.T) {
+	logger.L = zap.NewNop()
+	config.Set(testConfig())
+	uplink.Stream = uplink.NewDataStream(10)
+	ch, unsub := uplink.Stream.Subscribe()
+	defer unsub()
+
+	srv, addr := startTestTCPServer(t, client.IGate)
+	defer srv.Stop()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	_ = readLine(t, r, conn) // greeting
+	fmt.Fprintf(conn, "user TEST1 pass %d vers test 1.0\r\n", aprsutils.Passcode("TEST1"))
+	_ = readLine(t, r, conn) // logresp
+
+	var session uint64
+	for _, v := range LiveClients() {
+		if v.ID == "TEST1" {
+			session = v.Session
+		}
+	}
+	if session == 0 {
+		t.Fatal("logged-in client not listed")
+	}
+
+	if found, err := SendClientComment(session, "hello\r\nthere"); !found || err != nil {
+		t.Fatalf("SendClientComment = %v, %v", found, err)
+	}
+	if line := readLine(t, r, conn); line != "# hello  there" {
+		t.Fatalf("comment line = %q", line)
+	}
+
+	if !SetClientFilter(session, "r/10/20/100") {
+		t.Fatal("SetClientFilter: client not found")
+	}
+	for _, v := range LiveClients() {
+		if v.Session == session && v.Filter != "r/10/20/100" {
+			t.Fatalf("filter = %q", v.Filter)
+		}
+	}
+
+	// Read-only: input is dropped.
+	if !SetClientReadOnly(session, true) {
+		t.Fatal("SetClientReadOnly: client not found")
+	}
+	fmt.Fprintf(conn, "TEST1>APRS:>muted\r\n")
+	select {
+	case data := <-ch:
+		t.Fatalf("read-only client packet injected: %v", data.Data.Raw)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	if KickClient(session + 1000) {
+		t.Fatal("kicked an unknown session")
+	}
+	if !KickClient(session) {
+		t.Fatal("KickClient: client not found")
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			break
+		}
+		if !strings.HasPrefix(line, "#") {
+			t.Fatalf("unexpected line after kick: %q", line)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package listener
+
+import (
+	"fmt"
+
+	"github.com/APRSCN/aprsgo/internal/infra/config"
+	"github.com/APRSCN/aprsgo/internal/pkg/acl"
+)
+
+func init() { config.RegisterCheck(Check) }
+
+// Check reports the problems that would disable listeners of cfg when it is
+// loaded and that config.Validate cannot see: invalid ACLs, unreadable TLS
+// files and SCTP on platforms without it. It binds no sockets.
+func Check(cfg config.StaticConfig) []error {
+	var errs []error
+	for i, lc := range cfg.Server.Listeners {
+		name := fmt.Sprintf("listener %q", lc.Name)
+		if lc.Name == "" {
+			name = fmt.Sprintf("listener #%d", i)
+		}
+		fail := func(format string, args ...any) {
+			errs = append(errs, fmt.Errorf("%s: %s", name, fmt.Sprintf(format, args...)))
+		}
+
+		if lc.Protocol == "sctp" && !sctpSupported() {
+			fail("%v", errSCTPUnsupported)
+		}
+		if _, err := acl.Compile(lc.ACL); err != nil {
+			fail("invalid acl: %v", err)
+		}
+		if _, err := acl.Compile(lc.PerIPExempt); err != nil {
+			fail("invalid per_ip_exempt: %v", err)
+		}
+		if lc.TLS && lc.Protocol == "tcp" && lc.Cert != "" && lc.Key != "" {
+			if _, err := newTLSConfig(lc.Cert, lc.Key, lc.ClientCA); err != nil {
+				fail("tls: %v", err)
+			}
+		}
+	}
+	return errs
+}
@@ -0,0 +1,31 @@
+package listener
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/APRSCN/aprsgo/internal/infra/config"
+)
+
+// TestCheckReportsEveryProblem verifies that Check reports each listener
+// whose ACLs or TLS files are bad instead of stopping at the first one.
+func TestCheckReportsEveryProblem(t *testing.T) {
+	var cfg config.StaticConfig
+	cfg.Server.Listeners = []config.ListenerConfig{
+		{Name: "ok", Mode: "igate", Protocol: "tcp", Port: 14580, ACL: []string{"allow 10.0.0.0/8"}},
+		{Name: "udp", Protocol: "udp", Port: 8080},
+		{Name: "bad", Mode: "igate", Protocol: "tcp", ACL: []string{"permit everything"}, PerIPExempt: []string{"allow nowhere"}},
+		{Name: "tls", Mode: "fullfeed", Protocol: "tcp", TLS: true, Cert: "/nonexistent.pem", Key: "/nonexistent.key"},
+	}
+
+	errs := Check(cfg)
+	want := []string{`"bad": invalid acl`, `"bad": invalid per_ip_exempt`, `"tls": tls:`}
+	if len(errs) != len(want) {
+		t.Fatalf("Check returned %d errors, want %d: %v", len(errs), len(want), errs)
+	}
+	for i, w := range want {
+		if !strings.Contains(errs[i].Error(), w) {
+			t.Errorf("error %d = %q, want it to contain %q", i, errs[i], w)
+		}
+	}
+}
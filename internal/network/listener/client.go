@@ -9,6 +9,7 @@ import (
 
 // Client provides a struct to record client
 type Client struct {
+	Session  uint64 // connection id used by the admin API
 	At       string
 	Port     int
 	ID       string
@@ -22,6 +23,8 @@ type Client struct {
 	Filter   string
 	OutQ     int
 	MsgRcpts int
+	ReadOnly bool
+	IGate    *IGateStats // nil unless the client uses igate assist
 
 	Stats model.Statistics
 }
@@ -64,37 +67,9 @@ func update() {
 				addr = l.s.listener.Addr().String()
 			}
 			for c := range l.s.clients {
-				// Read the client's mutable fields under its own mutex to
-				// get a consistent snapshot (callsign/conn are written while
-				// holding c.mu). lastTX/msgRcpts are atomic.
-				c.mu.Lock()
-				if c.conn == nil {
-					c.mu.Unlock()
-					continue
+				if v := c.snapshot(addr, l.Port); v != nil {
+					newClients[c] = v
 				}
-				var lastTX time.Time
-				if ns := c.lastTX.Load(); ns != 0 {
-					lastTX = time.Unix(0, ns)
-				}
-				newClients[c] = &Client{
-					At:       addr,
-					Port:     l.Port,
-					ID:       c.callSign,
-					Verified: c.verified,
-					Addr:     c.conn.RemoteAddr().String(),
-					Uptime:   c.uptime,
-					Last:     c.lastActive,
-					LastTX:   lastTX,
-					Software: c.software,
-					Version:  c.version,
-					Filter:   c.filter,
-					// OutQ: bytes currently queued for delivery to the
-					// client (real async output-queue backlog).
-					OutQ:     int(c.outQBytes.Load()),
-					MsgRcpts: int(c.msgRcpts.Load()),
-					Stats:    c.stats.Snapshot(),
-				}
-				c.mu.Unlock()
 			}
 			l.s.mu.RUnlock()
 		}
@@ -103,3 +78,40 @@ func update() {
 		ClientsMutex.Unlock()
 	}
 }
+
+// snapshot returns the status view of the client, or nil once its connection
+// is gone. at and port describe the listener it is connected to. The client's
+// mutable fields are read under its own mutex to get a consistent snapshot
+// (callsign/conn are written while holding c.mu); lastTX/msgRcpts are atomic.
+func (c *TCPAPRSClient) snapshot(at string, port int) *Client {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	var lastTX time.Time
+	if ns := c.lastTX.Load(); ns != 0 {
+		lastTX = time.Unix(0, ns)
+	}
+	return &Client{
+		Session:  c.id,
+		At:       at,
+		Port:     port,
+		ID:       c.callSign,
+		Verified: c.verified,
+		Addr:     c.conn.RemoteAddr().String(),
+		Uptime:   c.uptime,
+		Last:     c.lastActive,
+		LastTX:   lastTX,
+		Software: c.software,
+		Version:  c.version,
+		Filter:   c.filter,
+		// OutQ: bytes currently queued for delivery to the client (real
+		// async output-queue backlog).
+		OutQ:     int(c.outQBytes.Load()),
+		MsgRcpts: int(c.msgRcpts.Load()),
+		ReadOnly: c.readOnly.Load(),
+		IGate:    c.igateStats(),
+		Stats:    c.stats.Snapshot(),
+	}
+}
@@ -17,6 +17,10 @@ func newFilterContext(clientCall string) filter.Context {
 	return &filterContext{clientCall: clientCall}
 }
 
+// FilterContext returns the filter.Context used for client filters, bound to
+// clientCall (which may be empty), for filtering outside a TCP client.
+func FilterContext(clientCall string) filter.Context { return newFilterContext(clientCall) }
+
 // ClientPosition returns the client's own last-known position, looked up by its
 // login callsign in the position history.
 func (c *filterContext) ClientPosition() (filter.Position, bool) {
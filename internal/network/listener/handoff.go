@@ -0,0 +1,229 @@
+package listener
+
+import (
+	"bufio"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/APRSCN/aprsgo/internal/infra/logger"
+	"github.com/APRSCN/aprsgo/internal/upgrade"
+	"github.com/APRSCN/aprsutils/client"
+	"go.gh.ink/json"
+	"go.uber.org/zap"
+)
+
+// handoffWait bounds how long freezing a client for a live upgrade waits for
+// its output queue to drain and for its reader to stop.
+const handoffWait = 3 * time.Second
+
+// sessionState is the state of a TCP client session handed to the new process
+// in a live upgrade.
+type sessionState struct {
+	Callsign string               `json:"callsign"`
+	Verified bool                 `json:"verified"`
+	Software string               `json:"software"`
+	Version  string               `json:"version"`
+	Filter   string               `json:"filter"`
+	Mode     client.Mode          `json:"mode"`
+	ReadOnly bool                 `json:"read_only"`
+	IGate    bool                 `json:"igate,omitempty"`
+	Uptime   time.Time            `json:"uptime"`
+	Heard    map[string]time.Time `json:"heard"`
+	Courtesy map[string]time.Time `json:"courtesy"`
+	// Pending is input read from the client but not yet processed.
+	Pending string `json:"pending"`
+}
+
+// handoffSessions freezes the logged-in clients of every plain TCP listener
+// and returns their sockets and session state for the new process. It is the
+// session source registered with the upgrade package. TLS and SCTP sessions
+// cannot be transferred and are dropped as before.
+func handoffSessions() []upgrade.Session {
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		out []upgrade.Session
+	)
+	for _, l := range snapshotListeners() {
+		s := l.s
+		if s == nil || s.tlsConfig != nil || s.sctp || s.addr == "" {
+			continue
+		}
+		s.mu.RLock()
+		clients := make([]*TCPAPRSClient, 0, len(s.clients))
+		for c := range s.clients {
+			clients = append(clients, c)
+		}
+		s.mu.RUnlock()
+
+		for _, c := range clients {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if sess, ok := c.freeze(s.addr); ok {
+					mu.Lock()
+					out = append(out, sess)
+					mu.Unlock()
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	logger.L.Info("Handing over client sessions", zap.Int("sessions", len(out)))
+	return out
+}
+
+// freeze stops serving the client and returns its socket and state for the
+// new process. Clients that are not logged in are left to this process, which
+// closes them as it drains; clients that cannot be frozen cleanly (e.g. a
+// stalled output queue) are closed.
+func (c *TCPAPRSClient) freeze(addr string) (upgrade.Session, bool) {
+	c.mu.Lock()
+	tc, ok := c.conn.(*net.TCPConn)
+	loggedIn := c.loggedIn
+	call := c.callSign
+	unsubscribe := c.unsubscribe
+	c.mu.Unlock()
+	if !ok || !loggedIn {
+		return upgrade.Session{}, false
+	}
+
+	// Stop producing output (Send refuses once closed is set) and let the
+	// writer flush what is queued, so no line is cut between the processes.
+	c.closed.Store(true)
+	c.stopHeartbeat()
+	if unsubscribe != nil {
+		unsubscribe()
+	}
+	deadline := time.Now().Add(handoffWait)
+	for c.outQBytes.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if c.outQBytes.Load() > 0 {
+		c.Close()
+		return upgrade.Session{}, false
+	}
+
+	f, err := tc.File()
+	if err != nil {
+		logger.L.Warn("Cannot hand over client socket", zap.String("callsign", call), zap.Error(err))
+		c.Close()
+		return upgrade.Session{}, false
+	}
+
+	// Interrupt the reader; it reports the input it had buffered and ends
+	// the connection here (the duplicated socket stays open).
+	c.handingOff.Store(true)
+	_ = tc.SetReadDeadline(time.Unix(1, 0))
+	var pending string
+	select {
+	case pending = <-c.handoffCh:
+	case <-time.After(handoffWait):
+		_ = f.Close()
+		c.Close()
+		return upgrade.Session{}, false
+	}
+
+	state, err := json.Marshal(c.sessionState(pending))
+	if err != nil {
+		_ = f.Close()
+		return upgrade.Session{}, false
+	}
+	return upgrade.Session{Addr: addr, File: f, State: state}, true
+}
+
+// sessionState captures the client's session for a handoff.
+func (c *TCPAPRSClient) sessionState(pending string) sessionState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return sessionState{
+		Callsign: c.callSign,
+		Verified: c.verified,
+		Software: c.software,
+		Version:  c.version,
+		Filter:   c.filter,
+		Mode:     c.mode,
+		ReadOnly: c.readOnly.Load(),
+		IGate:    c.igateAssist.Load(),
+		Uptime:   c.uptime,
+		Heard:    c.heard.Snapshot(),
+		Courtesy: c.courtesy.Snapshot(),
+		Pending:  pending,
+	}
+}
+
+// restore applies a handed-over session state to a new client before it is
+// served.
+func (c *TCPAPRSClient) restore(st *sessionState) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.callSign = st.Callsign
+	c.verified = st.Verified
+	c.loggedIn = true
+	c.software = st.Software
+	c.version = st.Version
+	if st.Mode != "" {
+		c.mode = st.Mode
+	}
+	if !st.Uptime.IsZero() {
+		c.uptime = st.Uptime
+	}
+	c.setFilter(st.Filter)
+	c.readOnly.Store(st.ReadOnly)
+	c.igateAssist.Store(st.IGate)
+	c.heard.Restore(st.Heard)
+	c.courtesy.Restore(st.Courtesy)
+}
+
+// resumeSessions serves the client sessions the previous process handed over
+// for this server's address.
+func (s *TCPAPRSServer) resumeSessions() {
+	for _, hs := range upgrade.InheritedSessions(s.addr) {
+		s.resume(hs)
+	}
+}
+
+// resume serves a handed-over session, taking ownership of its file.
+func (s *TCPAPRSServer) resume(hs upgrade.Session) {
+	conn, err := net.FileConn(hs.File)
+	_ = hs.File.Close()
+	if err != nil {
+		logger.L.Warn("Cannot resume handed-over session", zap.Error(err))
+		return
+	}
+	var st sessionState
+	if s.tlsConfig != nil || s.sctp || json.Unmarshal(hs.State, &st) != nil || st.Callsign == "" {
+		_ = conn.Close()
+		return
+	}
+	s.wg.Add(1)
+	go s.serveClient(conn, &st)
+}
+
+// resumeHandedBack serves again the sessions handoffSessions froze for an
+// upgrade whose new process could not be started. It is the resume function
+// registered with the upgrade package.
+func resumeHandedBack(sessions []upgrade.Session) {
+	servers := make(map[string]*TCPAPRSServer)
+	for _, l := range snapshotListeners() {
+		if l.s != nil && l.s.addr != "" {
+			servers[l.s.addr] = l.s
+		}
+	}
+	for _, hs := range sessions {
+		if s := servers[hs.Addr]; s != nil {
+			s.resume(hs)
+			continue
+		}
+		_ = hs.File.Close()
+	}
+	logger.L.Info("Resumed client sessions after a failed upgrade", zap.Int("sessions", len(sessions)))
+}
+
+// pendingInput returns the input the reader holds beyond what was consumed:
+// the partial line of an interrupted read plus anything still buffered.
+func pendingInput(partial string, r *bufio.Reader) string {
+	rest, _ := r.Peek(r.Buffered())
+	return partial + string(rest)
+}
@@ -0,0 +1,136 @@
+package listener
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/APRSCN/aprsgo/internal/infra/config"
+	"github.com/APRSCN/aprsgo/internal/infra/logger"
+	"github.com/APRSCN/aprsgo/internal/network/uplink"
+	"github.com/APRSCN/aprsutils/client"
+	"go.gh.ink/json"
+	"go.uber.org/zap"
+)
+
+// TestSessionHandoff freezes a logged-in client on one server and resumes it on
+// another from the handed-over socket and state, as a live upgrade does across
+// processes: the client keeps its login and the input it had not finished
+// sending when it was frozen.
+func TestSessionHandoff(t *testing.T) {
+	logger.L = zap.NewNop()
+	config.Set(testConfig())
+	uplink.Stream = uplink.NewDataStream(10)
+
+	old, addr := startTestTCPServer(t, client.Fullfeed)
+	defer old.Stop()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	readLine(t, r, conn) // banner
+	_, _ = conn.Write([]byte("user TEST pass 29939 vers test 1.0 filter r/1/2/3\r\n"))
+	if got := readLine(t, r, conn); !strings.Contains(got, "verified") {
+		t.Fatalf("logresp = %q", got)
+	}
+
+	// Half a packet is in flight when the upgrade starts.
+	_, _ = conn.Write([]byte("TEST>APRS,TCPIP*:>hand"))
+	time.Sleep(50 * time.Millisecond)
+
+	sessions := handoffSessions()
+	if len(sessions) != 1 {
+		t.Fatalf("handed over %d sessions, want 1", len(sessions))
+	}
+	var st sessionState
+	if err := json.Unmarshal(sessions[0].State, &st); err != nil {
+		t.Fatal(err)
+	}
+	if st.Callsign != "TEST" || !st.Verified || st.Filter != "r/1/2/3" || st.Pending != "TEST>APRS,TCPIP*:>hand" {
+		t.Fatalf("session state = %+v", st)
+	}
+	waitFor(t, "the old server to let the client go", func() bool { return old.ClientCount() == 0 })
+
+	// The new process resumes the session from the duplicated socket.
+	srv := NewTCPAPRSServer(client.Fullfeed, 0)
+	Listeners = []*Listener{{Name: "test", Protocol: "tcp", s: srv}}
+	resumed, err := net.FileConn(sessions[0].File)
+	_ = sessions[0].File.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.wg.Add(1)
+	go srv.serveClient(resumed, &st)
+	defer srv.Stop()
+
+	ch, unsub := uplink.Stream.Subscribe()
+	defer unsub()
+	_, _ = conn.Write([]byte("over\r\n"))
+	select {
+	case d := <-ch:
+		if !strings.Contains(d.Data.Raw, ">handover") || d.Data.From != "TEST" {
+			t.Fatalf("injected %q", d.Data.Raw)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("resumed session did not inject the completed packet")
+	}
+	if srv.ClientCount() != 1 {
+		t.Fatalf("resumed server has %d clients, want 1", srv.ClientCount())
+	}
+}
+
+// TestSessionHandBack verifies sessions frozen for an upgrade whose new
+// process failed to start are served again by the same server, while a client
+// that had not logged in is left connected.
+func TestSessionHandBack(t *testing.T) {
+	logger.L = zap.NewNop()
+	config.Set(testConfig())
+	uplink.Stream = uplink.NewDataStream(10)
+
+	srv, addr := startTestTCPServer(t, client.Fullfeed)
+	defer srv.Stop()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	readLine(t, r, conn) // banner
+	_, _ = conn.Write([]byte("user TEST pass 29939 vers test 1.0\r\n"))
+	if got := readLine(t, r, conn); !strings.Contains(got, "verified") {
+		t.Fatalf("logresp = %q", got)
+	}
+	idle, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer idle.Close()
+	readLine(t, bufio.NewReader(idle), idle) // banner
+	waitFor(t, "both clients", func() bool { return srv.ClientCount() == 2 })
+
+	sessions := handoffSessions()
+	if len(sessions) != 1 {
+		t.Fatalf("handed over %d sessions, want 1", len(sessions))
+	}
+	waitFor(t, "the frozen client to be let go", func() bool { return srv.ClientCount() == 1 })
+
+	resumeHandedBack(sessions)
+	waitFor(t, "the session to be resumed", func() bool { return srv.ClientCount() == 2 })
+	ch, unsub := uplink.Stream.Subscribe()
+	defer unsub()
+	_, _ = conn.Write([]byte("TEST>APRS,TCPIP*:>still here\r\n"))
+	select {
+	case d := <-ch:
+		if d.Data.From != "TEST" {
+			t.Fatalf("injected %q", d.Data.Raw)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("resumed session did not inject")
+	}
+}
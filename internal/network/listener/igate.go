@@ -0,0 +1,114 @@
+package listener
+
+import (
+	"strings"
+
+	"github.com/APRSCN/aprsgo/internal/infra/logger"
+	"github.com/APRSCN/aprsutils/client"
+	"github.com/APRSCN/aprsutils/parser"
+	"go.uber.org/zap"
+)
+
+// serverCommandIGate is the in-band comment keyword switching igate assist on
+// or off ("#igate on" / "#igate off").
+const serverCommandIGate = "igate"
+
+// IGateStats is the gating activity of a client in igate-assist mode.
+type IGateStats struct {
+	RFHeard  int    // stations in its RF heard list
+	Messages uint64 // messages pushed for gating to RF
+	Courtesy uint64 // courtesy positions pushed after a gated message
+}
+
+// handleIGate switches igate assist for a client. In assist mode the client's
+// heard list only records stations it gated from RF, and it receives just the
+// traffic it needs for IS-to-RF gating instead of its filter's: messages to
+// itself and to stations it heard on RF, and a courtesy position of their
+// senders. Only verified clients on igate ports may use it.
+func (s *TCPAPRSServer) handleIGate(c *TCPAPRSClient, arg string) {
+	c.mu.Lock()
+	eligible := c.verified && c.mode == client.IGate && !c.dupefeed
+	call := c.callSign
+	c.mu.Unlock()
+
+	switch strings.ToLower(arg) {
+	case "on":
+		if !eligible {
+			_ = c.Send("# igate assist requires a verified login on an igate port")
+			return
+		}
+		// Start over with stations heard on RF only.
+		if !c.igateAssist.Swap(true) {
+			c.heard.Clear()
+			stations.forget(c)
+		}
+	case "off":
+		c.igateAssist.Store(false)
+	default:
+		_ = c.Send("# usage: #igate on|off")
+		return
+	}
+	state := "off"
+	if c.igateAssist.Load() {
+		state = "on"
+	}
+	_ = c.Send("# igate assist " + state)
+	logger.L.Debug("Client switched igate assist",
+		zap.String("callsign", call), zap.String("state", state))
+}
+
+// shouldGate decides whether an igate-assist client should receive a packet:
+// a message to its own login, a message to a station it heard on RF whose
+// sender is not itself heard there (it needs no gating), or the next
+// position/object/item of the sender of a gated message.
+func (c *TCPAPRSClient) shouldGate(snap deliverState, pkt parser.Parsed) bool {
+	if pkt.PacketType.Has(parser.TypeMessage) {
+		addr := strings.TrimSpace(pkt.Addressee)
+		if addr == "" {
+			return false
+		}
+		if snap.callSign != "" && strings.EqualFold(addr, snap.callSign) {
+			c.msgRcpts.Add(1)
+			return true
+		}
+		if !c.heard.Heard(addr) || c.heard.Heard(pkt.From) {
+			return false
+		}
+		c.msgRcpts.Add(1)
+		c.gatedMessages.Add(1)
+		if pkt.From != "" {
+			c.courtesy.Add(pkt.From)
+		}
+		return true
+	}
+	if pkt.PacketType.Has(parser.TypePosition|parser.TypeObject|parser.TypeItem) &&
+		pkt.From != "" && c.courtesy.Take(pkt.From) {
+		c.gatedCourtesy.Add(1)
+		return true
+	}
+	return false
+}
+
+// igateStats returns the client's gating activity, or nil when igate assist
+// is off.
+func (c *TCPAPRSClient) igateStats() *IGateStats {
+	if !c.igateAssist.Load() {
+		return nil
+	}
+	return &IGateStats{
+		RFHeard:  c.heard.Len(),
+		Messages: c.gatedMessages.Load(),
+		Courtesy: c.gatedCourtesy.Load(),
+	}
+}
+
+// gatedFromRF reports whether a packet's path marks it as heard on RF by the
+// igate that submitted it (a qAR, qAr, qAo or qAO construct).
+func gatedFromRF(path []string) bool {
+	for _, hop := range path {
+		if len(hop) == 3 && hop[0] == 'q' && strings.ContainsRune("RroO", rune(hop[2])) {
+			return true
+		}
+	}
+	return false
+}
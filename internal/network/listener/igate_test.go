@@ -0,0 +1,68 @@
+package listener
+
+import (
+	"testing"
+
+	"github.com/APRSCN/aprsgo/internal/pkg/historydb"
+	"github.com/APRSCN/aprsutils/client"
+)
+
+// TestIGateAssistDelivery verifies that an igate-assist client receives only
+// what it needs for IS-to-RF gating: messages to itself and to stations it
+// heard on RF (unless the sender is local too), and one courtesy position of
+// a gated message's sender.
+func TestIGateAssistDelivery(t *testing.T) {
+	c := &TCPAPRSClient{
+		callSign: "IGATE",
+		mode:     client.IGate,
+		heard:    historydb.NewHeardList(),
+		courtesy: historydb.NewHeardList(),
+	}
+	c.igateAssist.Store(true)
+	c.heard.Add("RFSTA")
+	c.heard.Add("RFSTB")
+	snap := deliverState{loggedIn: true, connected: true, callSign: "IGATE", mode: client.IGate}
+
+	cases := []struct {
+		name string
+		raw  string
+		want bool
+	}{
+		{"message to RF station", "REMOTE>APRS,TCPIP*,qAC,SERVER::RFSTA    :hello{1", true},
+		{"courtesy position", "REMOTE>APRS,TCPIP*,qAC,SERVER:!4903.50N/07201.75W-", true},
+		{"courtesy is one-shot", "REMOTE>APRS,TCPIP*,qAC,SERVER:!4903.50N/07201.75W-", false},
+		{"message between RF stations", "RFSTB>APRS,WIDE1-1,qAR,IGATE::RFSTA    :local{2", false},
+		{"message to unheard station", "REMOTE>APRS,TCPIP*,qAC,SERVER::FARAWAY  :hi{3", false},
+		{"message to the igate", "REMOTE>APRS,TCPIP*,qAC,SERVER::IGATE    :ping{4", true},
+		{"position of RF station", "RFSTA>APRS,TCPIP*,qAC,SERVER:!4903.50N/07201.75W-", false},
+	}
+	for _, tc := range cases {
+		if got := c.shouldGate(snap, parsePkt(t, tc.raw)); got != tc.want {
+			t.Errorf("%s: shouldGate = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	st := c.igateStats()
+	if st == nil || st.Messages != 1 || st.Courtesy != 1 || st.RFHeard != 2 {
+		t.Errorf("igate stats = %+v, want 1 message, 1 courtesy, 2 heard", st)
+	}
+	if c.msgRcpts.Load() != 2 {
+		t.Errorf("MsgRcpts = %d, want 2", c.msgRcpts.Load())
+	}
+	c.igateAssist.Store(false)
+	if c.igateStats() != nil {
+		t.Error("igate stats reported with assist off")
+	}
+}
+
+func TestGatedFromRF(t *testing.T) {
+	cases := map[string]bool{
+		"qAR": true, "qAr": true, "qAo": true, "qAO": true,
+		"qAC": false, "qAS": false, "qAX": false, "WIDE1-1": false,
+	}
+	for hop, want := range cases {
+		if got := gatedFromRF([]string{"WIDE2-1", hop, "N0CALL"}); got != want {
+			t.Errorf("gatedFromRF(%s) = %v, want %v", hop, got, want)
+		}
+	}
+}
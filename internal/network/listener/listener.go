@@ -10,6 +10,9 @@ import (
 	"github.com/APRSCN/aprsgo/internal/infra/logger"
 	"github.com/APRSCN/aprsgo/internal/model"
 	"github.com/APRSCN/aprsgo/internal/pkg/acl"
+	"github.com/APRSCN/aprsgo/internal/pkg/ratelimit"
+	"github.com/APRSCN/aprsgo/internal/security"
+	"github.com/APRSCN/aprsgo/internal/upgrade"
 	"github.com/APRSCN/aprsutils/client"
 	"github.com/APRSCN/aprsutils/filter"
 	"go.uber.org/zap"
@@ -29,6 +32,9 @@ type Listener struct {
 	Visible  string
 	Filter   string
 
+	// key identifies the listener's server across reloads.
+	key listenerKey
+
 	// onlineClient / peakClient track the current and peak simultaneous client
 	// counts. They are written by register/unregister and read by the status
 	// handler from other goroutines, so they are atomic.
@@ -44,15 +50,25 @@ type Listener struct {
 	// maxClients caps simultaneous clients on this listener (0 = no per-port
 	// cap; the global cap still applies).
 	maxClients int
+	// maxClientsPerIP caps simultaneous clients from one remote address (0 =
+	// no cap); addresses perIPExempt allows are not counted (nil = none).
+	maxClientsPerIP int
+	perIPExempt     *acl.List
 	// ibufBytes / obufBytes are the input reader and output queue sizes in
 	// bytes (already resolved from config or the global default).
 	ibufBytes int
 	obufBytes int
 	// dupefeed marks a receive-only port that also delivers duplicate packets.
 	dupefeed bool
+	// rateLimit is the per-sender limit (already resolved from config or the
+	// global default).
+	rateLimit ratelimit.Limits
 
 	s  *TCPAPRSServer   // TCP server (nil for UDP listeners)
 	us *UDPSubmitServer // UDP submit server (nil for TCP listeners)
+	// live is set once the server is listening; a reload retries the others.
+	// Only load touches it.
+	live bool
 
 	// stats holds the latest statistics snapshot. It is stored behind an atomic
 	// pointer so the rate-updater goroutine can publish a new snapshot while the
@@ -79,9 +95,39 @@ func (l *Listener) OnlineClient() int { return int(l.onlineClient.Load()) }
 // PeakClient returns the peak number of simultaneous clients observed.
 func (l *Listener) PeakClient() int { return int(l.peakClient.Load()) }
 
+// PeakClients returns the peak client counts of the running listeners, keyed
+// by address and protocol, for saving across restarts.
+func PeakClients() map[string]int {
+	out := make(map[string]int)
+	for _, l := range snapshotListeners() {
+		out[l.peakKey()] = max(out[l.peakKey()], l.PeakClient())
+	}
+	return out
+}
+
+// RestorePeakClients raises the peak client counts of the running listeners
+// to the saved ones, matched by address and protocol.
+func RestorePeakClients(peaks map[string]int) {
+	for _, l := range snapshotListeners() {
+		if n, ok := peaks[l.peakKey()]; ok {
+			l.raisePeak(n)
+		}
+	}
+}
+
+// peakKey identifies a listener in saved peak client counts.
+func (l *Listener) peakKey() string {
+	return fmt.Sprintf("%s/%s:%d", l.Protocol, l.Host, l.Port)
+}
+
 // setOnlineClient publishes the current client count and bumps the peak.
 func (l *Listener) setOnlineClient(n int) {
 	l.onlineClient.Store(int64(n))
+	l.raisePeak(n)
+}
+
+// raisePeak raises the peak client count to n.
+func (l *Listener) raisePeak(n int) {
 	for {
 		peak := l.peakClient.Load()
 		if int64(n) <= peak || l.peakClient.CompareAndSwap(peak, int64(n)) {
@@ -101,8 +147,8 @@ func (l *Listener) stop() {
 }
 
 // Listeners records all listeners. It is replaced wholesale by load() (on
-// startup and SIGHUP reload) and read by many goroutines, so all access must
-// go through ListenersMutex.
+// startup and SIGHUP reload, reusing the servers of unchanged listeners) and
+// read by many goroutines, so all access must go through ListenersMutex.
 var Listeners = make([]*Listener, 0)
 
 // ListenersMutex guards the Listeners slice (its header), not the individual
@@ -123,12 +169,14 @@ func snapshotListeners() []*Listener {
 // listener set (e.g. for the status handler).
 func ListenersSnapshot() []*Listener { return snapshotListeners() }
 
-// listenerAt returns the listener at index i, or nil if out of range, taken
-// under the read lock.
-func listenerAt(i int) *Listener {
+// listenerAt returns the listener at the position held in index, or nil if out
+// of range. The index is read under the same read lock as the slice, so a
+// reload renumbering a server is observed consistently with the new slice.
+func listenerAt(index *atomic.Int64) *Listener {
 	ListenersMutex.RLock()
 	defer ListenersMutex.RUnlock()
-	if i < 0 || i >= len(Listeners) {
+	i := index.Load()
+	if i < 0 || i >= int64(len(Listeners)) {
 		return nil
 	}
 	return Listeners[i]
@@ -139,29 +187,70 @@ func Init() {
 	// Load init config
 	load()
 
+	// Drop the handed-over sessions of listeners the new config lacks
+	if n := upgrade.CloseUnclaimedSessions(); n > 0 {
+		logger.L.Info("Closed handed-over sessions no listener took", zap.Int("sessions", n))
+	}
+
 	// Start update daemon
 	go update()
 
+	// Start the message spool (store-and-forward for offline addressees)
+	go runSpool()
+
+	// Disconnect clients hit by a newly added ban
+	security.Bans.RegisterHook(kickBanned)
+
+	// Hand connected clients to the new process in a live upgrade
+	upgrade.RegisterSessionSource(handoffSessions, resumeHandedBack)
+
 	logger.L.Debug("Listener initialized")
 }
 
 // Reload rebuilds the listener set from the (already reloaded) configuration,
-// stopping ports that changed/went away and starting new ones. Safe to call on
-// SIGHUP.
+// stopping ports that changed/went away and starting new ones; unchanged ports
+// keep their clients. Safe to call on SIGHUP.
 func Reload() {
 	load()
 	logger.L.Info("Listeners reloaded")
 }
 
-// load (re)builds the listener set from config. It stops the previous
-// listeners, constructs the new ones, publishes them under the write lock and
-// only then starts their servers, so that goroutines started by Start() always
-// observe the published slice (e.g. register/unregister via listenerAt).
-func load() {
-	// Close existing servers.
-	for _, v := range snapshotListeners() {
-		v.stop()
+// listenerKey identifies a running server across reloads: a listener whose
+// key is unchanged keeps its server and connected clients and only takes the
+// new settings; any other change restarts it. The mode is part of the key as
+// it is fixed into each client when it connects.
+type listenerKey struct {
+	host     string
+	port     int
+	protocol string
+	mode     string
+	tls      bool
+	cert     string
+	key      string
+	clientCA string
+}
+
+// keyOf returns the restart key of a listener configuration.
+func keyOf(lc config.ListenerConfig) listenerKey {
+	k := listenerKey{host: lc.Host, port: lc.Port, protocol: lc.Protocol, mode: lc.Mode, tls: lc.TLS}
+	if lc.TLS {
+		k.cert, k.key, k.clientCA = lc.Cert, lc.Key, lc.ClientCA
 	}
+	return k
+}
+
+// load (re)builds the listener set from config. Listeners whose address,
+// protocol, mode and TLS settings are unchanged keep their running server, so
+// their clients stay connected while filter, ACL, client caps, buffers, rate
+// limits and the TLS certificate (reread from its files) change in place; the
+// others are stopped or started, as are listeners whose server failed to
+// start. The new set is published under the write lock (renumbering adopted
+// servers) before new servers start, so that goroutines started by Start()
+// always observe the published slice (e.g. register/unregister via
+// listenerAt()).
+func load() {
+	old := snapshotListeners()
+	claimed := make([]bool, len(old))
 
 	// Build the new listener set (without starting servers yet).
 	globalBuf := config.Get().Server.BuffSize
@@ -169,6 +258,7 @@ func load() {
 		globalBuf = 128
 	}
 	built := make([]*Listener, 0)
+	adopted := make([]bool, 0)
 	for _, lc := range config.Get().Server.Listeners {
 		idx := len(built)
 
@@ -186,6 +276,14 @@ func load() {
 			continue
 		}
 
+		// Compile the per-address cap whitelist (if any).
+		exempt, err := acl.Compile(lc.PerIPExempt)
+		if err != nil {
+			logger.L.Error("Invalid per_ip_exempt, listener disabled",
+				zap.String("name", lc.Name), zap.Error(err))
+			continue
+		}
+
 		// Resolve buffer sizes (KB -> bytes), falling back to the global size.
 		ibuf := globalBuf
 		if lc.IBufSize > 0 {
@@ -197,19 +295,23 @@ func load() {
 		}
 
 		l := &Listener{
-			Name:           lc.Name,
-			Type:           lc.Mode,
-			Protocol:       lc.Protocol,
-			Host:           lc.Host,
-			Port:           lc.Port,
-			Visible:        lc.Visible,
-			Filter:         lc.Filter,
-			compiledFilter: lf,
-			acl:            al,
-			maxClients:     lc.MaxClients,
-			ibufBytes:      ibuf * 1024,
-			obufBytes:      obuf * 1024,
-			dupefeed:       lc.Mode == "dupefeed",
+			Name:            lc.Name,
+			Type:            lc.Mode,
+			Protocol:        lc.Protocol,
+			Host:            lc.Host,
+			Port:            lc.Port,
+			Visible:         lc.Visible,
+			Filter:          lc.Filter,
+			key:             keyOf(lc),
+			compiledFilter:  lf,
+			acl:             al,
+			maxClients:      lc.MaxClients,
+			maxClientsPerIP: lc.MaxClientsPerIP,
+			perIPExempt:     exempt,
+			ibufBytes:       ibuf * 1024,
+			obufBytes:       obuf * 1024,
+			dupefeed:        lc.Mode == "dupefeed",
+			rateLimit:       resolveRateLimit(lc.RateLimit),
 		}
 
 		// A dupefeed port serves the duplicate stream; internally it behaves
@@ -222,6 +324,22 @@ func load() {
 			}
 		}
 
+		// Keep the running server of an unchanged listener, with a
+		// certificate renewed at the same path.
+		if i := findListener(old, claimed, l.key); i >= 0 {
+			claimed[i] = true
+			l.adopt(old[i])
+			if lc.TLS && l.s != nil {
+				if err := l.s.SetTLS(lc.Cert, lc.Key, lc.ClientCA); err != nil {
+					logger.L.Warn("Error reloading TLS cert/key, keeping the current one",
+						zap.String("name", lc.Name), zap.Error(err))
+				}
+			}
+			built = append(built, l)
+			adopted = append(adopted, true)
+			continue
+		}
+
 		switch lc.Protocol {
 		case "tcp":
 			l.s = NewTCPAPRSServer(mode, idx)
@@ -247,26 +365,84 @@ func load() {
 			continue
 		}
 		built = append(built, l)
+		adopted = append(adopted, false)
+	}
+
+	// Close the servers of listeners that changed or went away, freeing their
+	// ports for the new ones.
+	for i, v := range old {
+		if !claimed[i] {
+			v.stop()
+		}
 	}
 
 	// Publish the new slice before starting servers so register/unregister
-	// (which run on freshly started goroutines) see a consistent set.
+	// (which run on freshly started goroutines) see a consistent set. Adopted
+	// servers are renumbered under the same lock.
 	ListenersMutex.Lock()
 	Listeners = built
+	for i, l := range built {
+		if adopted[i] {
+			l.setIndex(i)
+		}
+	}
 	ListenersMutex.Unlock()
 
-	// Start the servers now that the slice is published.
-	for _, l := range built {
+	// Start the new servers now that the slice is published, and apply the
+	// new ACL and rate limit to the clients of adopted ones.
+	for i, l := range built {
 		addr := fmt.Sprintf("%s:%d", l.Host, l.Port)
 		switch {
+		case adopted[i]:
+			if l.s != nil {
+				l.s.kickDenied(l.acl)
+				l.s.setRateLimit(l.rateLimit)
+			}
 		case l.s != nil:
 			if err := l.s.Start(addr); err != nil {
 				logger.L.Error("Error starting server", zap.String("addr", addr), zap.Error(err))
+				continue
 			}
+			l.live = true
 		case l.us != nil:
 			if err := l.us.Start(addr); err != nil {
 				logger.L.Error("Error starting UDP submit server", zap.String("addr", addr), zap.Error(err))
+				continue
 			}
+			l.live = true
 		}
 	}
 }
+
+// findListener returns the index of the first unclaimed live listener in list
+// with the given key, or -1.
+func findListener(list []*Listener, claimed []bool, key listenerKey) int {
+	for i, l := range list {
+		if !claimed[i] && l.live && l.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// adopt takes over the running server of old (an unchanged listener from
+// before a reload) together with its client counts and statistics.
+func (l *Listener) adopt(old *Listener) {
+	l.s, l.us, l.live = old.s, old.us, old.live
+	l.onlineClient.Store(old.onlineClient.Load())
+	l.peakClient.Store(old.peakClient.Load())
+	if st := old.stats.Load(); st != nil {
+		l.stats.Store(st)
+	}
+}
+
+// setIndex points the listener's server at its new position in Listeners.
+// The caller must hold ListenersMutex for writing.
+func (l *Listener) setIndex(i int) {
+	if l.s != nil {
+		l.s.index.Store(int64(i))
+	}
+	if l.us != nil {
+		l.us.index.Store(int64(i))
+	}
+}
@@ -0,0 +1,53 @@
+package listener
+
+import (
+	"github.com/APRSCN/aprsgo/internal/infra/config"
+	"github.com/APRSCN/aprsgo/internal/pkg/ratelimit"
+)
+
+// submitLimits holds the per-IP rate limiters of HTTP submits. UDP submit
+// servers keep their own.
+var submitLimits = ratelimit.NewKeyed()
+
+// globalRateLimit returns the server-wide per-sender rate limit.
+func globalRateLimit() ratelimit.Limits {
+	rc := config.Get().Server.RateLimit
+	return ratelimit.Limits{Packets: rc.Packets, Bytes: rc.Bytes}
+}
+
+// resolveRateLimit returns a listener's own rate limit, or the global one when
+// the listener sets none.
+func resolveRateLimit(rc config.RateLimitConfig) ratelimit.Limits {
+	lim := ratelimit.Limits{Packets: rc.Packets, Bytes: rc.Bytes}
+	if !lim.Enabled() {
+		return globalRateLimit()
+	}
+	return lim
+}
+
+// SubmitRateAllowed charges an HTTP submit payload from ip against the global
+// per-sender rate limit and reports whether it may be processed. Packets over
+// the limit are counted as rate-limited.
+func SubmitRateAllowed(ip, payload string) bool {
+	_, _, packets, _ := parseSubmitEnvelope(payload)
+	n := max(len(packets), 1)
+	if submitLimits.Allow(ip, globalRateLimit(), n, len(payload)) {
+		return true
+	}
+	globalStats.AddReceivedRateLimited(uint64(n))
+	return false
+}
+
+// SweepRateLimits forgets per-IP limiters that have fully refilled. It is
+// intended to be called periodically (e.g. from cron).
+func SweepRateLimits() {
+	submitLimits.Sweep()
+	for _, l := range snapshotListeners() {
+		if l.s != nil {
+			l.s.limits.Sweep()
+		}
+		if l.us != nil {
+			l.us.limits.Sweep()
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package listener
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/APRSCN/aprsgo/internal/infra/config"
+	"github.com/APRSCN/aprsgo/internal/infra/logger"
+	"github.com/APRSCN/aprsgo/internal/network/uplink"
+	"github.com/APRSCN/aprsgo/internal/pkg/acl"
+	"go.uber.org/zap"
+)
+
+// waitFor polls cond until it holds or a deadline passes.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestReloadKeepsUnchangedListeners verifies that a reload keeps the server
+// (and clients) of a listener whose address is unchanged while applying its
+// new settings, stops removed listeners, and kicks clients a new ACL denies.
+func TestReloadKeepsUnchangedListeners(t *testing.T) {
+	logger.L = zap.NewNop()
+	uplink.Stream = uplink.NewDataStream(10)
+	Listeners = nil
+
+	full := config.ListenerConfig{Name: "full", Mode: "fullfeed", Protocol: "tcp", Host: "127.0.0.1"}
+	udp := config.ListenerConfig{Name: "udp", Mode: "fullfeed", Protocol: "udp", Host: "127.0.0.1"}
+	cfg := testConfig()
+	cfg.Server.Listeners = []config.ListenerConfig{full, udp}
+	config.Set(cfg)
+	load()
+	kept := Listeners[0].s
+	removed := Listeners[1].us
+	defer func() {
+		for _, l := range snapshotListeners() {
+			l.stop()
+		}
+	}()
+
+	conn, err := net.Dial("tcp", kept.listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	readLine(t, r, conn) // banner
+	waitFor(t, "client registration", func() bool { return kept.ClientCount() == 1 })
+
+	// Insert a new listener before it, tighten its settings, drop the UDP one.
+	full.MaxClients = 5
+	full.Filter = "t/m"
+	full.ACL = []string{"allow 127.0.0.0/8"}
+	igate := config.ListenerConfig{Name: "igate", Mode: "igate", Protocol: "tcp", Host: "127.0.0.1"}
+	cfg.Server.Listeners = []config.ListenerConfig{igate, full}
+	config.Set(cfg)
+	load()
+
+	l := ListenersSnapshot()[1]
+	if l.s != kept {
+		t.Fatal("unchanged listener got a new server")
+	}
+	if kept.index.Load() != 1 || listenerAt(&kept.index) != l {
+		t.Fatalf("adopted server index = %d, want 1", kept.index.Load())
+	}
+	if l.maxClients != 5 || l.compiledFilter == nil {
+		t.Fatal("new settings not applied to the adopted listener")
+	}
+	if l.OnlineClient() != 1 || kept.ClientCount() != 1 {
+		t.Fatalf("client lost across reload: online = %d", l.OnlineClient())
+	}
+	select {
+	case <-removed.stop:
+	default:
+		t.Fatal("removed UDP listener still running")
+	}
+
+	// An ACL that denies the connected client disconnects it.
+	full.ACL = []string{"deny 127.0.0.0/8"}
+	cfg.Server.Listeners = []config.ListenerConfig{igate, full}
+	config.Set(cfg)
+	load()
+	if ListenersSnapshot()[1].s != kept {
+		t.Fatal("ACL change restarted the listener")
+	}
+	waitFor(t, "denied client to be kicked", func() bool { return kept.ClientCount() == 0 })
+}
+
+// TestKickDeniedSkipsClosedClient verifies an ACL reload racing a disconnect
+// skips the client whose connection is already closed.
+func TestKickDeniedSkipsClosedClient(t *testing.T) {
+	logger.L = zap.NewNop()
+	list, err := acl.Compile([]string{"deny 0.0.0.0/0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := &TCPAPRSClient{}
+	s := &TCPAPRSServer{clients: map[*TCPAPRSClient]bool{closed: false}}
+	s.kickDenied(list) // must not dereference the nil conn
+}
+
+// writeCertFiles writes cert's certificate and key as PEM files in dir.
+func writeCertFiles(t *testing.T, dir string, cert tls.Certificate) (certFile, keyFile string) {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	for name, block := range map[string]*pem.Block{
+		certFile: {Type: "CERTIFICATE", Bytes: cert.Certificate[0]},
+		keyFile:  {Type: "PRIVATE KEY", Bytes: der},
+	} {
+		if err := os.WriteFile(name, pem.EncodeToMemory(block), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return certFile, keyFile
+}
+
+// TestReloadRenewsCertAndRetriesFailed verifies a reload serves a certificate
+// renewed at the same path without restarting the listener, and starts a
+// listener whose port was busy at the previous load.
+func TestReloadRenewsCertAndRetriesFailed(t *testing.T) {
+	logger.L = zap.NewNop()
+	uplink.Stream = uplink.NewDataStream(10)
+	Listeners = nil
+	defer func() {
+		for _, l := range snapshotListeners() {
+			l.stop()
+		}
+	}()
+
+	ca, caKey := genCA(t)
+	dir := t.TempDir()
+	certFile, keyFile := writeCertFiles(t, dir, genLeaf(t, ca, caKey, "OLD"))
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	busyPort := busy.Addr().(*net.TCPAddr).Port
+
+	secure := config.ListenerConfig{Name: "tls", Mode: "fullfeed", Protocol: "tcp", Host: "127.0.0.1",
+		TLS: true, Cert: certFile, Key: keyFile}
+	blocked := config.ListenerConfig{Name: "blocked", Mode: "fullfeed", Protocol: "tcp", Host: "127.0.0.1", Port: busyPort}
+	cfg := testConfig()
+	cfg.Server.Listeners = []config.ListenerConfig{secure, blocked}
+	config.Set(cfg)
+	load()
+	served := Listeners[0].s
+	servedCN := func() string {
+		conn, err := tls.Dial("tcp", served.listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		if err != nil {
+			t.Fatalf("tls dial: %v", err)
+		}
+		defer conn.Close()
+		return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+	}
+	if cn := servedCN(); cn != "OLD" {
+		t.Fatalf("served certificate %q, want OLD", cn)
+	}
+	if Listeners[1].live {
+		t.Fatal("listener on a busy port reported live")
+	}
+
+	writeCertFiles(t, dir, genLeaf(t, ca, caKey, "NEW"))
+	_ = busy.Close()
+	load()
+	if ListenersSnapshot()[0].s != served {
+		t.Fatal("certificate renewal restarted the listener")
+	}
+	if cn := servedCN(); cn != "NEW" {
+		t.Errorf("served certificate %q after reload, want NEW", cn)
+	}
+	if l := ListenersSnapshot()[1]; !l.live {
+		t.Fatal("listener whose port was busy not retried")
+	}
+	conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%d", busyPort))
+	if err != nil {
+		t.Fatalf("retried listener not accepting: %v", err)
+	}
+	_ = conn.Close()
+}
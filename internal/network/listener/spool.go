@@ -0,0 +1,407 @@
+package listener
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/APRSCN/aprsgo/internal/infra/logger"
+	"github.com/APRSCN/aprsgo/internal/network/uplink"
+	"github.com/APRSCN/aprsutils/parser"
+	"go.uber.org/zap"
+)
+
+const (
+	// spoolRetention is how long an undelivered message is kept waiting for
+	// its addressee.
+	spoolRetention = 6 * time.Hour
+	// spoolPerAddressee bounds the queue for a single addressee; the oldest
+	// message is dropped when it is exceeded.
+	spoolPerAddressee = 16
+	// spoolMax bounds the total number of spooled messages.
+	spoolMax = 4096
+	// spoolRetryInterval is the minimum gap between two redeliveries of a
+	// numbered message that has not been acknowledged yet.
+	spoolRetryInterval = 5 * time.Minute
+	// spoolMaxAttempts is how many times a numbered message is redelivered
+	// before it is given up on.
+	spoolMaxAttempts = 3
+	// localRetention is how long a station that logged in or was heard here
+	// stays one the spool queues messages for.
+	localRetention = spoolRetention
+)
+
+// spooledMsg is a text message waiting for its addressee.
+type spooledMsg struct {
+	from   string // originator (upper case)
+	msgNo  string // message number ("" = no ack expected)
+	text   string
+	raw    string
+	queued time.Time
+	last   time.Time // last redelivery (zero = never)
+	tries  int
+}
+
+// messageSpool is the store-and-forward queue for text messages whose
+// addressee, a station local clients logged in as or heard recently, was not
+// reachable through any connected client when the message passed through the
+// distribution stream. Messages are keyed by addressee and
+// redelivered when the addressee logs in or is heard by a client. Numbered
+// messages stay queued until acked/rejected (or attempts run out); messages
+// without a number are delivered once. It is safe for concurrent use.
+type messageSpool struct {
+	mu sync.Mutex
+	d  map[string][]*spooledMsg
+	n  int
+}
+
+// newMessageSpool creates an empty spool.
+func newMessageSpool() *messageSpool {
+	return &messageSpool{d: make(map[string][]*spooledMsg)}
+}
+
+// spool is the process-wide message spool.
+var spool = newMessageSpool()
+
+// SpooledMessages returns the number of messages waiting for delivery.
+func SpooledMessages() int { return spool.Len() }
+
+// runSpool feeds the spool from the distribution stream for the lifetime of
+// the process and periodically expires stale messages.
+func runSpool() {
+	ch, _ := uplink.Stream.Subscribe()
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case data, ok := <-ch:
+			if !ok {
+				return
+			}
+			if data.Dupe {
+				continue
+			}
+			spool.observe(data.Data, stations.where)
+		case <-ticker.C:
+			spool.cleanup()
+			stations.cleanup()
+		}
+	}
+}
+
+// addresseeState is where the addressee of a message is, as far as the spool
+// is concerned.
+type addresseeState int
+
+const (
+	addresseeRemote  addresseeState = iota // not seen here: elsewhere on APRS-IS
+	addresseeOffline                       // seen here recently, not reachable now
+	addresseeOnline                        // a connected client will get the message
+)
+
+// stationIndex tracks the calls clients are logged in as and the stations
+// they heard, so the spool can place an addressee without locking every
+// client. It is safe for concurrent use.
+type stationIndex struct {
+	mu     sync.Mutex
+	online map[string]int                          // logged-in sessions by call
+	heard  map[string]map[*TCPAPRSClient]time.Time // clients that heard a call
+	seen   map[string]time.Time                    // last login, logout or hearing
+}
+
+// newStationIndex creates an empty index.
+func newStationIndex() *stationIndex {
+	return &stationIndex{
+		online: make(map[string]int),
+		heard:  make(map[string]map[*TCPAPRSClient]time.Time),
+		seen:   make(map[string]time.Time),
+	}
+}
+
+// stations is the process-wide station index.
+var stations = newStationIndex()
+
+// login records a session logged in as call.
+func (x *stationIndex) login(call string) {
+	call = strings.ToUpper(call)
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.online[call]++
+	x.seen[call] = time.Now()
+}
+
+// logout records the end of a session logged in as call.
+func (x *stationIndex) logout(call string) {
+	call = strings.ToUpper(call)
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.online[call]--; x.online[call] <= 0 {
+		delete(x.online, call)
+	}
+	x.seen[call] = time.Now()
+}
+
+// heardBy records that client c heard call at t.
+func (x *stationIndex) heardBy(call string, c *TCPAPRSClient, t time.Time) {
+	call = strings.ToUpper(call)
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	m := x.heard[call]
+	if m == nil {
+		m = make(map[*TCPAPRSClient]time.Time)
+		x.heard[call] = m
+	}
+	m[c] = t
+	if t.After(x.seen[call]) {
+		x.seen[call] = t
+	}
+}
+
+// forget drops what client c heard, when it leaves or starts its heard list
+// over.
+func (x *stationIndex) forget(c *TCPAPRSClient) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for call, m := range x.heard {
+		delete(m, c)
+		if len(m) == 0 {
+			delete(x.heard, call)
+		}
+	}
+}
+
+// where places call: online when a client is logged in as it or heard it
+// within heardRetention (so messageRouted delivers to it), offline when it
+// was seen within localRetention, remote otherwise.
+func (x *stationIndex) where(call string) addresseeState {
+	now := time.Now()
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.online[call] > 0 {
+		return addresseeOnline
+	}
+	for _, t := range x.heard[call] {
+		if now.Sub(t) < heardRetention {
+			return addresseeOnline
+		}
+	}
+	if now.Sub(x.seen[call]) < localRetention {
+		return addresseeOffline
+	}
+	return addresseeRemote
+}
+
+// cleanup drops hearings and stations past their retention.
+func (x *stationIndex) cleanup() {
+	now := time.Now()
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for call, m := range x.heard {
+		for c, t := range m {
+			if now.Sub(t) >= heardRetention {
+				delete(m, c)
+			}
+		}
+		if len(m) == 0 {
+			delete(x.heard, call)
+		}
+	}
+	for call, t := range x.seen {
+		if now.Sub(t) >= localRetention && x.online[call] == 0 {
+			delete(x.seen, call)
+		}
+	}
+}
+
+// observe inspects a packet from the distribution stream. An ack/rej (or a
+// reply-ack piggybacked on a message) removes the matching spooled message; a
+// text message to a local station that is offline is queued. Retransmissions of
+// an already queued message replace it instead of queueing a second copy.
+func (s *messageSpool) observe(pkt parser.Parsed, where func(string) addresseeState) {
+	if !pkt.PacketType.Has(parser.TypeMessage) || pkt.Format != "message" {
+		return
+	}
+	addr := strings.ToUpper(strings.TrimSpace(pkt.Addressee))
+	from := strings.ToUpper(strings.TrimSpace(pkt.From))
+	if addr == "" || from == "" {
+		return
+	}
+
+	// An ack/rej from the addressee settles the original message; so does a
+	// reply-ack carried on a message or an ack.
+	if pkt.Response == "ack" || pkt.Response == "rej" {
+		s.settle(from, addr, pkt.MsgNo)
+		if pkt.AckMsgNo != "" {
+			s.settle(from, addr, pkt.AckMsgNo)
+		}
+		return
+	}
+	if pkt.AckMsgNo != "" {
+		s.settle(from, addr, pkt.AckMsgNo)
+	}
+
+	if where(addr) != addresseeOffline {
+		return
+	}
+	s.enqueue(addr, &spooledMsg{
+		from:   from,
+		msgNo:  pkt.MsgNo,
+		text:   pkt.MessageText,
+		raw:    pkt.Raw,
+		queued: time.Now(),
+	})
+}
+
+// enqueue adds m to addr's queue, replacing a queued retransmission of the
+// same message.
+func (s *messageSpool) enqueue(addr string, m *spooledMsg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q := s.d[addr]
+	for i, old := range q {
+		if old.from == m.from && old.msgNo == m.msgNo && (m.msgNo != "" || old.text == m.text) {
+			m.queued, m.last, m.tries = old.queued, old.last, old.tries
+			q[i] = m
+			return
+		}
+	}
+	if len(q) >= spoolPerAddressee {
+		// The addressee's oldest message makes room; the total is unchanged.
+		q = append(q[:0], q[1:]...)
+		s.d[addr] = append(q, m)
+		return
+	}
+	if s.n >= spoolMax {
+		logger.L.Debug("Message spool full, dropping message",
+			zap.String("from", m.from), zap.String("to", addr))
+		return
+	}
+	s.d[addr] = append(q, m)
+	s.n++
+}
+
+// settle removes the message numbered msgNo once its addressee has acked or
+// rejected it. ackFrom is the station sending the ack (the original addressee)
+// and ackTo the original sender.
+func (s *messageSpool) settle(ackFrom, ackTo, msgNo string) {
+	if msgNo == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q := s.d[ackFrom]
+	for i, m := range q {
+		if m.from == ackTo && m.msgNo == msgNo {
+			s.removeLocked(ackFrom, i)
+			return
+		}
+	}
+}
+
+// removeLocked drops the i-th message queued for addr. The caller must hold
+// s.mu.
+func (s *messageSpool) removeLocked(addr string, i int) {
+	q := append(s.d[addr][:i], s.d[addr][i+1:]...)
+	s.n--
+	if len(q) == 0 {
+		delete(s.d, addr)
+		return
+	}
+	s.d[addr] = q
+}
+
+// due takes the messages for addr that may be (re)delivered now. Messages
+// without a number, and numbered ones on their final attempt, leave the
+// spool; the others stay queued for a retry until acked.
+func (s *messageSpool) due(addr string) []*spooledMsg {
+	addr = strings.ToUpper(strings.TrimSpace(addr))
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	q, ok := s.d[addr]
+	if !ok {
+		return nil
+	}
+	var out []*spooledMsg
+	kept := q[:0]
+	for _, m := range q {
+		if now.Sub(m.queued) > spoolRetention {
+			s.n--
+			continue
+		}
+		if m.tries > 0 && now.Sub(m.last) < spoolRetryInterval {
+			kept = append(kept, m)
+			continue
+		}
+		out = append(out, m)
+		m.tries++
+		m.last = now
+		if m.msgNo == "" || m.tries >= spoolMaxAttempts {
+			s.n--
+			continue
+		}
+		kept = append(kept, m)
+	}
+	if len(kept) == 0 {
+		delete(s.d, addr)
+	} else {
+		s.d[addr] = kept
+	}
+	return out
+}
+
+// deliver sends the messages spooled for call to client c, which has just
+// logged in as call or heard it. Delivered messages count towards MsgRcpts
+// and make their originators eligible for a courtesy position, as with a
+// live routed message. It returns the number of messages sent.
+func (s *messageSpool) deliver(c *TCPAPRSClient, call string) int {
+	msgs := s.due(call)
+	sent := 0
+	for _, m := range msgs {
+		if err := c.Send(m.raw); err != nil {
+			continue
+		}
+		sent++
+		c.stats.AddSentPackets(1)
+		c.msgRcpts.Add(1)
+		if c.courtesy != nil {
+			c.courtesy.Add(m.from)
+		}
+	}
+	if len(msgs) > 0 {
+		logger.L.Debug("Delivered spooled messages",
+			zap.String("to", call), zap.Int("count", len(msgs)))
+	}
+	return sent
+}
+
+// cleanup drops messages held longer than spoolRetention.
+func (s *messageSpool) cleanup() {
+	cutoff := time.Now().Add(-spoolRetention)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for addr, q := range s.d {
+		kept := q[:0]
+		for _, m := range q {
+			if m.queued.Before(cutoff) {
+				s.n--
+				continue
+			}
+			kept = append(kept, m)
+		}
+		if len(kept) == 0 {
+			delete(s.d, addr)
+		} else {
+			s.d[addr] = kept
+		}
+	}
+}
+
+// Len returns the number of spooled messages.
+func (s *messageSpool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.n
+}
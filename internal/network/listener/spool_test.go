@@ -0,0 +1,239 @@
+package listener
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/APRSCN/aprsgo/internal/infra/config"
+	"github.com/APRSCN/aprsgo/internal/infra/logger"
+	"github.com/APRSCN/aprsgo/internal/model"
+	"github.com/APRSCN/aprsgo/internal/network/uplink"
+	"github.com/APRSCN/aprsgo/internal/pkg/historydb"
+	"github.com/APRSCN/aprsutils"
+	"github.com/APRSCN/aprsutils/client"
+	"go.uber.org/zap"
+)
+
+// unreachable is a reachability probe that reports every addressee offline.
+func unreachable(string) addresseeState { return addresseeOffline }
+
+// TestSpoolQueueAndAck verifies that a numbered message to an offline station
+// is queued once (retransmissions replace it), redelivered on demand and
+// removed by the addressee's ack.
+func TestSpoolQueueAndAck(t *testing.T) {
+	s := newMessageSpool()
+	msg := parsePkt(t, "SENDER>APRS,TCPIP*::DEST     :hello{42")
+
+	s.observe(msg, unreachable)
+	s.observe(msg, unreachable) // retransmission
+	if s.Len() != 1 {
+		t.Fatalf("spooled = %d, want 1", s.Len())
+	}
+
+	got := s.due("dest")
+	if len(got) != 1 || got[0].raw != msg.Raw {
+		t.Fatalf("due = %v, want the queued message", got)
+	}
+	// Numbered: kept for a retry, but not due again before the interval.
+	if s.Len() != 1 || len(s.due("DEST")) != 0 {
+		t.Fatalf("numbered message should stay queued and not be due yet")
+	}
+
+	s.observe(parsePkt(t, "DEST>APRS,TCPIP*::SENDER   :ack42"), unreachable)
+	if s.Len() != 0 {
+		t.Fatalf("spooled after ack = %d, want 0", s.Len())
+	}
+}
+
+// TestSpoolReplyAckAndRej verifies that a rej and a reply-ack piggybacked on a
+// message both settle the matching spooled message.
+func TestSpoolReplyAckAndRej(t *testing.T) {
+	s := newMessageSpool()
+	s.observe(parsePkt(t, "SENDER>APRS::DEST     :one{AA}"), unreachable)
+	s.observe(parsePkt(t, "SENDER>APRS::DEST     :two{BB}"), unreachable)
+	if s.Len() != 2 {
+		t.Fatalf("spooled = %d, want 2", s.Len())
+	}
+
+	// The reply is itself spooled for SENDER (also offline); it acks AA.
+	s.observe(parsePkt(t, "DEST>APRS::SENDER   :reply{CC}AA"), unreachable)
+	s.observe(parsePkt(t, "DEST>APRS::SENDER   :rejBB"), unreachable)
+	if got := s.due("DEST"); len(got) != 0 {
+		t.Fatalf("DEST still has %d spooled messages", len(got))
+	}
+	if got := s.due("SENDER"); len(got) != 1 || !strings.Contains(got[0].raw, "reply") {
+		t.Fatalf("SENDER spool = %v, want the reply", got)
+	}
+}
+
+// TestSpoolReachableAndUnnumbered verifies that messages to reachable stations
+// are not queued and that unnumbered messages are delivered only once.
+func TestSpoolReachableAndUnnumbered(t *testing.T) {
+	s := newMessageSpool()
+	s.observe(parsePkt(t, "SENDER>APRS::DEST     :online"), func(string) addresseeState { return addresseeOnline })
+	if s.Len() != 0 {
+		t.Fatalf("message to reachable station was spooled")
+	}
+
+	s.observe(parsePkt(t, "SENDER>APRS::DEST     :no number"), unreachable)
+	if len(s.due("DEST")) != 1 || s.Len() != 0 {
+		t.Fatalf("unnumbered message should be delivered once and dropped")
+	}
+}
+
+// TestSpoolDeliver verifies delivery to a client: the line is queued for the
+// socket, MsgRcpts is counted and the originator is remembered for a courtesy
+// position.
+func TestSpoolDeliver(t *testing.T) {
+	logger.L = zap.NewNop()
+	s := newMessageSpool()
+	s.observe(parsePkt(t, "SENDER>APRS::DEST     :hello{1"), unreachable)
+
+	c := &TCPAPRSClient{
+		server:   &TCPAPRSServer{stats: new(model.Counters)},
+		courtesy: historydb.NewHeardList(),
+		sendCh:   make(chan []byte, 4),
+		stats:    new(model.Counters),
+	}
+	s.deliver(c, "DEST")
+
+	select {
+	case line := <-c.sendCh:
+		if !strings.HasPrefix(string(line), "SENDER>APRS::DEST") {
+			t.Fatalf("delivered %q", line)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("nothing delivered")
+	}
+	if c.msgRcpts.Load() != 1 {
+		t.Fatalf("msgRcpts = %d, want 1", c.msgRcpts.Load())
+	}
+	if !c.courtesy.Heard("SENDER") {
+		t.Fatal("originator not remembered for a courtesy position")
+	}
+}
+
+// TestSpoolExpiry verifies that cleanup drops messages past the retention.
+func TestSpoolExpiry(t *testing.T) {
+	s := newMessageSpool()
+	s.observe(parsePkt(t, "SENDER>APRS::DEST     :old{7"), unreachable)
+	s.d["DEST"][0].queued = time.Now().Add(-spoolRetention - time.Minute)
+	s.cleanup()
+	if s.Len() != 0 || len(s.d) != 0 {
+		t.Fatalf("expired message survived cleanup")
+	}
+}
+
+// TestSpoolFull verifies the caps: at spoolMax a message to a new addressee is
+// dropped, while one to an addressee with a full queue replaces its oldest
+// message, and the count always matches the queues.
+func TestSpoolFull(t *testing.T) {
+	logger.L = zap.NewNop()
+	s := newMessageSpool()
+	for i := 0; s.Len() < spoolMax; i++ {
+		addr := fmt.Sprintf("DEST%d", i/spoolPerAddressee)
+		s.enqueue(addr, &spooledMsg{from: "SENDER", msgNo: strconv.Itoa(i)})
+	}
+
+	s.enqueue("NEWDEST", &spooledMsg{from: "SENDER", msgNo: "new"})
+	if s.Len() != spoolMax || len(s.d["NEWDEST"]) != 0 {
+		t.Fatalf("spooled = %d (NEWDEST %d), want %d and none for NEWDEST",
+			s.Len(), len(s.d["NEWDEST"]), spoolMax)
+	}
+
+	s.enqueue("DEST0", &spooledMsg{from: "SENDER", msgNo: "latest"})
+	q := s.d["DEST0"]
+	if len(q) != spoolPerAddressee || q[0].msgNo != "1" || q[len(q)-1].msgNo != "latest" {
+		t.Fatalf("DEST0 queue = %d messages from %q to %q, want %d from \"1\" to \"latest\"",
+			len(q), q[0].msgNo, q[len(q)-1].msgNo, spoolPerAddressee)
+	}
+	total := 0
+	for _, q := range s.d {
+		total += len(q)
+	}
+	if s.Len() != spoolMax || total != spoolMax {
+		t.Fatalf("spooled = %d, queues hold %d, want %d", s.Len(), total, spoolMax)
+	}
+}
+
+// TestSpoolUnverifiedLogin verifies that only a verified login collects the
+// messages spooled for its callsign.
+func TestSpoolUnverifiedLogin(t *testing.T) {
+	logger.L = zap.NewNop()
+	config.Set(testConfig())
+	uplink.Stream = uplink.NewDataStream(10)
+	spool = newMessageSpool()
+	spool.enqueue("N0CALL", &spooledMsg{from: "SENDER", text: "private", raw: "SENDER>APRS::N0CALL   :private", queued: time.Now()})
+
+	srv, addr := startTestTCPServer(t, client.Fullfeed)
+	defer srv.Stop()
+
+	for _, pass := range []int{1, aprsutils.Passcode("N0CALL")} {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		r := bufio.NewReader(conn)
+		_ = readLine(t, r, conn) // greeting
+		fmt.Fprintf(conn, "user N0CALL pass %d vers test 1.0\r\n", pass)
+		resp := readLine(t, r, conn)
+		if verified := !strings.Contains(resp, "unverified"); verified {
+			if line := readLine(t, r, conn); !strings.Contains(line, "private") {
+				t.Errorf("verified login got %q, want the spooled message", line)
+			}
+		} else {
+			_ = conn.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+			if line, _ := r.ReadString('\n'); strings.Contains(line, "private") || spool.Len() != 1 {
+				t.Error("spooled message handed to an unverified login")
+			}
+		}
+		_ = conn.Close()
+	}
+	if spool.Len() != 0 {
+		t.Errorf("spooled = %d after the verified login, want 0", spool.Len())
+	}
+}
+
+// TestStationIndex verifies where the index places an addressee as its
+// station logs in, is heard and leaves, and that messages to stations never
+// seen here are not spooled.
+func TestStationIndex(t *testing.T) {
+	x := newStationIndex()
+	c := &TCPAPRSClient{}
+	steps := []struct {
+		name string
+		do   func()
+		call string
+		want addresseeState
+	}{
+		{"never seen", func() {}, "DEST", addresseeRemote},
+		{"logged in", func() { x.login("dest") }, "DEST", addresseeOnline},
+		{"logged out", func() { x.logout("DEST") }, "DEST", addresseeOffline},
+		{"heard", func() { x.heardBy("other", c, time.Now()) }, "OTHER", addresseeOnline},
+		{"hearing client gone", func() { x.forget(c) }, "OTHER", addresseeOffline},
+		{"heard long ago", func() { x.heardBy("OLD", c, time.Now().Add(-localRetention)) }, "OLD", addresseeRemote},
+	}
+	for _, st := range steps {
+		st.do()
+		if got := x.where(st.call); got != st.want {
+			t.Errorf("%s: where(%s) = %d, want %d", st.name, st.call, got, st.want)
+		}
+	}
+
+	s := newMessageSpool()
+	s.observe(parsePkt(t, "SENDER>APRS::FARAWAY  :hello{1"), x.where)
+	s.observe(parsePkt(t, "SENDER>APRS::DEST     :hello{2"), x.where)
+	if s.Len() != 1 || len(s.d["DEST"]) != 1 {
+		t.Errorf("spooled %v, want only the message to DEST", s.d)
+	}
+
+	x.cleanup()
+	if _, ok := x.seen["OLD"]; ok || len(x.heard) != 0 {
+		t.Errorf("cleanup kept OLD or hearings: seen %v, heard %v", x.seen, x.heard)
+	}
+}
@@ -3,11 +3,9 @@ package listener
 import (
 	"errors"
 	"strings"
-	"time"
 
 	"github.com/APRSCN/aprsgo/internal/infra/config"
 	"github.com/APRSCN/aprsgo/internal/network/uplink"
-	"github.com/APRSCN/aprsgo/internal/pkg/historydb"
 	"github.com/APRSCN/aprsgo/internal/security"
 	"github.com/APRSCN/aprsutils"
 	"github.com/APRSCN/aprsutils/parser"
@@ -23,14 +21,6 @@ var (
 	ErrSubmitTooShort  = errors.New("packet too short")
 )
 
-// submitDedup is the shared duplicate-suppression window for connectionless
-// submit endpoints (UDP/HTTP). TCP clients keep their own per-connection store.
-var submitDedup = historydb.NewDupeChecker(30 * time.Second)
-
-// SweepSubmitDedup prunes expired entries from the shared submit duplicate
-// checker. It is intended to be called periodically (e.g. from cron).
-func SweepSubmitDedup() { submitDedup.Cleanup() }
-
 // SubmitSource identifies how a submitted packet arrived, which selects the
 // q-construct connection type (and therefore the qAU/qAC injected by the
 // server).
@@ -55,11 +45,6 @@ func ProcessSubmit(callsign string, verified bool, packet string, src SubmitSour
 		return ErrSubmitTooShort
 	}
 
-	// Duplicate suppression (shared 30s window).
-	if submitDedup.Seen(packet) {
-		return ErrSubmitDuplicate
-	}
-
 	// Initial parse (lenient on toCall, like the TCP path).
 	parsed, _ := parser.Parse(packet, parser.WithDisableToCallsignValidate())
 	if parsed.To == "" {
@@ -101,7 +86,9 @@ func ProcessSubmit(callsign string, verified bool, packet string, src SubmitSour
 	if err != nil {
 		return ErrSubmitParse
 	}
-	uplink.Stream.Write(parsed, callsign)
+	if !uplink.Stream.Ingest(parsed, callsign) {
+		return ErrSubmitDuplicate
+	}
 	return nil
 }
 
@@ -196,6 +183,9 @@ func SubmitEnvelope(payload string, src SubmitSource) (SubmitEnvelopeResult, err
 	if !verified {
 		return res, errors.New("invalid passcode")
 	}
+	if !security.LoginAllowed(call) {
+		return res, errors.New("login not allowed")
+	}
 	if len(packets) == 0 {
 		return res, errors.New("no packet data found")
 	}
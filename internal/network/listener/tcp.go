@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"net/netip"
 	"os"
 	"strconv"
 	"strings"
@@ -20,7 +21,9 @@ import (
 	"github.com/APRSCN/aprsgo/internal/meta"
 	"github.com/APRSCN/aprsgo/internal/model"
 	"github.com/APRSCN/aprsgo/internal/network/uplink"
+	"github.com/APRSCN/aprsgo/internal/pkg/acl"
 	"github.com/APRSCN/aprsgo/internal/pkg/historydb"
+	"github.com/APRSCN/aprsgo/internal/pkg/ratelimit"
 	"github.com/APRSCN/aprsgo/internal/security"
 	"github.com/APRSCN/aprsgo/internal/upgrade"
 	"github.com/APRSCN/aprsutils"
@@ -101,6 +104,12 @@ type TCPAPRSClient struct {
 	dataCh      <-chan uplink.StreamData
 
 	// Client identification and status
+	// id uniquely identifies the connection (the session id of the admin
+	// API); it is assigned once and never changes.
+	id uint64
+	// ip is the remote address the client is counted under for the
+	// per-address cap (invalid when it could not be parsed).
+	ip         netip.Addr
 	callSign   string
 	verified   bool
 	loggedIn   bool
@@ -115,9 +124,8 @@ type TCPAPRSClient struct {
 	// runtime "#filter" command).
 	compiledFilter *filter.Filter
 
-	// Server reference and duplicate checking
+	// Server reference
 	server *TCPAPRSServer
-	dup    *historydb.DupeChecker
 
 	// heard is the set of distinct source callsigns this client has received,
 	// each with a last-heard timestamp. It drives message routing (deliver a
@@ -131,10 +139,31 @@ type TCPAPRSClient struct {
 	// client sees where the correspondent is, then the entry is consumed.
 	courtesy *historydb.HeardList
 
+	// igateAssist is set by "#igate on": the heard list then only records
+	// stations gated from RF and the client receives just what it needs for
+	// IS-to-RF gating. gatedMessages and gatedCourtesy count what it was sent.
+	igateAssist   atomic.Bool
+	gatedMessages atomic.Uint64
+	gatedCourtesy atomic.Uint64
+
 	// dupefeed marks a client connected to a dupefeed port: it additionally
 	// receives packets that were detected as duplicates.
 	dupefeed bool
 
+	// readOnly is set by an operator through the admin API: everything the
+	// client sends is dropped, while it keeps receiving traffic.
+	readOnly atomic.Bool
+
+	// handingOff is set when the session is being handed to a new process in
+	// a live upgrade: the reader stops and reports the input it had buffered
+	// on handoffCh.
+	handingOff atomic.Bool
+	handoffCh  chan string
+
+	// limiter caps the packets and bytes per second the client may inject
+	// (nil = unlimited).
+	limiter *ratelimit.Limiter
+
 	// Asynchronous output queue. Send enqueues onto sendCh; writeLoop drains it
 	// to the socket. outQBytes tracks the number of bytes currently queued
 	// (reported as OutQ). This decouples the broadcast goroutine from slow
@@ -365,7 +394,11 @@ func (c *TCPAPRSClient) handleUplinkData() {
 			_ = c.Send(data.Data.Raw)
 			c.stats.AddSentPackets(1)
 		case client.IGate:
-			if c.shouldDeliver(snap, data.Data) {
+			deliver := c.shouldDeliver
+			if c.igateAssist.Load() {
+				deliver = c.shouldGate
+			}
+			if deliver(snap, data.Data) {
 				_ = c.Send(data.Data.Raw)
 				c.stats.AddSentPackets(1)
 			}
@@ -444,7 +477,7 @@ func (c *TCPAPRSClient) passesFilter(snap deliverState, pkt parser.Parsed) bool
 	ctx := newFilterContext(snap.callSign)
 
 	if c.server != nil {
-		if l := listenerAt(c.server.index); l != nil {
+		if l := listenerAt(&c.server.index); l != nil {
 			if lf := l.compiledFilter; lf != nil {
 				return lf.Match(&pkt, ctx)
 			}
@@ -472,31 +505,46 @@ func (c *TCPAPRSClient) setFilter(f string) {
 type TCPAPRSServer struct {
 	// Server connection and management
 	listener net.Listener
-	clients  map[*TCPAPRSClient]bool
+	// clients maps each registered client to whether it is counted in perIP.
+	clients map[*TCPAPRSClient]bool
+	// perIP counts the registered clients per remote address (exempt
+	// addresses are not counted).
+	perIP    map[netip.Addr]int
 	mu       sync.RWMutex
 	stopChan chan struct{}
 	wg       sync.WaitGroup
 
 	// Server configuration
-	mode      client.Mode
-	index     int
+	mode client.Mode
+	// index is the server's position in Listeners; a reload may renumber it.
+	index     atomic.Int64
 	tlsConfig *tls.Config                             // non-nil to serve TLS
+	tlsCert   atomic.Pointer[tls.Config]              // current certificate and client CA
+	sctp      bool                                    // serving SCTP instead of TCP
+	addr      string                                  // listen address, as passed to Start
 	listenFn  func(addr string) (net.Listener, error) // listener factory (TCP by default)
 
+	// limits holds one rate limiter per source IP, on top of each client's
+	// own, so a host cannot multiply its allowance by opening connections.
+	limits *ratelimit.Keyed
+
 	// Statistics (atomic counters)
 	stats *model.Counters
 }
 
 // NewTCPAPRSServer creates a new APRS server
 func NewTCPAPRSServer(mode client.Mode, index int) *TCPAPRSServer {
-	return &TCPAPRSServer{
+	s := &TCPAPRSServer{
 		clients:  make(map[*TCPAPRSClient]bool),
+		perIP:    make(map[netip.Addr]int),
 		stopChan: make(chan struct{}),
 		mode:     mode,
+		limits:   ratelimit.NewKeyed(),
 		stats:    new(model.Counters),
-		index:    index,
 		listenFn: func(addr string) (net.Listener, error) { return upgrade.ListenTCP(addr) },
 	}
+	s.index.Store(int64(index))
+	return s
 }
 
 // SetSCTP switches the server to listen on SCTP instead of TCP. Returns an
@@ -506,19 +554,39 @@ func (s *TCPAPRSServer) SetSCTP() error {
 		return errSCTPUnsupported
 	}
 	s.listenFn = listenSCTP
+	s.sctp = true
 	return nil
 }
 
 // SetTLS configures the server to serve TLS using the given certificate and
 // key PEM files. If clientCA is non-empty, client certificates issued by that
 // CA are requested and verified, enabling certificate-based login (a client
-// may still authenticate by passcode if it presents no certificate). It must
-// be called before Start.
+// may still authenticate by passcode if it presents no certificate). The first
+// call must come before Start; later ones replace the files' contents for new
+// connections, e.g. after the certificate was renewed.
 func (s *TCPAPRSServer) SetTLS(certFile, keyFile, clientCA string) error {
-	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	cfg, err := newTLSConfig(certFile, keyFile, clientCA)
 	if err != nil {
 		return err
 	}
+	s.tlsCert.Store(cfg)
+	if s.tlsConfig == nil {
+		s.tlsConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+				return s.tlsCert.Load(), nil
+			},
+		}
+	}
+	return nil
+}
+
+// newTLSConfig loads a listener's certificate, key and optional client CA.
+func newTLSConfig(certFile, keyFile, clientCA string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
 	cfg := &tls.Config{
 		Certificates: []tls.Certificate{cert},
 		MinVersion:   tls.VersionTLS12,
@@ -526,19 +594,18 @@ func (s *TCPAPRSServer) SetTLS(certFile, keyFile, clientCA string) error {
 	if clientCA != "" {
 		pem, err := os.ReadFile(clientCA)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		pool := x509.NewCertPool()
 		if !pool.AppendCertsFromPEM(pem) {
-			return fmt.Errorf("no certificates found in client CA file %q", clientCA)
+			return nil, fmt.Errorf("no certificates found in client CA file %q", clientCA)
 		}
 		cfg.ClientCAs = pool
 		// Verify a certificate when presented, but do not require one: clients
 		// without a certificate fall back to passcode authentication.
 		cfg.ClientAuth = tls.VerifyClientCertIfGiven
 	}
-	s.tlsConfig = cfg
-	return nil
+	return cfg, nil
 }
 
 // Start an APRS server
@@ -548,6 +615,7 @@ func (s *TCPAPRSServer) Start(addr string) error {
 	if err != nil {
 		return err
 	}
+	s.addr = addr
 
 	// Wrap in TLS if configured.
 	if s.tlsConfig != nil {
@@ -564,12 +632,20 @@ func (s *TCPAPRSServer) Start(addr string) error {
 	s.wg.Add(1)
 	go s.handleServer()
 
+	// Resume the client sessions handed over by a live upgrade.
+	s.resumeSessions()
+
 	return nil
 }
 
-// Stop an APRS server and wait for its goroutines to drain.
+// Stop an APRS server and wait for its goroutines to drain. Stopping an
+// already stopped server is a no-op.
 func (s *TCPAPRSServer) Stop() {
-	close(s.stopChan)
+	select {
+	case <-s.stopChan:
+	default:
+		close(s.stopChan)
+	}
 	if s.listener != nil {
 		_ = s.listener.Close()
 	}
@@ -609,50 +685,94 @@ func (s *TCPAPRSServer) handleServer() {
 // enforce the global max_clients cap.
 var globalClients atomic.Int64
 
+// nextClientID hands out client session ids.
+var nextClientID atomic.Uint64
+
+// Connection limit errors returned by register.
+var (
+	errServerFull = errors.New("client limit reached")
+	errPerIPLimit = errors.New("per-address client limit reached")
+)
+
 // register adds a client to the server and updates online/peak counts. It
-// returns false if a connection limit (per-listener or global) is exceeded, in
-// which case the caller must reject the connection.
-func (s *TCPAPRSServer) register(c *TCPAPRSClient) bool {
+// returns an error if a connection limit (global, per-listener or
+// per-address) is exceeded, in which case the caller must reject the
+// connection.
+func (s *TCPAPRSServer) register(c *TCPAPRSClient) error {
 	// Check the global cap first (lock-free).
 	if gm := config.Get().Server.MaxClients; gm > 0 {
 		if globalClients.Load() >= int64(gm) {
-			return false
+			return errServerFull
 		}
 	}
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	l := listenerAt(&s.index)
 	// Per-listener cap.
-	if l := listenerAt(s.index); l != nil && l.maxClients > 0 {
+	if l != nil && l.maxClients > 0 {
 		if len(s.clients) >= l.maxClients {
-			return false
+			return errServerFull
+		}
+	}
+
+	// Per-address cap. Whitelisted addresses are neither checked nor counted.
+	counted := false
+	if l != nil && l.maxClientsPerIP > 0 && c.ip.IsValid() &&
+		(l.perIPExempt == nil || !l.perIPExempt.AllowAddr(c.ip)) {
+		if s.perIP[c.ip] >= l.maxClientsPerIP {
+			return errPerIPLimit
 		}
+		counted = true
 	}
 
-	s.clients[c] = true
+	s.clients[c] = counted
+	if counted {
+		s.perIP[c.ip]++
+	}
 	globalClients.Add(1)
-	if l := listenerAt(s.index); l != nil {
+	if l != nil {
 		l.setOnlineClient(len(s.clients))
 	}
-	return true
+	return nil
 }
 
 // unregister removes a client from the server.
 func (s *TCPAPRSServer) unregister(c *TCPAPRSClient) {
+	c.mu.Lock()
+	call, loggedIn := c.callSign, c.loggedIn
+	c.mu.Unlock()
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	if _, ok := s.clients[c]; ok {
+	if counted, ok := s.clients[c]; ok {
 		delete(s.clients, c)
+		stations.forget(c)
+		if loggedIn {
+			stations.logout(call)
+		}
 		globalClients.Add(-1)
+		if counted {
+			if s.perIP[c.ip]--; s.perIP[c.ip] <= 0 {
+				delete(s.perIP, c.ip)
+			}
+		}
 	}
-	if l := listenerAt(s.index); l != nil {
+	if l := listenerAt(&s.index); l != nil {
 		l.setOnlineClient(len(s.clients))
 	}
 }
 
 // handleClient handles individual client connection
 func (s *TCPAPRSServer) handleClient(conn net.Conn) {
+	s.serveClient(conn, nil)
+}
+
+// serveClient runs a client connection until it ends. resume is the state of
+// a session handed over by a live upgrade (nil for a new connection): the
+// client starts logged in and no welcome banner is sent.
+func (s *TCPAPRSServer) serveClient(conn net.Conn, resume *sessionState) {
 	defer s.wg.Done()
 
 	remoteAddr := conn.RemoteAddr().String()
@@ -662,14 +782,21 @@ func (s *TCPAPRSServer) handleClient(conn net.Conn) {
 		ibuf     = config.Get().Server.BuffSize * 1024
 		obuf     = outQCap
 		dupefeed = false
+		limit    = globalRateLimit()
 	)
-	if l := listenerAt(s.index); l != nil {
+	if l := listenerAt(&s.index); l != nil {
 		// Access-control: reject connections not permitted by the ACL.
 		if !l.acl.Allow(remoteAddr) {
 			logger.L.Info("Connection rejected by ACL", zap.String("remoteAddr", remoteAddr))
 			_ = conn.Close()
 			return
 		}
+		// Runtime IP bans apply on top of the listener ACL.
+		if !security.AddrAllowed(remoteAddr) {
+			logger.L.Info("Connection rejected by ban", zap.String("remoteAddr", remoteAddr))
+			_ = conn.Close()
+			return
+		}
 		if l.ibufBytes > 0 {
 			ibuf = l.ibufBytes
 		}
@@ -681,45 +808,71 @@ func (s *TCPAPRSServer) handleClient(conn net.Conn) {
 			}
 		}
 		dupefeed = l.dupefeed
+		limit = l.rateLimit
 	}
 	if ibuf <= 0 {
 		ibuf = 1024
 	}
 
 	c := &TCPAPRSClient{
+		id:         nextClientID.Add(1),
+		ip:         remoteIP(conn),
 		conn:       conn,
 		uptime:     time.Now(),
 		lastActive: time.Now(),
 		mode:       s.mode,
 		dupefeed:   dupefeed,
+		limiter:    ratelimit.NewLimiter(limit),
 
 		server:   s,
-		dup:      historydb.NewDupeChecker(30 * time.Second),
 		heard:    historydb.NewHeardListTTL(heardRetention),
 		courtesy: historydb.NewHeardListTTL(courtesyRetention),
 		sendCh:   make(chan []byte, obuf),
 
+		handoffCh: make(chan string, 1),
+
 		stats: new(model.Counters),
 	}
+	if resume != nil {
+		c.restore(resume)
+	}
 
 	// Enable TCP keepalive so dead peers are detected even when idle.
 	applyKeepAlive(conn)
 
 	// Enforce connection limits; reject politely when exceeded.
-	if !s.register(c) {
-		logger.L.Info("Connection rejected: client limit reached", zap.String("remoteAddr", remoteAddr))
-		_, _ = conn.Write([]byte("# server full\r\n"))
+	if err := s.register(c); err != nil {
+		logger.L.Info("Connection rejected", zap.String("remoteAddr", remoteAddr), zap.Error(err))
+		msg := "# server full\r\n"
+		if errors.Is(err, errPerIPLimit) {
+			msg = "# too many connections from your address\r\n"
+		}
+		_, _ = conn.Write([]byte(msg))
 		_ = conn.Close()
 		return
 	}
-	logger.L.Info("Client connected", zap.String("remoteAddr", remoteAddr))
+	if resume != nil {
+		stations.login(resume.Callsign)
+		for call, at := range resume.Heard {
+			stations.heardBy(call, c, at)
+		}
+		logger.L.Info("Client session resumed",
+			zap.String("remoteAddr", remoteAddr), zap.String("callsign", resume.Callsign))
+	} else {
+		logger.L.Info("Client connected", zap.String("remoteAddr", remoteAddr))
+	}
 
 	defer func() {
 		s.unregister(c)
 		// Close() stops the heartbeat, the writer goroutine and the connection.
+		// A handed-off socket stays open in the new process.
 		c.Close()
 
-		logger.L.Info("Client disconnected",
+		msg := "Client disconnected"
+		if c.handingOff.Load() {
+			msg = "Client session handed off"
+		}
+		logger.L.Info(msg,
 			zap.String("remoteAddr", remoteAddr),
 			zap.String("callsign", c.callSign))
 	}()
@@ -729,7 +882,9 @@ func (s *TCPAPRSServer) handleClient(conn net.Conn) {
 	c.startHeartbeat()
 
 	// Send welcome message
-	_ = c.Send(fmt.Sprintf("# %s %s/%s", meta.ENName, meta.Version, meta.Nickname))
+	if resume == nil {
+		_ = c.Send(fmt.Sprintf("# %s %s/%s", meta.ENName, meta.Version, meta.Nickname))
+	}
 
 	// Subscribe to data stream for this client
 	c.dataCh, c.unsubscribe = uplink.Stream.Subscribe()
@@ -738,7 +893,14 @@ func (s *TCPAPRSServer) handleClient(conn net.Conn) {
 	loginTimeout := loginTimeoutDur()
 	clientTimeout := clientTimeoutDur()
 	lineCount := 0
-	reader := bufio.NewReaderSize(conn, ibuf)
+	var input io.Reader = conn
+	if resume != nil {
+		// A resumed session is past its first line; replay the input the old
+		// process had read but not processed.
+		lineCount = 1
+		input = io.MultiReader(strings.NewReader(resume.Pending), conn)
+	}
+	reader := bufio.NewReaderSize(input, ibuf)
 	for {
 		lineCount++
 
@@ -752,6 +914,13 @@ func (s *TCPAPRSServer) handleClient(conn net.Conn) {
 		}
 		_ = conn.SetReadDeadline(time.Now().Add(readDeadline))
 
+		// Checked after arming the deadline, so a handoff that interrupts the
+		// read with an expired deadline cannot be overridden by it.
+		if c.handingOff.Load() {
+			c.handoffCh <- pendingInput("", reader)
+			return
+		}
+
 		// Disconnect clients that never log in.
 		if time.Since(c.uptime) > loginTimeout && !c.loggedIn {
 			return
@@ -760,6 +929,10 @@ func (s *TCPAPRSServer) handleClient(conn net.Conn) {
 		// Read data from client
 		line, err := reader.ReadString('\n')
 		if err != nil {
+			if c.handingOff.Load() {
+				c.handoffCh <- pendingInput(line, reader)
+				return
+			}
 			var netErr net.Error
 			switch {
 			case errors.As(err, &netErr) && netErr.Timeout():
@@ -843,6 +1016,16 @@ func isNonAPRSProbe(raw string) bool {
 	return strings.Contains(raw, "HTTP/")
 }
 
+// remoteIP returns the unmapped remote address of conn (invalid when it cannot
+// be parsed, e.g. for a non-IP transport).
+func remoteIP(conn net.Conn) netip.Addr {
+	ap, err := netip.ParseAddrPort(conn.RemoteAddr().String())
+	if err != nil {
+		return netip.Addr{}
+	}
+	return ap.Addr().Unmap()
+}
+
 // tcpConnOf returns the underlying *net.TCPConn for a connection, unwrapping a
 // TLS wrapper if present. It returns nil for non-TCP transports (e.g. SCTP).
 func tcpConnOf(conn net.Conn) *net.TCPConn {
@@ -917,6 +1100,56 @@ func (s *TCPAPRSServer) kickOld(client *TCPAPRSClient, callsign string) {
 	}
 }
 
+// setRateLimit applies a reloaded rate limit to the connected clients.
+func (s *TCPAPRSServer) setRateLimit(lim ratelimit.Limits) {
+	s.mu.RLock()
+	clients := make([]*TCPAPRSClient, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mu.RUnlock()
+
+	for _, c := range clients {
+		c.mu.Lock()
+		if c.limiter == nil {
+			c.limiter = ratelimit.NewLimiter(lim)
+		} else {
+			c.limiter.SetLimits(lim)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// kickDenied disconnects the clients whose address list (the listener ACL
+// after a reload) no longer allows.
+func (s *TCPAPRSServer) kickDenied(list *acl.List) {
+	if list == nil {
+		return
+	}
+	s.mu.RLock()
+	clients := make([]*TCPAPRSClient, 0, len(s.clients))
+	for c := range s.clients {
+		clients = append(clients, c)
+	}
+	s.mu.RUnlock()
+
+	for _, c := range clients {
+		c.mu.Lock()
+		conn, call := c.conn, c.callSign
+		c.mu.Unlock()
+		if conn == nil {
+			continue // already closed
+		}
+		addr := conn.RemoteAddr().String()
+		if list.Allow(addr) {
+			continue
+		}
+		logger.L.Info("Kicking client denied by the new ACL",
+			zap.String("remoteAddr", addr), zap.String("callsign", call))
+		c.Close()
+	}
+}
+
 // processPacket processes received packet from client
 func (s *TCPAPRSServer) processPacket(c *TCPAPRSClient, packet string) {
 	switch {
@@ -1010,10 +1243,17 @@ func (s *TCPAPRSServer) handleLogin(client *TCPAPRSClient, packet string) {
 	}
 	client.loggedIn = true
 	client.mu.Unlock()
+	stations.login(callSign)
 
 	// Disconnect old clients with the same callsign (after the callsign is
 	// published so kickOld sees it and skips this connection).
 	s.kickOld(client, callSign)
+
+	// Hand over any messages spooled while the station was offline. Only a
+	// verified login proves the callsign, so an unverified one gets none.
+	if byPass || byCert {
+		spool.deliver(client, callSign)
+	}
 }
 
 // handleComment processes comment/keepalive lines and in-band server commands.
@@ -1021,6 +1261,11 @@ func (s *TCPAPRSServer) handleLogin(client *TCPAPRSClient, packet string) {
 func (s *TCPAPRSServer) handleComment(client *TCPAPRSClient, packet string) {
 	trimmed := strings.TrimSpace(strings.TrimPrefix(packet, "#"))
 
+	if arg, ok := strings.CutPrefix(trimmed, serverCommandIGate); ok && (arg == "" || arg[0] == ' ') {
+		s.handleIGate(client, strings.TrimSpace(arg))
+		return
+	}
+
 	if strings.HasPrefix(trimmed, serverCommandFilter) {
 		spec := strings.TrimSpace(strings.TrimPrefix(trimmed, serverCommandFilter))
 		client.mu.Lock()
@@ -1041,8 +1286,24 @@ func (s *TCPAPRSServer) handleAPRSData(c *TCPAPRSClient, packet string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	// Dupefeed ports are receive-only: clients there never inject traffic.
-	if c.dupefeed {
+	// Dupefeed ports are receive-only: clients there never inject traffic,
+	// nor do clients an operator has made read-only.
+	if c.dupefeed || c.readOnly.Load() {
+		return
+	}
+
+	// Drop what a flooding client sends beyond its rate limit, or its host
+	// beyond the same limit over all its connections. The dupe checker alone
+	// does not stop floods of distinct payloads.
+	lim := globalRateLimit()
+	if l := listenerAt(&s.index); l != nil {
+		lim = l.rateLimit
+	}
+	if !c.limiter.Allow(time.Now(), 1, len(packet)) ||
+		(c.ip.IsValid() && !s.limits.Allow(c.ip.String(), lim, 1, len(packet))) {
+		c.stats.AddReceivedRateLimited(1)
+		s.stats.AddReceivedRateLimited(1)
+		globalStats.AddReceivedRateLimited(1)
 		return
 	}
 
@@ -1069,15 +1330,6 @@ func (s *TCPAPRSServer) handleAPRSData(c *TCPAPRSClient, packet string) {
 		return
 	}
 
-	// Drop duplicates seen within the dedup window, but forward them to any
-	// dupefeed ports for debugging.
-	if c.dup.Seen(packet) {
-		c.stats.AddReceivedDups(1)
-		globalStats.AddReceivedDups(1)
-		uplink.Stream.WriteDupe(parsed, c.callSign)
-		return
-	}
-
 	// Process QConstruct for packet routing.
 	qConfig := &qConstruct.QConfig{
 		ServerLogin:            config.Get().Server.ID,
@@ -1107,13 +1359,24 @@ func (s *TCPAPRSServer) handleAPRSData(c *TCPAPRSClient, packet string) {
 		return
 	}
 
-	// Record the source station as heard by this client (message routing).
-	if parsed.From != "" && c.heard != nil {
+	// Record the source station as heard by this client (message routing);
+	// in igate assist only stations it gated from RF count. Messages spooled
+	// for that station can now be delivered through it.
+	if parsed.From != "" && c.heard != nil && (!c.igateAssist.Load() || gatedFromRF(parsed.Path)) {
 		c.heard.Add(parsed.From)
+		stations.heardBy(parsed.From, c, time.Now())
+		if n := spool.deliver(c, parsed.From); n > 0 && c.igateAssist.Load() {
+			c.gatedMessages.Add(uint64(n))
+		}
 	}
 
-	// Send to distribution stream
-	uplink.Stream.Write(parsed, c.callSign)
+	// Send to distribution stream. A packet already seen within the duplicate
+	// window (from this client or by any other path) is only forwarded to
+	// dupefeed ports.
+	if !uplink.Stream.Ingest(parsed, c.callSign) {
+		c.stats.AddReceivedDups(1)
+		globalStats.AddReceivedDups(1)
+	}
 }
 
 // updateServerSendStats updates server and global send statistics.
@@ -1133,7 +1396,7 @@ func (s *TCPAPRSServer) updateStats() {
 		select {
 		case <-ticker.C:
 			s.stats.UpdateRates()
-			if l := listenerAt(s.index); l != nil {
+			if l := listenerAt(&s.index); l != nil {
 				l.SetStats(s.stats.Snapshot())
 			}
 
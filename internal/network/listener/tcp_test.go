@@ -2,8 +2,10 @@ package listener
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"net"
+	"net/netip"
 	"strings"
 	"sync"
 	"testing"
@@ -11,9 +13,14 @@ import (
 
 	"github.com/APRSCN/aprsgo/internal/infra/config"
 	"github.com/APRSCN/aprsgo/internal/infra/logger"
+	"github.com/APRSCN/aprsgo/internal/model"
 	"github.com/APRSCN/aprsgo/internal/network/uplink"
+	"github.com/APRSCN/aprsgo/internal/pkg/acl"
+	"github.com/APRSCN/aprsgo/internal/pkg/historydb"
+	"github.com/APRSCN/aprsgo/internal/pkg/ratelimit"
 	"github.com/APRSCN/aprsutils"
 	"github.com/APRSCN/aprsutils/client"
+	"github.com/APRSCN/aprsutils/parser"
 	"go.uber.org/zap"
 )
 
@@ -98,6 +105,50 @@ func TestTCPLoginAndForward(t *testing.T) {
 	}
 }
 
+// TestTCPSharedDupe verifies a client packet that already reached the stream
+// by another path (here a core peer) is only published as a dupe and counted
+// as a client dupe.
+func TestTCPSharedDupe(t *testing.T) {
+	logger.L = zap.NewNop()
+	config.Set(testConfig())
+	uplink.Stream = uplink.NewDataStream(10)
+	info := ">shared dupe"
+	peerCopy, err := parser.Parse("TEST1>APRS,TCPIP*,qAS,PEER:"+info, parser.WithDisableToCallsignValidate())
+	if err != nil {
+		t.Fatal(err)
+	}
+	uplink.Stream.Ingest(peerCopy, uplink.WriterPeerPrefix+"PEER")
+	ch, unsub := uplink.Stream.Subscribe()
+	defer unsub()
+
+	srv, addr := startTestTCPServer(t, client.Fullfeed)
+	defer srv.Stop()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	readLine(t, r, conn)
+	fmt.Fprintf(conn, "user TEST1 pass %d vers test 1.0\r\n", aprsutils.Passcode("TEST1"))
+	readLine(t, r, conn)
+
+	fmt.Fprintf(conn, "TEST1>APRS,WIDE1-1:%s\r\n", info)
+
+	select {
+	case data := <-ch:
+		if !data.Dupe {
+			t.Errorf("packet seen by another path published as new: %q", data.Data.Raw)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("dupe not published to the stream")
+	}
+	if d := uplink.Stream.Dedup().Dupes()[uplink.SourceClient]; d != 1 {
+		t.Errorf("client dupes = %d, want 1", d)
+	}
+}
+
 // TestTCPUnverifiedRejected verifies an unverified client cannot inject data.
 func TestTCPUnverifiedRejected(t *testing.T) {
 	logger.L = zap.NewNop()
@@ -207,3 +258,82 @@ func TestKickOldSameCallsignConcurrent(t *testing.T) {
 	// Give kickOld goroutines a moment to finish before the server stops.
 	time.Sleep(100 * time.Millisecond)
 }
+
+// TestRegisterPerIPLimit verifies the per-address client cap: a third client
+// from one address is rejected, other and whitelisted addresses are not held
+// to it, and unregistering frees the slot again.
+func TestRegisterPerIPLimit(t *testing.T) {
+	config.Set(testConfig())
+	exempt, err := acl.Compile([]string{"allow 192.0.2.100/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewTCPAPRSServer(client.Fullfeed, 0)
+	Listeners = []*Listener{{Name: "test", Protocol: "tcp", s: srv, maxClientsPerIP: 2, perIPExempt: exempt}}
+
+	newClient := func(ip string) *TCPAPRSClient {
+		return &TCPAPRSClient{ip: netip.MustParseAddr(ip)}
+	}
+	a1, a2 := newClient("192.0.2.1"), newClient("192.0.2.1")
+	for _, c := range []*TCPAPRSClient{a1, a2, newClient("192.0.2.2")} {
+		if err := srv.register(c); err != nil {
+			t.Fatalf("register %s: %v", c.ip, err)
+		}
+	}
+	if err := srv.register(newClient("192.0.2.1")); !errors.Is(err, errPerIPLimit) {
+		t.Fatalf("third client from one address: err = %v, want errPerIPLimit", err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := srv.register(newClient("192.0.2.100")); err != nil {
+			t.Fatalf("whitelisted client %d rejected: %v", i, err)
+		}
+	}
+
+	srv.unregister(a1)
+	if err := srv.register(newClient("192.0.2.1")); err != nil {
+		t.Fatalf("register after unregister: %v", err)
+	}
+	srv.unregister(a2)
+	if srv.perIP[netip.MustParseAddr("192.0.2.1")] != 1 || len(srv.perIP) != 2 {
+		t.Fatalf("perIP = %v after unregister", srv.perIP)
+	}
+	for c := range srv.clients {
+		srv.unregister(c)
+	}
+	if len(srv.perIP) != 0 {
+		t.Fatalf("perIP = %v after unregistering all", srv.perIP)
+	}
+}
+
+// TestTCPRateLimitPerIP verifies that a host's connections share one rate
+// limit and that a reloaded limit reaches the connected clients.
+func TestTCPRateLimitPerIP(t *testing.T) {
+	logger.L = zap.NewNop()
+	config.Set(testConfig())
+	uplink.Stream = uplink.NewDataStream(10)
+	s := NewTCPAPRSServer(client.Fullfeed, 0)
+	Listeners = []*Listener{{Name: "test", Protocol: "tcp", s: s, rateLimit: ratelimit.Limits{Packets: 1}}}
+
+	var clients [2]*TCPAPRSClient
+	for i := range clients {
+		clients[i] = &TCPAPRSClient{
+			ip: netip.MustParseAddr("192.0.2.1"), callSign: "TEST", verified: true, loggedIn: true,
+			server: s, heard: historydb.NewHeardList(), sendCh: make(chan []byte, 8), stats: new(model.Counters),
+		}
+		s.clients[clients[i]] = false
+	}
+	// The burst is two packets a host, however many connections it opens.
+	for i, c := range []*TCPAPRSClient{clients[0], clients[1], clients[1]} {
+		s.handleAPRSData(c, fmt.Sprintf("TEST>APRS,TCPIP*:>flood %d", i))
+	}
+	if got := s.stats.Snapshot().ReceivedRateLimited; got != 1 {
+		t.Fatalf("rate-limited = %d, want 1", got)
+	}
+
+	s.setRateLimit(ratelimit.Limits{Packets: 5})
+	for _, c := range clients {
+		if !c.limiter.Allow(time.Now(), 10, 0) || c.limiter.Allow(time.Now(), 1, 0) {
+			t.Fatal("client limiter does not follow the reloaded limit")
+		}
+	}
+}
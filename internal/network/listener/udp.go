@@ -5,10 +5,13 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/APRSCN/aprsgo/internal/infra/logger"
 	"github.com/APRSCN/aprsgo/internal/model"
+	"github.com/APRSCN/aprsgo/internal/pkg/ratelimit"
+	"github.com/APRSCN/aprsgo/internal/security"
 	"github.com/APRSCN/aprsgo/internal/upgrade"
 	"go.uber.org/zap"
 )
@@ -27,19 +30,24 @@ const maxUDPDatagram = 2048
 // Authenticated packets are injected with a qAU construct (see ProcessSubmit /
 // SubmitUDP).
 type UDPSubmitServer struct {
-	conn  *net.UDPConn
-	index int
+	conn *net.UDPConn
+	// index is the server's position in Listeners; a reload may renumber it.
+	index atomic.Int64
 	stop  chan struct{}
 	wg    sync.WaitGroup
 	stats model.Counters
+	// limits holds one rate limiter per source IP.
+	limits *ratelimit.Keyed
 }
 
 // NewUDPSubmitServer creates a UDP submit server for the listener at index.
 func NewUDPSubmitServer(index int) *UDPSubmitServer {
-	return &UDPSubmitServer{
-		index: index,
-		stop:  make(chan struct{}),
+	s := &UDPSubmitServer{
+		stop:   make(chan struct{}),
+		limits: ratelimit.NewKeyed(),
 	}
+	s.index.Store(int64(index))
+	return s
 }
 
 // Start begins listening on addr (host:port).
@@ -101,7 +109,7 @@ func (s *UDPSubmitServer) updateStats() {
 			return
 		case <-ticker.C:
 			s.stats.UpdateRates()
-			if l := listenerAt(s.index); l != nil {
+			if l := listenerAt(&s.index); l != nil {
 				l.SetStats(s.stats.Snapshot())
 			}
 		}
@@ -111,10 +119,15 @@ func (s *UDPSubmitServer) updateStats() {
 // handleDatagram parses one datagram's envelope and submits its packets.
 func (s *UDPSubmitServer) handleDatagram(payload string, remote *net.UDPAddr) {
 	// Access-control: drop datagrams from addresses the ACL rejects.
-	if l := listenerAt(s.index); l != nil && !l.acl.AllowAddr(remote.AddrPort().Addr()) {
+	l := listenerAt(&s.index)
+	if l != nil && !l.acl.AllowAddr(remote.AddrPort().Addr()) {
 		logger.L.Debug("UDP submit rejected by ACL", zap.String("remote", remote.String()))
 		return
 	}
+	if !security.AddrAllowed(remote.String()) {
+		logger.L.Debug("UDP submit rejected by ban", zap.String("remote", remote.String()))
+		return
+	}
 
 	call, verified, packets, ok := parseSubmitEnvelope(payload)
 	if !ok {
@@ -131,8 +144,26 @@ func (s *UDPSubmitServer) handleDatagram(payload string, remote *net.UDPAddr) {
 		s.bumpStats(0, 0, 1)
 		return
 	}
+	if !security.LoginAllowed(call) {
+		logger.L.Debug("UDP submit: login not allowed",
+			zap.String("remote", remote.String()),
+			zap.String("callsign", call))
+		s.bumpStats(0, 0, 1)
+		return
+	}
 
+	var lim ratelimit.Limits
+	if l != nil {
+		lim = l.rateLimit
+	}
+	ip := remote.AddrPort().Addr().Unmap().String()
 	for _, pkt := range packets {
+		if !s.limits.Allow(ip, lim, 1, len(pkt)) {
+			s.bumpStats(1, 0, 0)
+			s.stats.AddReceivedRateLimited(1)
+			globalStats.AddReceivedRateLimited(1)
+			continue
+		}
 		if err := ProcessSubmit(call, true, pkt, SubmitUDP); err != nil {
 			switch {
 			case errors.Is(err, ErrSubmitDuplicate):
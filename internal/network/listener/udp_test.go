@@ -8,6 +8,7 @@ import (
 	"github.com/APRSCN/aprsgo/internal/infra/config"
 	"github.com/APRSCN/aprsgo/internal/infra/logger"
 	"github.com/APRSCN/aprsgo/internal/network/uplink"
+	"github.com/APRSCN/aprsgo/internal/pkg/ratelimit"
 	"go.uber.org/zap"
 )
 
@@ -59,3 +60,32 @@ func TestUDPSubmitServerEndToEnd(t *testing.T) {
 		t.Fatal("no packet injected from UDP datagram")
 	}
 }
+
+// TestUDPSubmitRateLimit verifies that packets beyond a source IP's rate limit
+// are dropped and counted, while other sources keep their own allowance.
+func TestUDPSubmitRateLimit(t *testing.T) {
+	logger.L = zap.NewNop()
+	config.Set(testConfig())
+	uplink.Stream = uplink.NewDataStream(10)
+
+	Listeners = []*Listener{{Name: "udp-test", Protocol: "udp", rateLimit: ratelimit.Limits{Packets: 1}}}
+	srv := NewUDPSubmitServer(0)
+
+	payload := "user TEST pass 29939 vers sw 1.0\r\n" +
+		"TEST>APRS,TCPIP*:>rate one\r\n" +
+		"TEST>APRS,TCPIP*:>rate two\r\n" +
+		"TEST>APRS,TCPIP*:>rate three\r\n" +
+		"TEST>APRS,TCPIP*:>rate four\r\n"
+	srv.handleDatagram(payload, &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1000})
+
+	st := srv.stats.Snapshot()
+	if st.ReceivedPackets != 4 || st.ReceivedRateLimited != 2 {
+		t.Fatalf("received = %d, rate-limited = %d; want 4, 2", st.ReceivedPackets, st.ReceivedRateLimited)
+	}
+
+	srv.handleDatagram("user TEST pass 29939 vers sw 1.0\r\nTEST>APRS,TCPIP*:>other source\r\n",
+		&net.UDPAddr{IP: net.ParseIP("192.0.2.2"), Port: 1000})
+	if got := srv.stats.Snapshot().ReceivedRateLimited; got != 2 {
+		t.Fatalf("other source rate-limited: total = %d, want 2", got)
+	}
+}
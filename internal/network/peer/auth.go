@@ -0,0 +1,170 @@
+package peer
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/APRSCN/aprsgo/internal/infra/config"
+)
+
+// Peer authentication. A tcp peer with tls set is only exchanged with over
+// mutual TLS, both ends verifying the other's certificate against the peer's
+// CA and checking it belongs to that peer. A udp peer with a secret signs every datagram: the first line is
+//
+//	#AUTH <unix-time> <nonce> <hmac>
+//
+// where hmac is the hex HMAC-SHA256, keyed with the secret, of
+// "<unix-time> <nonce>\n" followed by the rest of the datagram. A datagram is
+// accepted within the replay window of its timestamp, once per nonce. Peers
+// that do not authenticate ignore the line as a comment.
+const authTag = "#AUTH"
+
+// defaultReplayWindow applies when a peer sets no replay_window.
+const defaultReplayWindow = 30 * time.Second
+
+// handshakeTimeout bounds a TLS handshake with a peer.
+const handshakeTimeout = 10 * time.Second
+
+// newPeerTLS loads a peer's certificate, key and CA into the configurations
+// used when dialling it (client) and when it connects in (server). Both
+// require and verify the other end's certificate, and accept it only as the
+// configured peer's (see peerIdentity).
+func newPeerTLS(p config.PeerConfig) (client, server *tls.Config, err error) {
+	cert, err := tls.LoadX509KeyPair(p.Cert, p.Key)
+	if err != nil {
+		return nil, nil, err
+	}
+	pem, err := os.ReadFile(p.CA)
+	if err != nil {
+		return nil, nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, nil, fmt.Errorf("no certificates found in CA file %q", p.CA)
+	}
+	verify := func(cs tls.ConnectionState) error { return peerIdentity(cs, p) }
+	client = &tls.Config{
+		Certificates:     []tls.Certificate{cert},
+		RootCAs:          pool,
+		ServerName:       p.Host,
+		MinVersion:       tls.VersionTLS12,
+		VerifyConnection: verify,
+	}
+	server = &tls.Config{
+		Certificates:     []tls.Certificate{cert},
+		ClientCAs:        pool,
+		ClientAuth:       tls.RequireAndVerifyClientCert,
+		MinVersion:       tls.VersionTLS12,
+		VerifyConnection: verify,
+	}
+	return client, server, nil
+}
+
+// peerIdentity checks that the verified certificate of a connection belongs to
+// peer p: it is valid for p's host, or its Common Name is p's server ID. A
+// certificate of another peer under the same CA is refused.
+func peerIdentity(cs tls.ConnectionState, p config.PeerConfig) error {
+	if len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("no certificate presented")
+	}
+	leaf := cs.PeerCertificates[0]
+	if leaf.VerifyHostname(p.Host) == nil {
+		return nil
+	}
+	if p.ID != "" && strings.EqualFold(leaf.Subject.CommonName, p.ID) {
+		return nil
+	}
+	return fmt.Errorf("certificate %q does not belong to peer %q", leaf.Subject.CommonName, p.Name)
+}
+
+// datagramAuth signs and verifies the datagrams of a udp peer with a secret.
+type datagramAuth struct {
+	key    []byte
+	window time.Duration
+
+	mu        sync.Mutex
+	seen      map[string]time.Time // nonce -> when it leaves the window
+	lastPrune time.Time
+}
+
+// newDatagramAuth returns the datagram authenticator of a peer, or nil when it
+// has no secret.
+func newDatagramAuth(p config.PeerConfig) *datagramAuth {
+	if p.Secret == "" {
+		return nil
+	}
+	window := time.Duration(p.ReplayWindow) * time.Second
+	if window <= 0 {
+		window = defaultReplayWindow
+	}
+	return &datagramAuth{key: []byte(p.Secret), window: window, seen: make(map[string]time.Time)}
+}
+
+// mac returns the hex HMAC of a signed datagram's header fields and body.
+func (a *datagramAuth) mac(ts, nonce string, body []byte) string {
+	h := hmac.New(sha256.New, a.key)
+	h.Write([]byte(ts + " " + nonce + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// sign prefixes body with its authentication line.
+func (a *datagramAuth) sign(body []byte, now time.Time) []byte {
+	var n [8]byte
+	_, _ = rand.Read(n[:])
+	ts, nonce := strconv.FormatInt(now.Unix(), 10), hex.EncodeToString(n[:])
+	header := authTag + " " + ts + " " + nonce + " " + a.mac(ts, nonce, body) + "\r\n"
+	return append([]byte(header), body...)
+}
+
+// verify checks a signed datagram and returns its body. It fails for an
+// unsigned or badly signed datagram, one outside the replay window and one
+// already seen.
+func (a *datagramAuth) verify(datagram []byte, now time.Time) ([]byte, error) {
+	line, body, ok := strings.Cut(string(datagram), "\n")
+	if !ok {
+		return nil, fmt.Errorf("unsigned datagram")
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 4 || fields[0] != authTag {
+		return nil, fmt.Errorf("unsigned datagram")
+	}
+	ts, nonce, sum := fields[1], fields[2], fields[3]
+	if !hmac.Equal([]byte(sum), []byte(a.mac(ts, nonce, []byte(body)))) {
+		return nil, fmt.Errorf("bad signature")
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("bad timestamp %q", ts)
+	}
+	at := time.Unix(sec, 0)
+	if d := now.Sub(at); d > a.window || d < -a.window {
+		return nil, fmt.Errorf("timestamp %s outside the replay window", at.UTC().Format(time.RFC3339))
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if now.Sub(a.lastPrune) >= a.window {
+		for n, until := range a.seen {
+			if now.After(until) {
+				delete(a.seen, n)
+			}
+		}
+		a.lastPrune = now
+	}
+	if _, dup := a.seen[nonce]; dup {
+		return nil, fmt.Errorf("replayed datagram")
+	}
+	a.seen[nonce] = at.Add(a.window)
+	return []byte(body), nil
+}
@@ -0,0 +1,48 @@
+package peer
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/APRSCN/aprsgo/internal/infra/config"
+)
+
+func init() {
+	config.RegisterCheck(Check)
+	config.RegisterWarning(CheckAddrs)
+}
+
+// Check reports the problems that would make peers of cfg unusable when it is
+// loaded and that config.Validate cannot see: TLS files that do not load. It
+// binds no sockets.
+func Check(cfg config.StaticConfig) []error {
+	var errs []error
+	for _, gc := range groupsOf(cfg) {
+		for _, p := range gc.Peers {
+			if p.TLS && p.Cert != "" && p.Key != "" && p.CA != "" {
+				if _, _, err := newPeerTLS(p); err != nil {
+					errs = append(errs, fmt.Errorf("peer group %q: peer %q: tls: %v", gc.Name, p.Name, err))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// CheckAddrs reports the UDP peers of cfg whose address does not resolve. It
+// depends on DNS, so its findings are warnings: such a peer is only skipped.
+func CheckAddrs(cfg config.StaticConfig) []error {
+	var errs []error
+	for _, gc := range groupsOf(cfg) {
+		for _, p := range gc.Peers {
+			if p.Host == "" || strings.EqualFold(p.Protocol, "tcp") {
+				continue
+			}
+			if _, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", p.Host, p.Port)); err != nil {
+				errs = append(errs, fmt.Errorf("peer group %q: peer %q: invalid address: %v", gc.Name, p.Name, err))
+			}
+		}
+	}
+	return errs
+}
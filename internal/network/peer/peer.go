@@ -13,6 +13,8 @@ package peer
 
 import (
 	"bufio"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"strings"
@@ -21,6 +23,7 @@ import (
 
 	"github.com/APRSCN/aprsgo/internal/infra/config"
 	"github.com/APRSCN/aprsgo/internal/infra/logger"
+	"github.com/APRSCN/aprsgo/internal/model"
 	"github.com/APRSCN/aprsgo/internal/network/uplink"
 	"github.com/APRSCN/aprsgo/internal/wait"
 	"github.com/APRSCN/aprsutils/parser"
@@ -45,20 +48,27 @@ const (
 
 // remotePeer is a configured remote peer.
 type remotePeer struct {
-	name string
-	id   string
-	tcp  bool // transport is TCP (otherwise UDP)
+	name  string
+	id    string
+	tcp   bool       // transport is TCP (otherwise UDP)
+	stats *peerStats // attached by Manager.start
 
-	// UDP transport: resolved datagram address.
+	// UDP transport: resolved datagram address, and the datagram signer when
+	// the peer has a secret.
 	udpAddr *net.UDPAddr
+	auth    *datagramAuth
 
 	// TCP transport: dial target and the set of currently-open connections
 	// (an outbound dialled connection and/or an accepted inbound one). Writes
 	// go to every open connection; the dedup layer absorbs any duplication.
 	tcpAddr string
 	ip      net.IP // resolved remote IP, for matching inbound TCP connections
-	connsMu sync.Mutex
-	conns   map[net.Conn]struct{}
+	// Mutual TLS configurations for dialled and accepted connections (nil
+	// when the peer link is plain TCP).
+	tlsClient *tls.Config
+	tlsServer *tls.Config
+	connsMu   sync.Mutex
+	conns     map[net.Conn]struct{}
 }
 
 // addConn registers an open TCP connection for this peer.
@@ -78,22 +88,32 @@ func (p *remotePeer) removeConn(c net.Conn) {
 	p.connsMu.Unlock()
 }
 
-// writeAll writes raw to every open connection for this peer.
-func (p *remotePeer) writeAll(raw []byte) {
+// writeAll writes raw to every open connection for this peer. It returns the
+// number of connections written and the last write error, if none succeeded.
+func (p *remotePeer) writeAll(raw []byte) (int, error) {
 	p.connsMu.Lock()
 	conns := make([]net.Conn, 0, len(p.conns))
 	for c := range p.conns {
 		conns = append(conns, c)
 	}
 	p.connsMu.Unlock()
+	var written int
+	var lastErr error
 	for _, c := range conns {
 		_ = c.SetWriteDeadline(time.Now().Add(30 * time.Second))
 		if _, err := c.Write(raw); err != nil {
 			logger.L.Debug("Peer TCP send error", zap.String("peer", p.name), zap.Error(err))
 			_ = c.Close()
 			p.removeConn(c)
+			lastErr = err
+			continue
 		}
+		written++
+	}
+	if written > 0 {
+		lastErr = nil
 	}
+	return written, lastErr
 }
 
 // Manager owns a peer group's transports (a shared UDP socket for UDP peers
@@ -121,11 +141,20 @@ var (
 	managersMu sync.RWMutex
 )
 
-// Info describes a configured remote peer for status reporting.
+// Info describes a configured remote peer and its link for status reporting.
 type Info struct {
-	Name string
-	ID   string
-	Addr string
+	Group    string
+	Name     string
+	ID       string
+	Addr     string
+	Protocol string // "udp" or "tcp"
+	// Up is true while a TCP peer has an open connection, or a UDP peer has
+	// been heard from recently (traffic or heartbeats).
+	Up         bool
+	LastHeard  time.Time // zero if never heard from
+	Reconnects uint64    // outbound TCP reconnects after a drop
+	SendErrors uint64
+	Stats      model.Statistics
 }
 
 // List returns the configured peers across all groups. Safe to call when no
@@ -135,9 +164,31 @@ func List() []Info {
 	defer managersMu.RUnlock()
 	var out []Info
 	for _, m := range managers {
-		for _, p := range m.peers {
-			out = append(out, Info{Name: p.name, ID: p.id, Addr: p.addrString()})
-		}
+		out = append(out, m.info()...)
+	}
+	return out
+}
+
+// info describes the group's peers.
+func (m *Manager) info() []Info {
+	out := make([]Info, 0, len(m.peers))
+	for _, p := range m.peers {
+		proto := "udp"
+		if p.tcp {
+			proto = "tcp"
+		}
+		out = append(out, Info{
+			Group:      m.name,
+			Name:       p.name,
+			ID:         p.id,
+			Addr:       p.addrString(),
+			Protocol:   proto,
+			Up:         p.up(),
+			LastHeard:  p.lastHeard(),
+			Reconnects: p.stats.reconnects.Load(),
+			SendErrors: p.stats.sendErrors.Load(),
+			Stats:      p.stats.Snapshot(),
+		})
 	}
 	return out
 }
@@ -159,6 +210,7 @@ func Init() {
 		logger.L.Debug("No core peers configured")
 		return
 	}
+	statsOnce.Do(func() { go statsDaemon() })
 
 	var started []*Manager
 	for _, gc := range groups {
@@ -186,14 +238,19 @@ func Init() {
 // configuredGroups returns the union of the legacy single-group config and the
 // peergroups list (groups with no peers are skipped by the caller).
 func configuredGroups() []config.PeerGroupConfig {
+	return groupsOf(config.Get())
+}
+
+// groupsOf returns the peer groups of cfg, as configuredGroups.
+func groupsOf(cfg config.StaticConfig) []config.PeerGroupConfig {
 	var groups []config.PeerGroupConfig
-	if legacy := config.Get().Server.Peer; len(legacy.Peers) > 0 {
+	if legacy := cfg.Server.Peer; len(legacy.Peers) > 0 {
 		if legacy.Name == "" {
 			legacy.Name = "default"
 		}
 		groups = append(groups, legacy)
 	}
-	groups = append(groups, config.Get().Server.PeerGroups...)
+	groups = append(groups, cfg.Server.PeerGroups...)
 	return groups
 }
 
@@ -204,6 +261,14 @@ func buildManager(gc config.PeerGroupConfig) *Manager {
 		hostport := fmt.Sprintf("%s:%d", p.Host, p.Port)
 		if strings.EqualFold(p.Protocol, "tcp") {
 			rp := &remotePeer{name: p.Name, id: p.ID, tcp: true, tcpAddr: hostport}
+			if p.TLS {
+				var err error
+				if rp.tlsClient, rp.tlsServer, err = newPeerTLS(p); err != nil {
+					logger.L.Error("Invalid peer TLS configuration, skipping",
+						zap.String("name", p.Name), zap.Error(err))
+					continue
+				}
+			}
 			if ips, err := net.LookupIP(p.Host); err == nil && len(ips) > 0 {
 				rp.ip = ips[0]
 			}
@@ -216,7 +281,7 @@ func buildManager(gc config.PeerGroupConfig) *Manager {
 				zap.String("name", p.Name), zap.Error(err))
 			continue
 		}
-		m.peers = append(m.peers, &remotePeer{name: p.Name, id: p.ID, udpAddr: addr})
+		m.peers = append(m.peers, &remotePeer{name: p.Name, id: p.ID, udpAddr: addr, auth: newDatagramAuth(p)})
 	}
 	return m
 }
@@ -243,6 +308,9 @@ func (m *Manager) hasTCP() bool {
 // start opens the needed transports and launches the receive/dial/send loops.
 func (m *Manager) start() error {
 	bind := fmt.Sprintf("%s:%d", m.bindHost, m.bindPort)
+	for _, p := range m.peers {
+		p.stats = statsOf(m.name, p.name)
+	}
 
 	if m.hasUDP() {
 		udpAddr, err := net.ResolveUDPAddr("udp", bind)
@@ -254,8 +322,9 @@ func (m *Manager) start() error {
 			return err
 		}
 		m.udpConn = conn
-		m.wg.Add(1)
+		m.wg.Add(2)
 		go m.udpReceiveLoop()
+		go m.heartbeatLoop()
 	}
 
 	if m.hasTCP() {
@@ -348,12 +417,21 @@ func (m *Manager) udpReceiveLoop() {
 			logger.L.Debug("Peer datagram from unknown source", zap.String("remote", remote.String()))
 			continue
 		}
-		m.handlePayload(string(buf[:n]), src)
+		payload := buf[:n]
+		if src.auth != nil {
+			if payload, err = src.auth.verify(payload, time.Now()); err != nil {
+				logger.L.Debug("Peer datagram rejected",
+					zap.String("peer", src.name), zap.String("remote", remote.String()), zap.Error(err))
+				continue
+			}
+		}
+		src.heard(n)
+		m.handlePayload(string(payload), src)
 	}
 }
 
 // tcpAcceptLoop accepts inbound TCP peer connections, matching them to a
-// configured peer by source IP.
+// configured peer by source IP (and, for a TLS peer, by its certificate).
 func (m *Manager) tcpAcceptLoop() {
 	defer m.wg.Done()
 	for {
@@ -374,9 +452,6 @@ func (m *Manager) tcpAcceptLoop() {
 			_ = conn.Close()
 			continue
 		}
-		src.addConn(conn)
-		logger.L.Info("Core peer connected (inbound)",
-			zap.String("peer", src.name), zap.String("remote", conn.RemoteAddr().String()))
 		m.wg.Add(1)
 		go m.tcpReadLoop(conn, src)
 	}
@@ -387,6 +462,7 @@ func (m *Manager) tcpAcceptLoop() {
 func (m *Manager) tcpDialLoop(p *remotePeer) {
 	defer m.wg.Done()
 	backoff := peerMinBackoff
+	dialled := false
 	for {
 		select {
 		case <-m.stop:
@@ -395,6 +471,13 @@ func (m *Manager) tcpDialLoop(p *remotePeer) {
 		}
 
 		conn, err := net.DialTimeout("tcp", p.tcpAddr, 10*time.Second)
+		if err == nil && p.tlsClient != nil {
+			conn, err = m.handshake(tls.Client(conn, p.tlsClient))
+			if err != nil {
+				logger.L.Warn("Core peer TLS handshake failed (outbound)",
+					zap.String("peer", p.name), zap.String("remote", p.tcpAddr), zap.Error(err))
+			}
+		}
 		if err != nil {
 			if !wait.SleepOrStop(m.stop, backoff) {
 				return
@@ -406,6 +489,10 @@ func (m *Manager) tcpDialLoop(p *remotePeer) {
 			continue
 		}
 		backoff = peerMinBackoff
+		if dialled {
+			p.stats.reconnects.Add(1)
+		}
+		dialled = true
 		p.addConn(conn)
 		logger.L.Info("Core peer connected (outbound)",
 			zap.String("peer", p.name), zap.String("remote", p.tcpAddr))
@@ -425,12 +512,43 @@ func (m *Manager) tcpDialLoop(p *remotePeer) {
 	}
 }
 
-// tcpReadLoop is the wg-tracked entry point for an accepted inbound connection.
+// tcpReadLoop is the wg-tracked entry point for an accepted inbound
+// connection. A TLS peer must complete the handshake first.
 func (m *Manager) tcpReadLoop(conn net.Conn, src *remotePeer) {
 	defer m.wg.Done()
+	if src.tlsServer != nil {
+		var err error
+		if conn, err = m.handshake(tls.Server(conn, src.tlsServer)); err != nil {
+			logger.L.Warn("Core peer TLS handshake failed (inbound)",
+				zap.String("peer", src.name), zap.String("remote", conn.RemoteAddr().String()), zap.Error(err))
+			return
+		}
+	}
+	src.addConn(conn)
+	logger.L.Info("Core peer connected (inbound)",
+		zap.String("peer", src.name), zap.String("remote", conn.RemoteAddr().String()))
 	m.tcpReadConn(conn, src)
 }
 
+// handshake completes a TLS handshake within handshakeTimeout, or when the
+// manager stops. It closes the connection on failure.
+func (m *Manager) handshake(conn *tls.Conn) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-m.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	if err := conn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return conn, err
+	}
+	return conn, nil
+}
+
 // tcpReadConn reads APRS lines from a TCP peer connection until it closes,
 // injecting each into the stream. It unregisters and closes the connection on
 // return.
@@ -445,6 +563,7 @@ func (m *Manager) tcpReadConn(conn net.Conn, src *remotePeer) {
 		if err != nil {
 			return
 		}
+		src.heard(len(line))
 		line = strings.TrimSpace(strings.TrimRight(line, "\r\n"))
 		if line == "" || strings.HasPrefix(line, "#") {
 			continue
@@ -497,8 +616,12 @@ func (m *Manager) handlePayload(payload string, src *remotePeer) {
 
 // injectFromPeer q-processes and injects a single packet received from a peer.
 func (m *Manager) injectFromPeer(packet string, src *remotePeer) {
+	src.stats.AddReceivedPackets(1)
+	Stats.AddReceivedPackets(1)
 	parsed, _ := parser.Parse(packet, parser.WithDisableToCallsignValidate())
 	if parsed.To == "" {
+		src.stats.AddReceivedErrors(1)
+		Stats.AddReceivedErrors(1)
 		return
 	}
 
@@ -514,19 +637,30 @@ func (m *Manager) injectFromPeer(packet string, src *remotePeer) {
 	}
 	result, err := qConstruct.QConstruct(parsed, qConfig)
 	if err != nil || result.ShouldDrop || result.IsLoop {
+		src.stats.AddReceivedQDrop(1)
+		Stats.AddReceivedQDrop(1)
 		return
 	}
 
 	packet, err = qConstruct.Replace(packet, parsed.To, result.Path)
 	if err != nil {
+		src.stats.AddReceivedErrors(1)
+		Stats.AddReceivedErrors(1)
 		return
 	}
 	parsed, err = parser.Parse(packet, parser.WithDisableToCallsignValidate())
 	if err != nil {
+		src.stats.AddReceivedErrors(1)
+		Stats.AddReceivedErrors(1)
 		return
 	}
 
-	uplink.Stream.Write(parsed, WriterPrefix+src.id)
+	// In a mesh the same packet arrives once from each peer (and may also come
+	// from the uplink or a local client): only the first copy is distributed.
+	if !uplink.Stream.Ingest(parsed, WriterPrefix+src.id) {
+		src.stats.AddReceivedDups(1)
+		Stats.AddReceivedDups(1)
+	}
 }
 
 // remoteIP returns the peer's remote IP string for q-construct (IP->hex).
@@ -573,12 +707,25 @@ func (m *Manager) relay(data uplink.StreamData) {
 			continue // never echo back to the source peer
 		}
 		if p.tcp {
-			p.writeAll(raw)
+			if n, err := p.writeAll(raw); n > 0 || err != nil {
+				p.sent(1, len(raw), err)
+			}
 			continue
 		}
-		if _, err := m.udpConn.WriteToUDP(raw, p.udpAddr); err != nil {
-			logger.L.Debug("Peer UDP send error",
-				zap.String("peer", p.name), zap.Error(err))
-		}
+		m.sendUDP(p, raw, 1)
+	}
+}
+
+// sendUDP sends a datagram carrying raw (signed when the peer has a secret)
+// to a UDP peer, counting it as packets sent.
+func (m *Manager) sendUDP(p *remotePeer, raw []byte, packets int) {
+	datagram := raw
+	if p.auth != nil {
+		datagram = p.auth.sign(raw, time.Now())
+	}
+	_, err := m.udpConn.WriteToUDP(datagram, p.udpAddr)
+	if err != nil {
+		logger.L.Debug("Peer UDP send error", zap.String("peer", p.name), zap.Error(err))
 	}
+	p.sent(packets, len(datagram), err)
 }
@@ -2,7 +2,19 @@ package peer
 
 import (
 	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
 	"net"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -14,6 +26,12 @@ import (
 	"go.uber.org/zap"
 )
 
+// uniq makes the information field of a test packet unique to this run, so
+// the shared duplicate window does not drop it when tests are repeated.
+func uniq(raw string) string {
+	return raw + " " + strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
 // parsePkt parses a raw packet for tests.
 func parsePkt(t *testing.T, raw string) parser.Parsed {
 	t.Helper()
@@ -43,6 +61,27 @@ func newManager(t *testing.T, peerAddr *net.UDPAddr) *Manager {
 	return m
 }
 
+// readRelayed returns the next datagram read from conn, skipping heartbeats.
+// Datagrams of a signing peer are verified with auth and returned unsigned.
+func readRelayed(conn *net.UDPConn, auth *datagramAuth) (string, error) {
+	buf := make([]byte, 1024)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			return "", err
+		}
+		body := buf[:n]
+		if auth != nil {
+			if body, err = auth.verify(body, time.Now()); err != nil {
+				return "", err
+			}
+		}
+		if !strings.HasPrefix(string(body), heartbeat) {
+			return string(body), nil
+		}
+	}
+}
+
 // TestPeerInboundInjectsToStream verifies a packet sent by a peer is injected
 // into the distribution stream (tagged as a peer source) and q-processed.
 func TestPeerInboundInjectsToStream(t *testing.T) {
@@ -105,13 +144,12 @@ func TestPeerOutboundRelay(t *testing.T) {
 	// A client-sourced packet should be relayed to the peer.
 	uplink.Stream.Write(parsePkt(t, "SRC>DST,qAR,IGATE:from client"), "N5CAL-1")
 
-	buf := make([]byte, 1024)
 	_ = fake.SetReadDeadline(time.Now().Add(2 * time.Second))
-	n, _, err := fake.ReadFromUDP(buf)
+	got, err := readRelayed(fake, nil)
 	if err != nil {
 		t.Fatalf("expected relayed packet, got error: %v", err)
 	}
-	got := strings.TrimSpace(string(buf[:n]))
+	got = strings.TrimSpace(got)
 	if !strings.Contains(got, "from client") {
 		t.Errorf("relayed packet = %q, want it to contain 'from client'", got)
 	}
@@ -119,11 +157,25 @@ func TestPeerOutboundRelay(t *testing.T) {
 	// An uplink-sourced packet must NOT be relayed to the peer.
 	uplink.Stream.Write(parsePkt(t, "UP>DST,qAR,IGATE:from upstream"), "uplink")
 	_ = fake.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
-	if n, _, err := fake.ReadFromUDP(buf); err == nil {
-		t.Errorf("uplink-sourced packet should not reach peer, but got: %q", string(buf[:n]))
+	if got, err := readRelayed(fake, nil); err == nil {
+		t.Errorf("uplink-sourced packet should not reach peer, but got: %q", got)
 	}
 }
 
+// waitConn waits until the manager has registered a connection to p.
+func waitConn(t *testing.T, p *remotePeer) {
+	t.Helper()
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		p.connsMu.Lock()
+		n := len(p.conns)
+		p.connsMu.Unlock()
+		if n > 0 {
+			return
+		}
+	}
+	t.Fatal("manager did not register the peer connection")
+}
+
 // TestPeerTCPRelayAndInject verifies a TCP core peer: a client-sourced packet
 // is relayed out over the TCP connection, and a packet sent by the peer over
 // that connection is injected into the stream tagged as a peer source.
@@ -172,6 +224,7 @@ func TestPeerTCPRelayAndInject(t *testing.T) {
 		t.Fatal("manager did not dial the TCP peer")
 	}
 	defer peerConn.Close()
+	waitConn(t, m.peers[0])
 
 	// Outbound: a client-sourced packet should be relayed over the TCP link.
 	uplink.Stream.Write(parsePkt(t, "SRC>DST,qAR,IGATE:from client"), "N5CAL-1")
@@ -206,3 +259,430 @@ func TestPeerTCPRelayAndInject(t *testing.T) {
 		}
 	}
 }
+
+// TestDatagramAuth verifies datagram signing: a signed datagram verifies once,
+// and tampered, foreign-key, stale and replayed datagrams are refused.
+func TestDatagramAuth(t *testing.T) {
+	a := newDatagramAuth(config.PeerConfig{Secret: "s3cret", ReplayWindow: 10})
+	now := time.Now()
+	body := []byte("SRC>DST,qAR,IGATE:signed\r\n")
+
+	signed := a.sign(body, now)
+	got, err := a.verify(signed, now)
+	if err != nil || string(got) != string(body) {
+		t.Fatalf("verify = %q, %v; want the body", got, err)
+	}
+	if _, err := a.verify(signed, now); err == nil {
+		t.Error("replayed datagram accepted")
+	}
+
+	tampered := []byte(strings.Replace(string(a.sign(body, now)), "signed", "forged", 1))
+	if _, err := a.verify(tampered, now); err == nil {
+		t.Error("tampered datagram accepted")
+	}
+	other := newDatagramAuth(config.PeerConfig{Secret: "other"})
+	if _, err := a.verify(other.sign(body, now), now); err == nil {
+		t.Error("datagram signed with another key accepted")
+	}
+	if _, err := a.verify(a.sign(body, now.Add(-time.Minute)), now); err == nil {
+		t.Error("datagram outside the replay window accepted")
+	}
+	if _, err := a.verify(body, now); err == nil {
+		t.Error("unsigned datagram accepted")
+	}
+	if newDatagramAuth(config.PeerConfig{}) != nil {
+		t.Error("peer without a secret got an authenticator")
+	}
+}
+
+// nextFromPeer returns the sender of the next peer-sourced packet on ch,
+// skipping locally written ones and duplicates, or "" after timeout.
+func nextFromPeer(ch <-chan uplink.StreamData, timeout time.Duration) string {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case data := <-ch:
+			if strings.HasPrefix(data.Writer, WriterPrefix) && !data.Dupe {
+				return data.Data.From
+			}
+		case <-deadline:
+			return ""
+		}
+	}
+}
+
+// TestPeerUDPSecret verifies a udp peer with a secret: only signed datagrams
+// from it are injected, each once, and datagrams relayed to it are signed.
+func TestPeerUDPSecret(t *testing.T) {
+	logger.L = zap.NewNop()
+	config.Set(testConfig())
+	uplink.Stream = uplink.NewDataStream(10)
+	ch, unsub := uplink.Stream.Subscribe()
+	defer unsub()
+
+	fake, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 0})
+	if err != nil {
+		t.Fatalf("fake peer listen: %v", err)
+	}
+	defer fake.Close()
+	fakeAddr := fake.LocalAddr().(*net.UDPAddr)
+
+	pc := config.PeerConfig{Secret: "s3cret"}
+	m := &Manager{bindHost: "127.0.0.1", bindPort: 0, stop: make(chan struct{})}
+	m.peers = []*remotePeer{{name: "fake", id: "SRV1", udpAddr: fakeAddr, auth: newDatagramAuth(pc)}}
+	if err := m.start(); err != nil {
+		t.Fatalf("start peer manager: %v", err)
+	}
+	defer m.shutdown()
+	mgrAddr := m.udpConn.LocalAddr().(*net.UDPAddr)
+
+	// The fake peer signs with its own copy of the secret.
+	auth := newDatagramAuth(pc)
+	signed := auth.sign([]byte(uniq("SGN>DST,qAR,IGATE:signed")+"\r\n"), time.Now())
+	for _, d := range [][]byte{
+		[]byte("UNS>DST,qAR,IGATE:unsigned\r\n"),
+		signed,
+		signed, // replayed
+		auth.sign([]byte(uniq("LAST>DST,qAR,IGATE:signed")+"\r\n"), time.Now()),
+	} {
+		if _, err := fake.WriteToUDP(d, mgrAddr); err != nil {
+			t.Fatalf("fake peer write: %v", err)
+		}
+	}
+	for _, want := range []string{"SGN", "LAST"} {
+		if got := nextFromPeer(ch, 2*time.Second); got != want {
+			t.Fatalf("injected packet from %q, want %q", got, want)
+		}
+	}
+
+	uplink.Stream.Write(parsePkt(t, "SRC>DST,qAR,IGATE:from client"), "N5CAL-1")
+	_ = fake.SetReadDeadline(time.Now().Add(2 * time.Second))
+	body, err := readRelayed(fake, auth)
+	if err != nil {
+		t.Fatalf("expected a signed relayed packet, got error: %v", err)
+	}
+	if !strings.Contains(body, "from client") {
+		t.Errorf("relayed packet = %q, want it to contain 'from client'", body)
+	}
+}
+
+// writePeerPKI writes a CA and three certificates it issued to dir, returning
+// their file paths: two for 127.0.0.1 (one for each end of a link) and one of
+// another server, ROGUE, for another address.
+func writePeerPKI(t *testing.T, dir string) (ca string, certs, keys [3]string) {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Peer CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caCert, _ := x509.ParseCertificate(caDER)
+	write := func(name, typ string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	ca = write("ca.pem", "CERTIFICATE", caDER)
+	for i := range certs {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(int64(i + 2)),
+			Subject:      pkix.Name{CommonName: fmt.Sprintf("SRV%d", i+1)},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		}
+		if i == 2 {
+			// Another server under the same CA, valid for another host.
+			tmpl.Subject.CommonName, tmpl.IPAddresses = "ROGUE", []net.IP{net.ParseIP("192.0.2.99")}
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, caCert, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certs[i] = write(fmt.Sprintf("peer%d.pem", i), "CERTIFICATE", der)
+		keys[i] = write(fmt.Sprintf("peer%d.key", i), "EC PRIVATE KEY", keyDER)
+	}
+	return ca, certs, keys
+}
+
+// TestPeerTCPMutualTLS verifies a tcp peer with tls: the outbound link is
+// mutual TLS, and an inbound connection is only read once it completes a
+// mutual TLS handshake with the peer's own certificate.
+func TestPeerTCPMutualTLS(t *testing.T) {
+	logger.L = zap.NewNop()
+	config.Set(testConfig())
+	uplink.Stream = uplink.NewDataStream(10)
+	ch, unsub := uplink.Stream.Subscribe()
+	defer unsub()
+
+	ca, certs, keys := writePeerPKI(t, t.TempDir())
+	// The fake peer's side of the link: its certificate with the same CA.
+	fakeClient, fakeServer, err := newPeerTLS(config.PeerConfig{Host: "127.0.0.1", Cert: certs[1], Key: keys[1], CA: ca})
+	if err != nil {
+		t.Fatalf("fake peer tls: %v", err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", fakeServer)
+	if err != nil {
+		t.Fatalf("fake peer listen: %v", err)
+	}
+	defer ln.Close()
+	_, portStr, _ := net.SplitHostPort(ln.Addr().String())
+	port, _ := strconv.Atoi(portStr)
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- c
+	}()
+
+	m := buildManager(config.PeerGroupConfig{Name: "tls", Host: "127.0.0.1", Peers: []config.PeerConfig{{
+		Name: "fake", ID: "SRV2", Host: "127.0.0.1", Port: port, Protocol: "tcp",
+		TLS: true, Cert: certs[0], Key: keys[0], CA: ca,
+	}}})
+	if len(m.peers) != 1 {
+		t.Fatal("tls peer skipped")
+	}
+	if err := m.start(); err != nil {
+		t.Fatalf("start peer manager: %v", err)
+	}
+	defer m.shutdown()
+
+	var peerConn net.Conn
+	select {
+	case peerConn = <-accepted:
+	case <-time.After(3 * time.Second):
+		t.Fatal("manager did not dial the TCP peer")
+	}
+	defer peerConn.Close()
+	// The manager registers the link once its side of the handshake is done;
+	// drive the handshake from ours meanwhile.
+	_ = peerConn.SetDeadline(time.Now().Add(3 * time.Second))
+	if err := peerConn.(*tls.Conn).Handshake(); err != nil {
+		t.Fatalf("fake peer handshake: %v", err)
+	}
+	waitConn(t, m.peers[0])
+	uplink.Stream.Write(parsePkt(t, "SRC>DST,qAR,IGATE:from client"), "N5CAL-1")
+	line, err := bufio.NewReader(peerConn).ReadString('\n')
+	if err != nil {
+		t.Fatalf("expected relayed packet over TLS, got: %v", err)
+	}
+	if !strings.Contains(line, "from client") {
+		t.Errorf("relayed TLS packet = %q, want it to contain 'from client'", line)
+	}
+	if certs := peerConn.(*tls.Conn).ConnectionState().PeerCertificates; len(certs) == 0 {
+		t.Error("manager presented no client certificate")
+	}
+
+	// Inbound: a plain connection from the peer's address is not read, a
+	// mutual TLS one is.
+	mgrAddr := m.tcpLn.Addr().String()
+	plain, err := net.Dial("tcp", mgrAddr)
+	if err != nil {
+		t.Fatalf("plain dial: %v", err)
+	}
+	defer plain.Close()
+	_, _ = plain.Write([]byte("PLAIN>DST,qAR,IGATE:not authenticated\r\n"))
+
+	// A certificate from the same CA that belongs to another server is
+	// refused.
+	rogueClient, _, err := newPeerTLS(config.PeerConfig{Host: "127.0.0.1", Cert: certs[2], Key: keys[2], CA: ca})
+	if err != nil {
+		t.Fatalf("rogue tls: %v", err)
+	}
+	if rogue, err := tls.Dial("tcp", mgrAddr, rogueClient); err == nil {
+		_, _ = rogue.Write([]byte("ROGUE>DST,qAR,IGATE:impersonating\r\n"))
+		defer rogue.Close()
+	}
+
+	secure, err := tls.Dial("tcp", mgrAddr, fakeClient)
+	if err != nil {
+		t.Fatalf("tls dial: %v", err)
+	}
+	defer secure.Close()
+	if _, err := secure.Write([]byte(uniq("MTLS>DST,qAR,IGATE:authenticated") + "\r\n")); err != nil {
+		t.Fatalf("tls write: %v", err)
+	}
+	if got := nextFromPeer(ch, 3*time.Second); got != "MTLS" {
+		t.Errorf("injected packet from %q, want MTLS", got)
+	}
+	if got := nextFromPeer(ch, 500*time.Millisecond); got != "" {
+		t.Errorf("unexpected packet from %q", got)
+	}
+}
+
+// TestPeerStatsAndHeartbeat verifies the per-peer counters, that UDP peers
+// get heartbeats, and that a UDP peer is up only while it is heard from.
+func TestPeerStatsAndHeartbeat(t *testing.T) {
+	logger.L = zap.NewNop()
+	config.Set(testConfig())
+	uplink.Stream = uplink.NewDataStream(10)
+	ch, unsub := uplink.Stream.Subscribe()
+	defer unsub()
+	defer func(d time.Duration) { heartbeatInterval = d }(heartbeatInterval)
+	heartbeatInterval = 100 * time.Millisecond
+	peerStatsMu.Lock()
+	delete(peerStatsMap, t.Name()+"/fake")
+	peerStatsMu.Unlock()
+
+	fake, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 0})
+	if err != nil {
+		t.Fatalf("fake peer listen: %v", err)
+	}
+	defer fake.Close()
+	m := &Manager{name: t.Name(), bindHost: "127.0.0.1", bindPort: 0, stop: make(chan struct{})}
+	m.peers = []*remotePeer{{name: "fake", id: "SRV1", udpAddr: fake.LocalAddr().(*net.UDPAddr)}}
+	if err := m.start(); err != nil {
+		t.Fatalf("start peer manager: %v", err)
+	}
+	defer m.shutdown()
+	mgrAddr := m.udpConn.LocalAddr().(*net.UDPAddr)
+
+	// The manager heartbeats the peer, which is down until heard from.
+	buf := make([]byte, 1024)
+	_ = fake.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := fake.ReadFromUDP(buf)
+	if err != nil || !strings.HasPrefix(string(buf[:n]), heartbeat+"TESTING") {
+		t.Fatalf("heartbeat = %q, %v", buf[:n], err)
+	}
+	if m.info()[0].Up {
+		t.Error("peer up before it was heard from")
+	}
+
+	payload := uniq("SRC>DST,qAR,IGATE:one") + "\r\nnot a packet\r\n"
+	if _, err := fake.WriteToUDP([]byte(payload), mgrAddr); err != nil {
+		t.Fatalf("fake peer write: %v", err)
+	}
+	if got := nextFromPeer(ch, 2*time.Second); got != "SRC" {
+		t.Fatalf("injected packet from %q, want SRC", got)
+	}
+	uplink.Stream.Write(parsePkt(t, "CLI>DST,qAR,IGATE:from client"), "N5CAL-1")
+	for deadline := time.Now().Add(2 * time.Second); m.info()[0].Stats.SentPackets == 0 && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	info := m.info()[0]
+	if !info.Up || info.Protocol != "udp" || info.Group != t.Name() {
+		t.Errorf("info = %+v, want an up udp peer of the group", info)
+	}
+	if time.Since(info.LastHeard) > 2*time.Second {
+		t.Errorf("last heard = %v", info.LastHeard)
+	}
+	st := info.Stats
+	if st.ReceivedPackets != 2 || st.ReceivedErrors != 1 || st.ReceivedBytes != uint64(len(payload)) {
+		t.Errorf("rx stats = %+v, want 2 packets (1 error) of %d bytes", st, len(payload))
+	}
+	if st.SentPackets != 1 || st.SentBytes == 0 || info.SendErrors != 0 {
+		t.Errorf("tx stats = %+v, send errors %d; want 1 packet sent", st, info.SendErrors)
+	}
+
+	// Silent for downAfter heartbeats: down.
+	time.Sleep(downAfter*heartbeatInterval + 50*time.Millisecond)
+	if m.info()[0].Up {
+		t.Error("silent peer still up")
+	}
+}
+
+// TestPeerMeshDedup verifies that a packet relayed by every peer of a mesh
+// (and by the uplink) reaches local clients once, and that the copies are
+// counted as dupes of the peers that sent them.
+func TestPeerMeshDedup(t *testing.T) {
+	logger.L = zap.NewNop()
+	config.Set(testConfig())
+	uplink.Stream = uplink.NewDataStream(10)
+	ch, unsub := uplink.Stream.Subscribe()
+	defer unsub()
+
+	var fakes [2]*net.UDPConn
+	m := &Manager{name: t.Name(), bindHost: "127.0.0.1", bindPort: 0, stop: make(chan struct{})}
+	for i := range fakes {
+		fake, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 0})
+		if err != nil {
+			t.Fatalf("fake peer listen: %v", err)
+		}
+		defer fake.Close()
+		fakes[i] = fake
+		name := fmt.Sprintf("fake%d", i)
+		peerStatsMu.Lock()
+		delete(peerStatsMap, t.Name()+"/"+name)
+		peerStatsMu.Unlock()
+		m.peers = append(m.peers, &remotePeer{name: name, id: fmt.Sprintf("SRV%d", i+1),
+			udpAddr: fake.LocalAddr().(*net.UDPAddr)})
+	}
+	if err := m.start(); err != nil {
+		t.Fatalf("start peer manager: %v", err)
+	}
+	defer m.shutdown()
+	mgrAddr := m.udpConn.LocalAddr().(*net.UDPAddr)
+
+	pkt := uniq("MESH>DST,qAR,IGATE:relayed by both")
+	if _, err := fakes[0].WriteToUDP([]byte(pkt+"\r\n"), mgrAddr); err != nil {
+		t.Fatalf("fake peer write: %v", err)
+	}
+	if got := nextFromPeer(ch, 2*time.Second); got != "MESH" {
+		t.Fatalf("injected packet from %q, want MESH", got)
+	}
+	// The second peer's copy, with another path, and the uplink's are dupes.
+	second := strings.Replace(pkt, "qAR,IGATE", "qAS,OTHER", 1)
+	if _, err := fakes[1].WriteToUDP([]byte(second+"\r\n"), mgrAddr); err != nil {
+		t.Fatalf("fake peer write: %v", err)
+	}
+	if got := nextFromPeer(ch, 500*time.Millisecond); got != "" {
+		t.Errorf("duplicate from the second peer injected (from %q)", got)
+	}
+	if uplink.Stream.Ingest(parsePkt(t, pkt), uplink.WriterUplink) {
+		t.Error("uplink copy not seen as a duplicate")
+	}
+	dupes := uplink.Stream.Dedup().Dupes()
+	if dupes[uplink.SourcePeer] != 1 || dupes[uplink.SourceUplink] != 1 {
+		t.Errorf("dupes by source = %v, want one peer and one uplink dupe", dupes)
+	}
+
+	info := m.info()
+	if d := info[0].Stats.ReceivedDups; d != 0 {
+		t.Errorf("first peer dupes = %d, want 0", d)
+	}
+	if d := info[1].Stats.ReceivedDups; d != 1 {
+		t.Errorf("second peer dupes = %d, want 1", d)
+	}
+}
+
+func TestPeerCheckAddrsIsAWarning(t *testing.T) {
+	var cfg config.StaticConfig
+	cfg.Server.PeerGroups = []config.PeerGroupConfig{{Name: "mesh", Peers: []config.PeerConfig{
+		{Name: "gone", Host: "peer.invalid", Port: 10160},
+		{Name: "tcp", Host: "peer.invalid", Port: 10160, Protocol: "tcp"},
+	}}}
+	if errs := Check(cfg); len(errs) != 0 {
+		t.Fatalf("Check = %v, an unresolvable peer host must not fail the config", errs)
+	}
+	errs := CheckAddrs(cfg)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), `peer "gone": invalid address`) {
+		t.Fatalf("CheckAddrs = %v, want one warning for the UDP peer", errs)
+	}
+}
@@ -0,0 +1,179 @@
+package peer
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/APRSCN/aprsgo/internal/infra/config"
+	"github.com/APRSCN/aprsgo/internal/infra/logger"
+	"github.com/APRSCN/aprsgo/internal/model"
+	"github.com/APRSCN/aprsgo/internal/pkg/historydb"
+	"go.uber.org/zap"
+)
+
+// heartbeatInterval is how often a heartbeat is sent to each UDP peer. A UDP
+// peer heard from neither traffic nor heartbeats for downAfter heartbeats is
+// reported down. It is a variable so tests can shorten it.
+var heartbeatInterval = 30 * time.Second
+
+// downAfter is the number of missed heartbeat intervals after which a silent
+// UDP peer is reported down.
+const downAfter = 3
+
+// heartbeat is the comment line sent to UDP peers as a heartbeat. Peers ignore
+// it as a comment; it only refreshes the last-heard time.
+const heartbeat = "# aprsgo heartbeat "
+
+// Stats holds the cumulative counters of all core peers together.
+var Stats = new(model.Counters)
+
+// Packet and byte rates of all core peers, recorded once a minute.
+var (
+	StatsPacketRX = historydb.NewMapFloat64History()
+	StatsPacketTX = historydb.NewMapFloat64History()
+	StatsBytesRX  = historydb.NewMapFloat64History()
+	StatsBytesTX  = historydb.NewMapFloat64History()
+)
+
+// peerStats holds the counters of one configured peer. Entries are kept
+// across reloads, keyed by group and peer name, so the totals stay monotonic.
+type peerStats struct {
+	model.Counters
+	lastHeard  atomic.Int64 // unix nanoseconds, zero if never heard
+	reconnects atomic.Uint64
+	sendErrors atomic.Uint64
+	failing    atomic.Bool // the last send failed
+}
+
+var (
+	peerStatsMap = make(map[string]*peerStats)
+	peerStatsMu  sync.Mutex
+	statsOnce    sync.Once
+)
+
+// statsOf returns the counters of a group's peer, creating them on first use.
+func statsOf(group, name string) *peerStats {
+	peerStatsMu.Lock()
+	defer peerStatsMu.Unlock()
+	key := group + "/" + name
+	s, ok := peerStatsMap[key]
+	if !ok {
+		s = new(peerStats)
+		peerStatsMap[key] = s
+	}
+	return s
+}
+
+// heard records that something arrived from the peer.
+func (p *remotePeer) heard(bytes int) {
+	p.stats.lastHeard.Store(time.Now().UnixNano())
+	p.stats.AddReceivedBytes(uint64(bytes))
+	Stats.AddReceivedBytes(uint64(bytes))
+}
+
+// lastHeard returns when the peer was last heard from (zero if never).
+func (p *remotePeer) lastHeard() time.Time {
+	if ns := p.stats.lastHeard.Load(); ns != 0 {
+		return time.Unix(0, ns)
+	}
+	return time.Time{}
+}
+
+// sent records a packet (packets is 1) or heartbeat (0) sent to the peer, or
+// a send error. The first error after a success and the recovery are logged.
+func (p *remotePeer) sent(packets, bytes int, err error) {
+	if err != nil {
+		p.stats.sendErrors.Add(1)
+		if !p.stats.failing.Swap(true) {
+			logger.L.Warn("Core peer send failing", zap.String("peer", p.name), zap.Error(err))
+		}
+		return
+	}
+	if p.stats.failing.Swap(false) {
+		logger.L.Info("Core peer send recovered", zap.String("peer", p.name))
+	}
+	p.stats.AddSentPackets(uint64(packets))
+	p.stats.AddSentBytes(uint64(bytes))
+	Stats.AddSentPackets(uint64(packets))
+	Stats.AddSentBytes(uint64(bytes))
+}
+
+// up reports whether the peer link is up: a TCP peer with an open connection,
+// or a UDP peer heard from within downAfter heartbeat intervals.
+func (p *remotePeer) up() bool {
+	if p.tcp {
+		p.connsMu.Lock()
+		defer p.connsMu.Unlock()
+		return len(p.conns) > 0
+	}
+	last := p.lastHeard()
+	return !last.IsZero() && time.Since(last) < downAfter*heartbeatInterval
+}
+
+// heartbeatLoop sends heartbeats to the UDP peers and logs a peer going quiet
+// or coming back.
+func (m *Manager) heartbeatLoop() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	wasUp := make(map[*remotePeer]bool)
+	for {
+		raw := []byte(heartbeat + config.Get().Server.ID + "\r\n")
+		for _, p := range m.peers {
+			if p.tcp {
+				continue
+			}
+			m.sendUDP(p, raw, 0)
+			up := p.up()
+			switch {
+			case wasUp[p] && !up:
+				logger.L.Warn("Core peer went quiet", zap.String("peer", p.name),
+					zap.Time("last_heard", p.lastHeard()))
+			case !wasUp[p] && up:
+				logger.L.Info("Core peer heard", zap.String("peer", p.name))
+			}
+			wasUp[p] = up
+		}
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// statsDaemon refreshes the peers' rates every second and records the history
+// once a minute. It runs for the life of the process, across reloads.
+func statsDaemon() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	lastRecord := time.Now()
+	for now := range ticker.C {
+		Stats.UpdateRates()
+		peerStatsMu.Lock()
+		for _, s := range peerStatsMap {
+			s.UpdateRates()
+		}
+		peerStatsMu.Unlock()
+
+		if now.Sub(lastRecord) < time.Minute {
+			continue
+		}
+		lastRecord = now
+		key := float64(now.UnixNano()) / 1e9
+		snap := Stats.Snapshot()
+		for _, r := range []struct {
+			h *historydb.MapFloat64History
+			v uint64
+		}{
+			{StatsPacketRX, snap.RecvPacketRate},
+			{StatsPacketTX, snap.SendPacketRate},
+			{StatsBytesRX, snap.RecvByteRate},
+			{StatsBytesTX, snap.SendByteRate},
+		} {
+			r.h.Record(key, float64(r.v))
+			r.h.ClearByKey(30 * 24 * 60 * 60)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package uplink
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/APRSCN/aprsgo/internal/pkg/historydb"
+)
+
+// DefaultDupeWindow is the duplicate window when dupe_window is not set.
+const DefaultDupeWindow = 30 * time.Second
+
+// Dupe sources: the paths duplicates are counted by.
+const (
+	SourceClient = "client" // TCP clients and the UDP/HTTP submit endpoints
+	SourceUplink = "uplink"
+	SourcePeer   = "peer" // core peers, all groups
+)
+
+// Dedup is the server-wide duplicate filter in front of the stream: of the
+// copies of a packet arriving within the window by any path (clients, submit
+// endpoints, the uplink, core peers), only the first is distributed. The
+// checker is sharded internally, so concurrent writers rarely contend.
+type Dedup struct {
+	window time.Duration
+	seen   *historydb.DupeChecker
+	dupes  map[string]*atomic.Uint64 // by source; the keys are fixed
+}
+
+// NewDedup creates a duplicate filter with the given window.
+func NewDedup(window time.Duration) *Dedup {
+	return &Dedup{
+		window: window,
+		seen:   historydb.NewDupeChecker(window),
+		dupes: map[string]*atomic.Uint64{
+			SourceClient: new(atomic.Uint64),
+			SourceUplink: new(atomic.Uint64),
+			SourcePeer:   new(atomic.Uint64),
+		},
+	}
+}
+
+// Window returns the duplicate window.
+func (d *Dedup) Window() time.Duration { return d.window }
+
+// Seen reports whether raw was seen within the window, counting it as a dupe
+// of the writer's source; otherwise it records it and returns false.
+func (d *Dedup) Seen(raw, writer string) bool {
+	if !d.seen.Seen(raw) {
+		return false
+	}
+	d.dupes[sourceOf(writer)].Add(1)
+	return true
+}
+
+// sourceOf maps a stream writer tag to its dupe source.
+func sourceOf(writer string) string {
+	switch {
+	case writer == WriterUplink:
+		return SourceUplink
+	case isPeerWriter(writer):
+		return SourcePeer
+	}
+	return SourceClient
+}
+
+// Dupes returns the duplicates dropped so far by source.
+func (d *Dedup) Dupes() map[string]uint64 {
+	out := make(map[string]uint64, len(d.dupes))
+	for s, n := range d.dupes {
+		out[s] = n.Load()
+	}
+	return out
+}
+
+// Cleanup releases the memory of expired entries.
+func (d *Dedup) Cleanup() { d.seen.Cleanup() }
+
+// dedupStateKey is the key of the filter's hashes in a Snapshot.
+const dedupStateKey = "stream"
+
+// Snapshot returns the hashes seen within the window, for saving across
+// restarts.
+func (d *Dedup) Snapshot() map[string]map[uint64]time.Time {
+	return map[string]map[uint64]time.Time{dedupStateKey: d.seen.Snapshot()}
+}
+
+// Restore loads hashes saved by Snapshot.
+func (d *Dedup) Restore(saved map[string]map[uint64]time.Time) {
+	d.seen.Restore(saved[dedupStateKey])
+}
+
+// SetDupeWindow sets the window of the stream's duplicate filter (0 or less:
+// DefaultDupeWindow). A changed window swaps in a new filter that carries over
+// the hashes still within it and the dupe counts, so a reload lets no burst of
+// duplicates through.
+func (ds *DataStream) SetDupeWindow(window time.Duration) {
+	if window <= 0 {
+		window = DefaultDupeWindow
+	}
+	old := ds.dedup.Load()
+	if old != nil && old.window == window {
+		return
+	}
+	d := NewDedup(window)
+	if old != nil {
+		d.seen.Restore(old.seen.Snapshot())
+		for src, n := range old.dupes {
+			d.dupes[src].Store(n.Load())
+		}
+	}
+	ds.dedup.Store(d)
+}
+
+// Dedup returns the stream's duplicate filter.
+func (ds *DataStream) Dedup() *Dedup { return ds.dedup.Load() }
@@ -4,46 +4,48 @@ import (
 	"strings"
 	"time"
 
-	"github.com/APRSCN/aprsgo/internal/pkg/historydb"
+	"github.com/APRSCN/aprsgo/internal/model"
 	"github.com/APRSCN/aprsutils/client"
 	"github.com/APRSCN/aprsutils/parser"
 )
 
-var dupRecords *historydb.DupeChecker
-
-// SweepDupes prunes expired entries from the uplink duplicate checker. It is
+// SweepDupes prunes expired entries from the stream's duplicate filter. It is
 // intended to be called periodically (e.g. from cron) and is a no-op before
 // the uplink daemon has been initialised.
 func SweepDupes() {
-	if dupRecords != nil {
-		dupRecords.Cleanup()
+	if Stream != nil {
+		Stream.Dedup().Cleanup()
 	}
 }
 
-// recvHandler is the packet handler of uplink
-func recvHandler(packet string) {
+// recvHandler is the packet handler of uplink. gs is the receiving group's
+// counters, updated alongside the aggregate Stats, and h the health of the
+// target the packet came from.
+func recvHandler(gs *model.Counters, h *targetHealth, packet string) {
 	now := time.Now()
+	h.packet()
 
 	Stats.AddReceivedPackets(1)
-
-	if dupRecords.Seen(packet) {
-		Stats.AddReceivedDups(1)
-		return
-	}
+	gs.AddReceivedPackets(1)
 
 	parsed, _ := parser.Parse(packet, parser.WithDisableToCallsignValidate())
 	if parsed.To == "" {
 		Stats.AddReceivedErrors(1)
+		gs.AddReceivedErrors(1)
 		return
 	}
 
-	Stream.Write(parsed, WriterUplink)
+	if !Stream.Ingest(parsed, WriterUplink) {
+		Stats.AddReceivedDups(1)
+		gs.AddReceivedDups(1)
+		return
+	}
 	SetLast(now)
 }
 
 // sendHandler relays the distribution stream to one uplink client for the
-// lifetime of that link.
-func sendHandler(c *client.Client, dataCh <-chan StreamData) {
+// lifetime of that link. gs is the link's group counters.
+func sendHandler(gs *model.Counters, c *client.Client, dataCh <-chan StreamData) {
 	for data := range dataCh {
 		// Never relay uplink- or peer-sourced traffic back upstream
 		// (no upstream<->peer cross-feed; no echo to the uplink itself).
@@ -58,6 +60,7 @@ func sendHandler(c *client.Client, dataCh <-chan StreamData) {
 		}
 		// Count packet tx
 		Stats.AddSentPackets(1)
+		gs.AddSentPackets(1)
 	}
 }
 
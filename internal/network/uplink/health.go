@@ -0,0 +1,329 @@
+package uplink
+
+import (
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Health scoring: a target starts at healthBase and loses points for recent
+// failures, slow connects and short sessions, and gains a few for a busy
+// feed. Targets never tried keep the base score, so they rank by config order.
+const (
+	healthBase = 100.0
+	// failurePenalty is lost per failure within failureWindow: a failed
+	// connect, or a session the upstream dropped within shortSession.
+	failurePenalty = 15.0
+	failureWindow  = 30 * time.Minute
+	shortSession   = time.Minute
+	// Up to latencyPenalty is lost for connect latency, one point per
+	// latencyStep.
+	latencyPenalty = 20.0
+	latencyStep    = 50 * time.Millisecond
+	// Up to sessionPenalty is lost while the average session lasts less than
+	// stableSession.
+	sessionPenalty = 20.0
+	stableSession  = 30 * time.Minute
+	// Up to rateBonus is gained for the packet rate of the last session, one
+	// point per rateStep packets per second.
+	rateBonus = 10.0
+	rateStep  = 10.0
+	// ewmaWeight is the weight of the newest sample in the latency and
+	// session-length averages.
+	ewmaWeight = 0.3
+)
+
+// failbackInterval is how often a group connected to another target probes
+// its preferred one.
+var failbackInterval = 2 * time.Minute
+
+// targetHealth tracks how well one uplink target (one address of an uplink
+// host) has served. It is kept across reloads, keyed by protocol and address.
+type targetHealth struct {
+	mu         sync.Mutex
+	retryAt    time.Time     // the target is skipped until then after failing
+	backoff    time.Duration // current retry backoff (zero after a connect)
+	connects   uint64
+	failures   []time.Time   // within failureWindow
+	latency    time.Duration // average connect latency
+	session    time.Duration // average session length
+	sessions   uint64
+	start      time.Time // start of the current session (zero when down)
+	lastRate   float64   // packets per second of the last session
+	packets    atomic.Uint64
+	sessionPkt uint64 // packets at the start of the current session
+}
+
+// health holds the targets' health by key; expanded the targets each group
+// last resolved to, for Health.
+var (
+	health   = make(map[string]*targetHealth)
+	expanded = make(map[string][]uplinkTarget)
+	healthMu sync.Mutex
+)
+
+// key identifies a target in the health table.
+func (up uplinkTarget) key() string {
+	return up.protocol + "/" + up.address()
+}
+
+// healthOf returns the health record of a target, creating it on first use.
+func healthOf(up uplinkTarget) *targetHealth {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	h, ok := health[up.key()]
+	if !ok {
+		h = new(targetHealth)
+		health[up.key()] = h
+	}
+	return h
+}
+
+// pruneHealth drops the health of every target outside the configured groups'
+// last expansion (or configured list, before the first one), so addresses a
+// host no longer resolves to and removed uplinks do not pile up.
+func pruneHealth() {
+	groups := groupedUplinks()
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	for g := range expanded {
+		if _, ok := groups[g]; !ok {
+			delete(expanded, g)
+		}
+	}
+	live := make(map[string]bool)
+	for g, list := range groups {
+		if e, ok := expanded[g]; ok {
+			list = e
+		}
+		for _, up := range list {
+			live[up.key()] = true
+		}
+	}
+	for k := range health {
+		if !live[k] {
+			delete(health, k)
+		}
+	}
+}
+
+// ewma folds sample into avg (avg zero means no samples yet).
+func ewma(avg, sample time.Duration) time.Duration {
+	if avg == 0 {
+		return sample
+	}
+	return time.Duration(ewmaWeight*float64(sample) + (1-ewmaWeight)*float64(avg))
+}
+
+// pruneLocked drops failures older than failureWindow. The caller holds mu.
+func (h *targetHealth) pruneLocked(now time.Time) {
+	cutoff := now.Add(-failureWindow)
+	h.failures = slices.DeleteFunc(h.failures, func(t time.Time) bool { return t.Before(cutoff) })
+}
+
+// failed records a failed connect and backs the target off, doubling its
+// backoff up to maxBackoff.
+func (h *targetHealth) failed() {
+	now := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pruneLocked(now)
+	h.failures = append(h.failures, now)
+	h.backoff = min(max(2*h.backoff, minBackoff), maxBackoff)
+	h.retryAt = now.Add(h.backoff)
+}
+
+// retryAfter returns when the target may be tried again (zero: now).
+func (h *targetHealth) retryAfter() time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.retryAt
+}
+
+// recovered lifts the target's backoff, e.g. after a successful probe.
+func (h *targetHealth) recovered() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.backoff, h.retryAt = 0, time.Time{}
+}
+
+// connected records a successful connect and its latency, starting a session.
+func (h *targetHealth) connected(latency time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.connects++
+	h.backoff, h.retryAt = 0, time.Time{}
+	h.latency = ewma(h.latency, latency)
+	h.start = time.Now()
+	h.sessionPkt = h.packets.Load()
+}
+
+// packet counts a packet received in the current session.
+func (h *targetHealth) packet() { h.packets.Add(1) }
+
+// ended records the end of the current session. dropped is true when the
+// upstream ended it (rather than a shutdown or failback); a dropped session
+// shorter than shortSession counts as a failure.
+func (h *targetHealth) ended(dropped bool) {
+	now := time.Now()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.start.IsZero() {
+		return
+	}
+	d := now.Sub(h.start)
+	if secs := d.Seconds(); secs > 0 {
+		h.lastRate = float64(h.packets.Load()-h.sessionPkt) / secs
+	}
+	if dropped {
+		h.session = ewma(h.session, d)
+		h.sessions++
+		if d < shortSession {
+			h.pruneLocked(now)
+			h.failures = append(h.failures, now)
+		}
+	}
+	h.start = time.Time{}
+}
+
+// score rates the target (higher is better, never below 0).
+func (h *targetHealth) score() float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.scoreLocked(time.Now())
+}
+
+// scoreLocked computes score. The caller holds mu.
+func (h *targetHealth) scoreLocked(now time.Time) float64 {
+	h.pruneLocked(now)
+	s := healthBase - failurePenalty*float64(len(h.failures))
+	s -= min(latencyPenalty, float64(h.latency)/float64(latencyStep))
+	if h.sessions > 0 && h.session < stableSession {
+		s -= sessionPenalty * (1 - float64(h.session)/float64(stableSession))
+	}
+	s += min(rateBonus, h.rateLocked(now)/rateStep)
+	return max(0, s)
+}
+
+// rateLocked returns the packet rate of the current session, or of the last
+// one while down or in a session's first second. The caller holds mu.
+func (h *targetHealth) rateLocked(now time.Time) float64 {
+	if !h.start.IsZero() {
+		if secs := now.Sub(h.start).Seconds(); secs >= 1 {
+			return float64(h.packets.Load()-h.sessionPkt) / secs
+		}
+	}
+	return h.lastRate
+}
+
+// ranked returns the targets in the order to try them: a preferred target
+// first, then by score, ties keeping the configured order.
+func ranked(targets []uplinkTarget) []uplinkTarget {
+	type scored struct {
+		up    uplinkTarget
+		score float64
+	}
+	list := make([]scored, len(targets))
+	for i, up := range targets {
+		list[i] = scored{up: up, score: healthOf(up).score()}
+	}
+	slices.SortStableFunc(list, func(a, b scored) int {
+		switch {
+		case a.up.preferred != b.up.preferred:
+			return boolOrder(a.up.preferred, b.up.preferred)
+		case a.score > b.score:
+			return -1
+		case a.score < b.score:
+			return 1
+		}
+		return 0
+	})
+	out := make([]uplinkTarget, len(list))
+	for i, s := range list {
+		out[i] = s.up
+	}
+	return out
+}
+
+// preferredOf returns the preferred targets (addresses) of a group.
+func preferredOf(targets []uplinkTarget) []uplinkTarget {
+	var out []uplinkTarget
+	for _, up := range targets {
+		if up.preferred {
+			out = append(out, up)
+		}
+	}
+	return out
+}
+
+// probe reports whether a TCP target accepts connections again.
+func probe(up uplinkTarget) bool {
+	conn, err := net.DialTimeout("tcp", up.address(), 5*time.Second)
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// TargetHealth is the health of one configured uplink target.
+type TargetHealth struct {
+	Group      string
+	Name       string
+	Protocol   string
+	Host       string
+	Addr       string // resolved address (empty when the host did not resolve)
+	Port       int
+	Preferred  bool
+	Up         bool
+	Score      float64
+	Connects   uint64
+	Failures   int           // within the failure window
+	Latency    time.Duration // average connect latency
+	Session    time.Duration // average session length
+	PacketRate float64       // packets per second of the current or last session
+}
+
+// Health returns the health of every uplink target (each address of the
+// configured hosts, as last resolved), by group in the order they would be
+// tried.
+func Health() []TargetHealth {
+	groups := groupedUplinks()
+	healthMu.Lock()
+	for g := range groups {
+		if list, ok := expanded[g]; ok {
+			groups[g] = list
+		}
+	}
+	healthMu.Unlock()
+	names := make([]string, 0, len(groups))
+	for g := range groups {
+		names = append(names, g)
+	}
+	slices.Sort(names)
+
+	var out []TargetHealth
+	now := time.Now()
+	for _, g := range names {
+		for _, up := range ranked(groups[g]) {
+			h := healthOf(up)
+			h.mu.Lock()
+			th := TargetHealth{
+				Group: g, Name: up.name, Protocol: up.protocol, Host: up.host, Addr: up.addr, Port: up.port,
+				Preferred:  up.preferred,
+				Up:         !h.start.IsZero(),
+				Score:      h.scoreLocked(now),
+				Connects:   h.connects,
+				Failures:   len(h.failures),
+				Latency:    h.latency,
+				Session:    h.session,
+				PacketRate: h.rateLocked(now),
+			}
+			h.mu.Unlock()
+			out = append(out, th)
+		}
+	}
+	return out
+}
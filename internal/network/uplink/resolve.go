@@ -0,0 +1,91 @@
+package uplink
+
+import (
+	"context"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/APRSCN/aprsgo/internal/infra/logger"
+	"go.uber.org/zap"
+)
+
+// Address-family policies of an uplink.
+const (
+	familyIPv4       = "ipv4"        // IPv4 addresses only
+	familyIPv6       = "ipv6"        // IPv6 addresses only
+	familyPreferIPv6 = "prefer-ipv6" // IPv6 addresses before IPv4 ones
+)
+
+// resolveTimeout bounds one uplink host lookup.
+const resolveTimeout = 5 * time.Second
+
+// lookupIP resolves a host for network "ip", "ip4" or "ip6". It is a variable
+// so tests can fake DNS.
+var lookupIP = func(ctx context.Context, network, host string) ([]net.IP, error) {
+	return net.DefaultResolver.LookupIP(ctx, network, host)
+}
+
+// expand resolves the targets of a group into one target per address allowed
+// by its family policy, so every server behind a round-robin name is tried
+// (and backs off) on its own. A target whose host does not resolve is kept
+// as is and fails on connect. The result is also recorded for Health, and the
+// health of addresses it no longer holds is dropped.
+func expand(group string, targets []uplinkTarget) []uplinkTarget {
+	var out []uplinkTarget
+	for _, up := range targets {
+		out = append(out, resolve(up)...)
+	}
+	healthMu.Lock()
+	expanded[group] = out
+	healthMu.Unlock()
+	pruneHealth()
+	return out
+}
+
+// resolve returns one target per address of up.host allowed by its family.
+func resolve(up uplinkTarget) []uplinkTarget {
+	network := "ip"
+	switch up.family {
+	case familyIPv4:
+		network = "ip4"
+	case familyIPv6:
+		network = "ip6"
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	ips, err := lookupIP(ctx, network, up.host)
+	if err != nil || len(ips) == 0 {
+		logger.L.Warn("Uplink host does not resolve", zap.String("host", up.host),
+			zap.String("family", up.family), zap.Error(err))
+		return []uplinkTarget{up}
+	}
+	if up.family == familyPreferIPv6 {
+		slices.SortStableFunc(ips, func(a, b net.IP) int {
+			return boolOrder(a.To4() == nil, b.To4() == nil)
+		})
+	}
+	out := make([]uplinkTarget, 0, len(ips))
+	seen := make(map[string]bool)
+	for _, ip := range ips {
+		if seen[ip.String()] {
+			continue
+		}
+		seen[ip.String()] = true
+		t := up
+		t.addr = ip.String()
+		out = append(out, t)
+	}
+	return out
+}
+
+// boolOrder orders true before false.
+func boolOrder(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return -1
+	}
+	return 1
+}
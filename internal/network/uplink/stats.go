@@ -1,6 +1,7 @@
 package uplink
 
 import (
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -14,6 +15,44 @@ import (
 // race.
 var Stats = new(model.Counters)
 
+// groupStats holds per-group counters alongside the aggregate Stats, so each
+// uplink group's traffic can be reported separately (e.g. by the metrics
+// exporter). Entries are created on first use and kept across reloads so the
+// cumulative totals stay monotonic.
+var (
+	groupStats   = make(map[string]*model.Counters)
+	groupStatsMu sync.RWMutex
+)
+
+// statsFor returns the counters for an uplink group, creating them on first
+// use.
+func statsFor(group string) *model.Counters {
+	groupStatsMu.RLock()
+	c, ok := groupStats[group]
+	groupStatsMu.RUnlock()
+	if ok {
+		return c
+	}
+	groupStatsMu.Lock()
+	defer groupStatsMu.Unlock()
+	if c, ok = groupStats[group]; !ok {
+		c = new(model.Counters)
+		groupStats[group] = c
+	}
+	return c
+}
+
+// GroupStats returns a snapshot of the per-group counters keyed by group name.
+func GroupStats() map[string]model.Statistics {
+	groupStatsMu.RLock()
+	defer groupStatsMu.RUnlock()
+	out := make(map[string]model.Statistics, len(groupStats))
+	for g, c := range groupStats {
+		out[g] = c.Snapshot()
+	}
+	return out
+}
+
 // lastRX holds the time the most recent packet was received from the uplink.
 // It is read by the status handler and written by the receive handler, so it
 // is published through an atomic pointer to avoid a data race.
@@ -44,6 +83,11 @@ func rate() {
 			return
 		case <-ticker.C:
 			Stats.UpdateRates()
+			groupStatsMu.RLock()
+			for _, c := range groupStats {
+				c.UpdateRates()
+			}
+			groupStatsMu.RUnlock()
 		}
 	}
 }
@@ -2,6 +2,7 @@ package uplink
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"github.com/APRSCN/aprsgo/internal/pkg/historydb"
 	"github.com/APRSCN/aprsutils/parser"
@@ -39,26 +40,46 @@ type DataStream struct {
 	subscribers []chan StreamData
 	mu          sync.RWMutex
 	bufferSize  int
+	dedup       atomic.Pointer[Dedup] // duplicate filter of Ingest
 }
 
 // NewDataStream creates a new data Stream
 func NewDataStream(bufferSize int) *DataStream {
-	return &DataStream{
+	ds := &DataStream{
 		subscribers: make([]chan StreamData, 0),
 		bufferSize:  bufferSize,
 	}
+	ds.dedup.Store(NewDedup(DefaultDupeWindow))
+	return ds
 }
 
-// Write data to Stream.
+// Ingest is the entry point of every path into the stream (clients, submit
+// endpoints, the uplink, core peers): the first copy of a packet within the
+// duplicate window is written to the stream, later copies are only published
+// as dupes for dupefeed ports. It reports whether the packet was new.
+func (ds *DataStream) Ingest(data parser.Parsed, writer string) bool {
+	if ds.Dedup().Seen(data.Raw, writer) {
+		ds.WriteDupe(data, writer)
+		return false
+	}
+	ds.Write(data, writer)
+	return true
+}
+
+// Write data to Stream, bypassing the duplicate filter (paths use Ingest;
+// capture replay writes recorded packets as they were).
 //
 // This is the single choke point through which every accepted packet flows, so
 // it is also where we record station positions for position-aware filters
-// (m/, f/, ranged t/).
+// (m/, f/, ranged t/) and the per-station packet history.
 func (ds *DataStream) Write(data parser.Parsed, writer string) {
 	// Record last-known position for the source station (and the inner source
 	// of third-party traffic) so range filters can resolve it.
 	recordPosition(&data)
 
+	// Remember the packet for the station last-heard API.
+	recordPacket(&data)
+
 	ds.broadcast(StreamData{Data: data, Writer: writer})
 }
 
@@ -93,6 +114,12 @@ func recordPosition(p *parser.Parsed) {
 	}
 }
 
+// recordPacket stores a packet in the packet history under its source callsign
+// and, for objects/items, under the object name as well.
+func recordPacket(p *parser.Parsed) {
+	historydb.Packets.Record(p.Raw, p.From, p.ObjectName)
+}
+
 // Subscribe a Stream
 func (ds *DataStream) Subscribe() (<-chan StreamData, func()) {
 	ds.mu.Lock()
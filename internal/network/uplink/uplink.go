@@ -2,7 +2,11 @@ package uplink
 
 import (
 	"fmt"
+	"net"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/APRSCN/aprsgo/internal/infra/config"
@@ -78,12 +82,46 @@ const (
 
 // uplinkTarget is one configured uplink endpoint within a group.
 type uplinkTarget struct {
+	name     string
 	mode     string
 	protocol string
 	host     string
 	port     int
+	// family is the address-family policy for host; addr the address it
+	// resolved to, which is dialled instead of host when set.
+	family string
+	addr   string
+
+	// Login line: callsign and passcode, software and version announced
+	// with vers, and the requested filter.
+	login    string
+	passcode string
+	software string
+	version  string
+	filter   string
+
+	// preferred marks the group's primary: it is tried first and failed back
+	// to when it recovers.
+	preferred bool
 }
 
+// dialHost returns the resolved address, or the host while unresolved.
+func (up uplinkTarget) dialHost() string {
+	if up.addr != "" {
+		return up.addr
+	}
+	return up.host
+}
+
+// address returns the host:port to dial.
+func (up uplinkTarget) address() string {
+	return net.JoinHostPort(up.dialHost(), strconv.Itoa(up.port))
+}
+
+// readOnly reports whether the uplink only receives: an "ro" uplink is not
+// sent the local traffic.
+func (up uplinkTarget) readOnly() bool { return up.mode == "ro" }
+
 // GetClient returns one active uplink client (any group), or nil if none is
 // connected. Retained for the single-link status view.
 func GetClient() *client.Client {
@@ -131,9 +169,23 @@ func groupedUplinks() map[string][]uplinkTarget {
 		if g == "" {
 			g = "default"
 		}
-		groups[g] = append(groups[g], uplinkTarget{
-			mode: up.Mode, protocol: up.Protocol, host: up.Host, port: up.Port,
-		})
+		t := uplinkTarget{
+			name: up.Name, mode: up.Mode, protocol: up.Protocol, host: up.Host, port: up.Port,
+			family:    up.Family,
+			preferred: up.Preferred,
+			login:     config.Get().Server.ID,
+			passcode:  config.Get().Server.Passcode,
+			software:  meta.ENName,
+			version:   fmt.Sprintf("%s/%s", meta.Nickname, meta.Version),
+			filter:    strings.TrimSpace(up.Filter),
+		}
+		if up.Login != "" {
+			t.login, t.passcode = up.Login, up.Passcode
+		}
+		if software, version, ok := strings.Cut(strings.TrimSpace(up.Vers), " "); ok {
+			t.software, t.version = software, strings.TrimSpace(version)
+		}
+		groups[g] = append(groups[g], t)
 	}
 	return groups
 }
@@ -142,9 +194,7 @@ func groupedUplinks() map[string][]uplinkTarget {
 func Init() {
 	// Init Stream
 	Stream = NewDataStream(100)
-
-	// Init dupRecords
-	dupRecords = historydb.NewDupeChecker(time.Second)
+	Stream.SetDupeWindow(time.Duration(config.Get().Server.DupeWindow) * time.Second)
 
 	// Init stats
 	StatsPacketRX = historydb.NewMapFloat64History()
@@ -179,15 +229,17 @@ func startManagers() {
 	}
 }
 
-// manageGroup runs the connection lifecycle for one uplink group: it rotates
-// through the group's targets, connecting to the first that answers, pumping
-// the distribution stream to it, and reconnecting with exponential backoff when
-// the link drops. At most one link in the group is active at a time.
+// manageGroup runs the connection lifecycle for one uplink group: it resolves
+// the group's hosts into one target per address, tries them best first (the
+// preferred uplink, then by health score), connecting to the first that
+// answers and pumping the distribution stream to it, and reconnects when the
+// link drops. A target that fails is skipped for its own exponential backoff;
+// the group only waits when every target is backing off. At most one link in
+// the group is active at a time.
 func manageGroup(group string, targets []uplinkTarget) {
 	defer mgrWG.Done()
 
 	stop := currentStop() // capture locally; Reload re-arms the package var
-	backoff := minBackoff
 
 	for {
 		select {
@@ -204,46 +256,56 @@ func manageGroup(group string, targets []uplinkTarget) {
 			continue
 		}
 
+		list := ranked(expand(group, targets))
 		connected := false
-		for _, up := range targets {
+		var next time.Time // earliest retry of a backing-off target
+		for _, up := range list {
 			select {
 			case <-stop:
 				return
 			default:
 			}
 
-			if tryUplink(group, up) {
+			h := healthOf(up)
+			if at := h.retryAfter(); time.Now().Before(at) {
+				if next.IsZero() || at.Before(next) {
+					next = at
+				}
+				continue
+			}
+			if tryUplink(group, up, list) {
 				connected = true
-				backoff = minBackoff // reset backoff after a good session
 				break
 			}
+			if at := h.retryAfter(); next.IsZero() || at.Before(next) {
+				next = at
+			}
 		}
 
 		if !connected {
+			backoff := min(max(time.Until(next), minBackoff), maxBackoff)
 			logger.L.Warn("All uplinks in group unavailable, backing off",
 				zap.String("group", group), zap.Duration("backoff", backoff))
 			if !wait.SleepOrStop(stop, backoff) {
 				return
 			}
-			backoff *= 2
-			if backoff > maxBackoff {
-				backoff = maxBackoff
-			}
 		}
 	}
 }
 
 // tryUplink connects to a single uplink and, on success, blocks until the link
 // drops. It returns true if a connection was established (regardless of how it
-// later ended), false if the initial connect failed.
-func tryUplink(group string, up uplinkTarget) bool {
+// later ended), false if the initial connect failed. targets is the whole
+// group, whose preferred target a link to another one fails back to.
+func tryUplink(group string, up uplinkTarget, targets []uplinkTarget) bool {
+	gs := statsFor(group)
+	h := healthOf(up)
 	opts := []client.Option{
 		client.WithBufSize(config.Get().Server.BuffSize * 1024),
 		client.WithLogger(&ZapLogger{logger: logger.L}),
-		client.WithSoftwareAndVersion(
-			meta.ENName, fmt.Sprintf("%s/%s", meta.Nickname, meta.Version),
-		),
-		client.WithHandler(recvHandler),
+		client.WithSoftwareAndVersion(up.software, up.version),
+		client.WithFilter(up.filter),
+		client.WithHandler(func(packet string) { recvHandler(gs, h, packet) }),
 		// Reconnection contract: the manager owns reconnection, so the
 		// client's internal retry is disabled (WithRetryTimes(0)). With retry
 		// disabled the client does not reconnect itself; instead, when the
@@ -265,41 +327,94 @@ func tryUplink(group string, up uplinkTarget) bool {
 	}
 
 	c := client.NewClient(
-		config.Get().Server.ID,
-		config.Get().Server.Passcode,
+		up.login,
+		up.passcode,
 		client.Mode(up.mode), client.Protocol(up.protocol),
-		up.host, up.port,
+		up.dialHost(), up.port,
 		opts...,
 	)
 
+	start := time.Now()
 	if err := c.Connect(); err != nil {
+		h.failed()
 		logger.L.Debug("Uplink connect failed", zap.String("group", group),
-			zap.String("host", up.host), zap.Int("port", up.port), zap.Error(err))
+			zap.String("host", up.host), zap.String("addr", up.addr), zap.Int("port", up.port), zap.Error(err))
 		return false
 	}
+	h.connected(time.Since(start))
 
 	setClient(group, c)
 	logger.L.Info("Uplink connected", zap.String("group", group),
-		zap.String("host", up.host), zap.Int("port", up.port), zap.String("mode", up.mode))
+		zap.String("host", up.host), zap.String("addr", up.addr), zap.Int("port", up.port),
+		zap.String("mode", up.mode))
+
+	// Pump the distribution stream to this uplink for the duration of the
+	// link, unless it is read-only.
+	closeFn := func() {}
+	if !up.readOnly() {
+		var ch <-chan StreamData
+		ch, closeFn = Stream.Subscribe()
+		go sendHandler(gs, c, ch)
+	}
 
-	// Pump the distribution stream to this uplink for the duration of the link.
-	ch, closeFn := Stream.Subscribe()
-	go sendHandler(c, ch)
+	// Fail back once the group's preferred target answers again.
+	var failedBack atomic.Bool
+	sessionDone := make(chan struct{})
+	if pref := preferredOf(targets); len(pref) > 0 && !up.preferred && pref[0].protocol == "tcp" {
+		go failback(group, pref, c, &failedBack, sessionDone)
+	}
 
 	// Wait until the client is closed (by remote drop or shutdown).
 	c.Wait()
+	close(sessionDone)
 
+	select {
+	case <-currentStop():
+		h.ended(false)
+	default:
+		h.ended(!failedBack.Load())
+	}
 	closeFn()
 	setClient(group, nil)
 	logger.L.Info("Uplink disconnected", zap.String("group", group),
-		zap.String("host", up.host), zap.Int("port", up.port))
+		zap.String("host", up.host), zap.String("addr", up.addr), zap.Int("port", up.port))
 	return true
 }
 
+// failback probes the preferred targets (addresses) of a group every
+// failbackInterval while the group's link c runs to another target, and
+// closes c (marking failedBack) once one accepts connections again, lifting
+// its backoff so the manager reconnects to it. It returns when the session
+// ends.
+func failback(group string, pref []uplinkTarget, c *client.Client, failedBack *atomic.Bool, done <-chan struct{}) {
+	ticker := time.NewTicker(failbackInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			for _, up := range pref {
+				if !probe(up) {
+					continue
+				}
+				logger.L.Info("Preferred uplink recovered, failing back", zap.String("group", group),
+					zap.String("host", up.host), zap.String("addr", up.addr), zap.Int("port", up.port))
+				healthOf(up).recovered()
+				failedBack.Store(true)
+				c.Close()
+				return
+			}
+		}
+	}
+}
+
 // Reload restarts the uplink managers after a configuration change (e.g.
 // SIGHUP), so new uplink targets take effect.
 func Reload() {
 	Stop()
+	Stream.SetDupeWindow(time.Duration(config.Get().Server.DupeWindow) * time.Second)
+	pruneHealth()
 
 	// Re-arm and start fresh managers plus the stats goroutines (all of which
 	// exited when the stop channel was closed by Stop). All are tracked by
@@ -1,12 +1,21 @@
 package uplink
 
 import (
+	"bufio"
+	"context"
+	"io"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	config2 "github.com/APRSCN/aprsgo/internal/infra/config"
 	"github.com/APRSCN/aprsgo/internal/infra/logger"
+	"github.com/APRSCN/aprsutils/parser"
 	"go.uber.org/zap"
 )
 
@@ -77,3 +86,410 @@ func TestUplinkConcurrentReload(t *testing.T) {
 		t.Fatal("Stop did not return within 5s after concurrent reloads")
 	}
 }
+
+// TestUplinkLoginOptions verifies that each uplink logs in with its own
+// callsign, passcode, vers and filter (or the server's defaults), and that a
+// read-only uplink is not sent the local traffic.
+func TestUplinkLoginOptions(t *testing.T) {
+	logger.L = zap.NewNop()
+	accept := func() (net.Listener, <-chan net.Conn) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = ln.Close() })
+		ch := make(chan net.Conn, 1)
+		go func() {
+			if conn, err := ln.Accept(); err == nil {
+				t.Cleanup(func() { _ = conn.Close() })
+				ch <- conn
+			}
+		}()
+		return ln, ch
+	}
+	roLn, roConn := accept()
+	fullLn, fullConn := accept()
+
+	c := emptyUplinkConfig()
+	c.Server.Passcode = "12345"
+	c.Server.Uplinks = slices.Grow(c.Server.Uplinks, 2)[:2]
+	ro, full := &c.Server.Uplinks[0], &c.Server.Uplinks[1]
+	ro.Name, ro.Mode, ro.Protocol, ro.Host, ro.Port = "regional", "ro", "tcp", "127.0.0.1", roLn.Addr().(*net.TCPAddr).Port
+	ro.Group, ro.Login, ro.Filter, ro.Vers = "regional", "N0CALL-R", "r/60/25/100", "tester 1.0"
+	full.Name, full.Mode, full.Protocol, full.Host, full.Port = "core", "igate", "tcp", "127.0.0.1", fullLn.Addr().(*net.TCPAddr).Port
+	config2.Set(c)
+
+	Init()
+	t.Cleanup(Stop)
+
+	login := func(ch <-chan net.Conn) (net.Conn, *bufio.Reader, string) {
+		select {
+		case conn := <-ch:
+			_ = conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+			r := bufio.NewReader(conn)
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatalf("read login: %v", err)
+			}
+			return conn, r, strings.TrimSpace(line)
+		case <-time.After(5 * time.Second):
+			t.Fatal("uplink did not connect")
+		}
+		return nil, nil, ""
+	}
+	roC, roR, roLine := login(roConn)
+	if want := "user N0CALL-R vers tester 1.0 filter r/60/25/100"; roLine != want {
+		t.Errorf("ro login = %q, want %q", roLine, want)
+	}
+	_, fullR, fullLine := login(fullConn)
+	if !strings.HasPrefix(fullLine, "user TESTING pass 12345 vers ") || strings.Contains(fullLine, "filter") {
+		t.Errorf("default login = %q, want the server id and passcode without a filter", fullLine)
+	}
+
+	// Wait until both links pump the stream, then inject local traffic.
+	pkt, err := parser.Parse("N0CALL>APRS,TCPIP*,qAC,TESTING:>local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for len(Clients()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	Stream.Write(pkt, "client")
+	if line, err := fullR.ReadString('\n'); err != nil || !strings.Contains(line, ">local") {
+		t.Errorf("full uplink got %q, %v; want the local packet", line, err)
+	}
+	_ = roC.SetReadDeadline(time.Now().Add(300 * time.Millisecond))
+	for {
+		line, err := roR.ReadString('\n')
+		if strings.Contains(line, ">local") {
+			t.Fatal("read-only uplink was sent local traffic")
+		}
+		if err != nil {
+			break
+		}
+	}
+}
+
+// TestRankedByHealth verifies that targets are tried preferred first, then by
+// health score, with untried targets keeping their configured order.
+func TestRankedByHealth(t *testing.T) {
+	health = make(map[string]*targetHealth)
+	a := uplinkTarget{name: "a", protocol: "tcp", host: "192.0.2.1", port: 10152}
+	b := uplinkTarget{name: "b", protocol: "tcp", host: "192.0.2.2", port: 10152}
+	c := uplinkTarget{name: "c", protocol: "tcp", host: "192.0.2.3", port: 10152}
+	d := uplinkTarget{name: "d", protocol: "tcp", host: "192.0.2.4", port: 10152}
+
+	names := func(list []uplinkTarget) string {
+		var out []string
+		for _, up := range list {
+			out = append(out, up.name)
+		}
+		return strings.Join(out, ",")
+	}
+	if got := names(ranked([]uplinkTarget{a, b, c, d})); got != "a,b,c,d" {
+		t.Fatalf("untried order = %s, want a,b,c,d", got)
+	}
+
+	healthOf(a).failed()                          // a flaky first entry
+	healthOf(b).connected(500 * time.Millisecond) // slow connect
+	healthOf(b).ended(false)
+	healthOf(c).connected(10 * time.Millisecond) // session dropped at once
+	healthOf(c).ended(true)
+	if got := names(ranked([]uplinkTarget{a, b, c, d})); got != "d,b,a,c" {
+		t.Errorf("ranked = %s, want d,b,a,c", got)
+	}
+
+	c.preferred = true
+	if got := names(ranked([]uplinkTarget{a, b, c, d})); got != "c,d,b,a" {
+		t.Errorf("ranked with c preferred = %s, want c,d,b,a", got)
+	}
+}
+
+// TestUplinkFailback verifies that a group connected to a backup target
+// returns to its preferred target once that accepts connections again.
+func TestUplinkFailback(t *testing.T) {
+	logger.L = zap.NewNop()
+	health = make(map[string]*targetHealth)
+	defer func(d time.Duration) { failbackInterval = d }(failbackInterval)
+	failbackInterval = 50 * time.Millisecond
+
+	// The preferred target is down at first: reserve a port and free it.
+	down, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	prefPort := down.Addr().(*net.TCPAddr).Port
+	_ = down.Close()
+	backup, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = backup.Close() })
+
+	c := emptyUplinkConfig()
+	c.Server.Uplinks = slices.Grow(c.Server.Uplinks, 2)[:2]
+	pref, bak := &c.Server.Uplinks[0], &c.Server.Uplinks[1]
+	pref.Name, pref.Mode, pref.Protocol, pref.Host, pref.Port, pref.Preferred = "primary", "full", "tcp", "127.0.0.1", prefPort, true
+	bak.Name, bak.Mode, bak.Protocol, bak.Host, bak.Port = "backup", "full", "tcp", "127.0.0.1", backup.Addr().(*net.TCPAddr).Port
+	config2.Set(c)
+
+	Init()
+	t.Cleanup(Stop)
+
+	_ = backup.(*net.TCPListener).SetDeadline(time.Now().Add(5 * time.Second))
+	bconn, err := backup.Accept()
+	if err != nil {
+		t.Fatalf("backup not connected: %v", err)
+	}
+	defer bconn.Close()
+
+	// Bring the preferred target up: the group must fail back to it.
+	up, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(prefPort)))
+	if err != nil {
+		t.Skipf("preferred port taken meanwhile: %v", err)
+	}
+	t.Cleanup(func() { _ = up.Close() })
+	_ = up.(*net.TCPListener).SetDeadline(time.Now().Add(5 * time.Second))
+	for {
+		// The probe connects without logging in; wait for the uplink.
+		conn, err := up.Accept()
+		if err != nil {
+			t.Fatalf("no failback to the preferred target: %v", err)
+		}
+		defer conn.Close()
+		_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+		if line, _ := bufio.NewReader(conn).ReadString('\n'); strings.HasPrefix(line, "user ") {
+			// Let the link receive a packet, so it is fully up before the
+			// test stops it.
+			_, _ = conn.Write([]byte("N0CALL>APRS,qAS,CORE:>up\r\n"))
+			break
+		}
+	}
+	prefHealth := healthOf(uplinkTarget{protocol: "tcp", host: "127.0.0.1", port: prefPort})
+	for deadline := time.Now().Add(3 * time.Second); prefHealth.packets.Load() == 0; {
+		if time.Now().After(deadline) {
+			t.Fatal("preferred link received nothing")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_ = bconn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	if _, err := io.ReadAll(bconn); err != nil {
+		t.Errorf("backup link not closed after failback: %v", err)
+	}
+	for _, h := range Health() {
+		if h.Name == "backup" && h.Failures != 0 {
+			t.Errorf("failback counted as a failure of the backup: %+v", h)
+		}
+	}
+}
+
+// TestResolveFamilies verifies that an uplink host expands into one target
+// per address its family policy allows.
+func TestResolveFamilies(t *testing.T) {
+	logger.L = zap.NewNop()
+	defer func(f func(context.Context, string, string) ([]net.IP, error)) { lookupIP = f }(lookupIP)
+	lookupIP = func(_ context.Context, network, host string) ([]net.IP, error) {
+		all := []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1"), net.ParseIP("192.0.2.2")}
+		var out []net.IP
+		for _, ip := range all {
+			if network == "ip" || (network == "ip4") == (ip.To4() != nil) {
+				out = append(out, ip)
+			}
+		}
+		return out, nil
+	}
+
+	addrs := func(family string) string {
+		var out []string
+		for _, up := range resolve(uplinkTarget{host: "rotate.test", port: 10152, family: family}) {
+			out = append(out, up.addr)
+		}
+		return strings.Join(out, ",")
+	}
+	cases := map[string]string{
+		"":            "192.0.2.1,2001:db8::1,192.0.2.2",
+		"ipv4":        "192.0.2.1,192.0.2.2",
+		"ipv6":        "2001:db8::1",
+		"prefer-ipv6": "2001:db8::1,192.0.2.1,192.0.2.2",
+	}
+	for family, want := range cases {
+		if got := addrs(family); got != want {
+			t.Errorf("family %q: addresses %s, want %s", family, got, want)
+		}
+	}
+}
+
+// TestUplinkSkipsDeadAddress verifies that a dead server behind a round-robin
+// name does not make the group back off: the next address is tried at once.
+func TestUplinkSkipsDeadAddress(t *testing.T) {
+	logger.L = zap.NewNop()
+	health = make(map[string]*targetHealth)
+	defer func(f func(context.Context, string, string) ([]net.IP, error)) { lookupIP = f }(lookupIP)
+	lookupIP = func(context.Context, string, string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("127.0.0.2"), net.ParseIP("127.0.0.1")}, nil
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	c := emptyUplinkConfig()
+	c.Server.Uplinks = slices.Grow(c.Server.Uplinks, 1)[:1]
+	up := &c.Server.Uplinks[0]
+	up.Name, up.Mode, up.Protocol, up.Host, up.Port = "rotate", "full", "tcp", "rotate.test", ln.Addr().(*net.TCPAddr).Port
+	config2.Set(c)
+
+	Init()
+	t.Cleanup(Stop)
+
+	// Well within minBackoff: the live address is tried right after the
+	// dead one fails.
+	_ = ln.(*net.TCPListener).SetDeadline(time.Now().Add(minBackoff / 2))
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("live address not tried at once: %v", err)
+	}
+	defer conn.Close()
+	_, _ = conn.Write([]byte("N0CALL>APRS,qAS,CORE:>up\r\n"))
+
+	var dead, live *TargetHealth
+	for deadline := time.Now().Add(3 * time.Second); live == nil || !live.Up; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("live target not up: %+v", Health())
+		}
+		for _, h := range Health() {
+			switch h.Addr {
+			case "127.0.0.2":
+				dead = &h
+			case "127.0.0.1":
+				live = &h
+			}
+		}
+	}
+	if dead == nil || dead.Failures != 1 || dead.Host != "rotate.test" {
+		t.Errorf("dead address health = %+v, want one failure of rotate.test", dead)
+	}
+	h := healthOf(uplinkTarget{protocol: "tcp", addr: "127.0.0.1", port: up.Port})
+	for deadline := time.Now().Add(3 * time.Second); h.packets.Load() == 0; time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("live link received nothing")
+		}
+	}
+}
+
+// TestHealthPruned verifies that the health table follows the targets: an
+// address the host stops resolving to and a removed uplink are forgotten.
+func TestHealthPruned(t *testing.T) {
+	logger.L = zap.NewNop()
+	health = make(map[string]*targetHealth)
+	expanded = make(map[string][]uplinkTarget)
+	defer func(f func(context.Context, string, string) ([]net.IP, error)) { lookupIP = f }(lookupIP)
+	ips := []net.IP{net.ParseIP("127.0.0.2"), net.ParseIP("127.0.0.1")}
+	lookupIP = func(context.Context, string, string) ([]net.IP, error) { return ips, nil }
+
+	c := emptyUplinkConfig()
+	c.Server.Uplinks = slices.Grow(c.Server.Uplinks, 1)[:1]
+	up := &c.Server.Uplinks[0]
+	up.Name, up.Mode, up.Protocol, up.Host, up.Port = "rotate", "full", "tcp", "rotate.test", 14580
+	config2.Set(c)
+
+	for _, target := range expand("default", groupedUplinks()["default"]) {
+		healthOf(target).failed()
+	}
+	if len(health) != 2 {
+		t.Fatalf("health has %d entries, want 2", len(health))
+	}
+
+	ips = ips[1:]
+	expand("default", groupedUplinks()["default"])
+	if _, ok := health["tcp/127.0.0.1:14580"]; len(health) != 1 || !ok {
+		t.Errorf("health after re-resolving = %v, want only 127.0.0.1", slices.Collect(maps.Keys(health)))
+	}
+
+	config2.Set(emptyUplinkConfig())
+	pruneHealth()
+	if len(health) != 0 || len(expanded) != 0 {
+		t.Errorf("health after removing the uplink = %v, expanded = %v, want none", health, expanded)
+	}
+}
+
+// TestStreamIngestDedup verifies the stream's duplicate filter: a copy of a
+// packet seen by any path is published only as a dupe, counted by the source
+// of the copy, and the filter state survives a snapshot and restore.
+func TestStreamIngestDedup(t *testing.T) {
+	ds := NewDataStream(10)
+	ch, unsub := ds.Subscribe()
+	defer unsub()
+
+	first, err := parser.Parse("DEDUP1>APRS,TCPIP*:>dedup test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	copyOf, err := parser.Parse("DEDUP1>APRS,TCPIP*,qAS,PEER:>dedup test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ds.Ingest(first, "DEDUP1") {
+		t.Fatal("first copy rejected")
+	}
+	if ds.Ingest(copyOf, WriterPeerPrefix+"PEER") {
+		t.Fatal("peer copy accepted")
+	}
+	if ds.Ingest(copyOf, WriterUplink) {
+		t.Fatal("uplink copy accepted")
+	}
+	for i, wantDupe := range []bool{false, true, true} {
+		select {
+		case data := <-ch:
+			if data.Dupe != wantDupe {
+				t.Errorf("item %d dupe = %v, want %v", i, data.Dupe, wantDupe)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("item %d not published", i)
+		}
+	}
+	want := map[string]uint64{SourceClient: 0, SourceUplink: 1, SourcePeer: 1}
+	if got := ds.Dedup().Dupes(); !maps.Equal(got, want) {
+		t.Errorf("dupes = %v, want %v", got, want)
+	}
+
+	snap := ds.Dedup().Snapshot()
+	restored := NewDataStream(10)
+	restored.Dedup().Restore(snap)
+	if restored.Ingest(first, "DEDUP1") {
+		t.Error("restored filter accepted a saved packet")
+	}
+	other, err := parser.Parse("DEDUP2>APRS,TCPIP*:>dedup test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !restored.Ingest(other, "DEDUP2") {
+		t.Error("restored filter rejected another station's packet")
+	}
+
+	// A reload with a new dupe_window keeps what the filter has seen.
+	logger.L = zap.NewNop()
+	c := emptyUplinkConfig()
+	c.Server.DupeWindow = 60
+	config2.Set(c)
+	prev := Stream
+	Stream = ds
+	armStop()
+	Reload()
+	t.Cleanup(func() {
+		Stop()
+		Stream = prev
+	})
+	if ds.Dedup().Window() != time.Minute {
+		t.Fatalf("window after reload = %v, want 1m", ds.Dedup().Window())
+	}
+	if ds.Ingest(first, "DEDUP1") {
+		t.Error("packet seen before the reload accepted")
+	}
+	if got := ds.Dedup().Dupes(); got[SourceClient] != 1 || got[SourcePeer] != 1 {
+		t.Errorf("dupes after reload = %v, want the counts carried over", got)
+	}
+}
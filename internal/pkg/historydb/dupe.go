@@ -1,9 +1,9 @@
 package historydb
 
 import (
-	"hash/fnv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -17,20 +17,52 @@ import (
 // each accepted packet is additionally stored under several normalised
 // variants (trailing spaces removed, high bit handled, low/DEL bytes handled);
 // a later packet matching any stored variant is treated as a duplicate.
+//
+// Hashes are spread over dupeShards shards, each with its own lock, so
+// concurrent writers rarely contend. Within a shard, entries live in a ring of
+// dupeBuckets time buckets each spanning a fraction of the window. When a new
+// bucket period starts, the first writer to notice (elected without a lock)
+// empties the buckets that have left the window, one shard at a time, so memory
+// holds little more than a window's worth of packets and expiry never stops
+// all writers at once.
 type DupeChecker struct {
-	mu        sync.Mutex
-	seen      map[uint64]time.Time
-	window    time.Duration
-	lastSweep time.Time // last time expired entries were pruned
+	window time.Duration
+	width  int64        // nanoseconds spanned by one bucket
+	base   time.Time    // origin of the monotonic times stored in the buckets
+	epoch  atomic.Int64 // latest bucket period expired up to
+	shards [dupeShards]dupeShard
+}
+
+// dupeShards is the number of shards of a DupeChecker (a power of two).
+const dupeShards = 64
+
+// dupeBuckets is the number of time buckets in a shard's ring. The window
+// spans dupeBuckets-1 buckets, so the oldest is free to be reused.
+const dupeBuckets = 8
+
+// dupeShard is one shard of a DupeChecker.
+type dupeShard struct {
+	mu      sync.Mutex
+	buckets [dupeBuckets]dupeBucket
+}
+
+// dupeBucket holds the hashes seen during one bucket period (epoch), with
+// when they were seen in nanoseconds since the checker's base.
+type dupeBucket struct {
+	epoch int64
+	seen  map[uint64]int64
 }
 
 // NewDupeChecker creates a checker that treats identical packets as duplicates
 // if seen again within window.
 func NewDupeChecker(window time.Duration) *DupeChecker {
-	return &DupeChecker{
-		seen:   make(map[uint64]time.Time),
-		window: window,
+	width := int64(window) / (dupeBuckets - 1)
+	if width <= 0 {
+		width = 1
 	}
+	// The base lies a window in the past, so restored entries still within
+	// the window get positive times.
+	return &DupeChecker{window: window, width: width, base: time.Now().Add(-window)}
 }
 
 // dupeKey reduces a raw packet line to the bytes used for duplicate detection:
@@ -56,9 +88,45 @@ func dupeKey(packet string) string {
 
 // hashKey returns the 64-bit FNV-1a hash of s.
 func hashKey(s string) uint64 {
-	h := fnv.New64a()
-	_, _ = h.Write([]byte(s))
-	return h.Sum64()
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(s); i++ {
+		h ^= uint64(s[i])
+		h *= 1099511628211
+	}
+	return h
+}
+
+// dupeHash returns hashKey(dupeKey(packet)) without building the key.
+func dupeHash(packet string) uint64 {
+	i := strings.IndexByte(packet, ':')
+	if i < 0 {
+		return hashKey(packet)
+	}
+	src := packet
+	if j := strings.IndexByte(packet, '>'); j >= 0 {
+		src = packet[:j]
+	}
+	h := hashKey(src)
+	for _, c := range []byte(packet[i:]) { // the ':' and the information field
+		h ^= uint64(c)
+		h *= 1099511628211
+	}
+	return h
+}
+
+// mangled reports whether a packet could have normalised variants: it has
+// trailing spaces, 8-bit, low control or DEL bytes. Most packets have none,
+// which spares them building the variant set.
+func mangled(packet string) bool {
+	if strings.HasSuffix(packet, " ") {
+		return true
+	}
+	for i := 0; i < len(packet); i++ {
+		if c := packet[i]; c&0x80 != 0 || (c < 0x20 && c > 0) || c == 0x7f {
+			return true
+		}
+	}
+	return false
 }
 
 // mangleVariants returns the set of normalised variants of key (excluding key
@@ -140,67 +208,168 @@ func mangleVariants(key string) []string {
 	return out
 }
 
-// Seen reports whether packet was seen within the window; otherwise it records
-// it (and its normalised variants) and returns false. Expired entries are
-// pruned at most once per window (time-sampled) so the hot path stays O(1)
-// amortised instead of scanning the whole map on every packet.
-func (d *DupeChecker) Seen(packet string) bool {
-	key := dupeKey(packet)
-	keyHash := hashKey(key)
-
-	now := time.Now()
-	d.mu.Lock()
-	defer d.mu.Unlock()
+// shard returns the shard a hash is stored in.
+func (d *DupeChecker) shard(h uint64) *dupeShard {
+	return &d.shards[h>>58] // top bits: FNV mixes them best
+}
 
-	// Exact (canonical) match within the window?
-	if t, ok := d.seen[keyHash]; ok && now.Sub(t) < d.window {
-		return true
+// lookup reports whether h was seen within the window ending at now. The
+// caller must hold the shard's lock.
+func (s *dupeShard) lookup(h uint64, now int64, d *DupeChecker) bool {
+	oldest := now/d.width - (dupeBuckets - 1)
+	for i := range s.buckets {
+		b := &s.buckets[i]
+		if b.epoch < oldest || b.seen == nil {
+			continue
+		}
+		if t, ok := b.seen[h]; ok && now-t < int64(d.window) {
+			return true
+		}
 	}
+	return false
+}
+
+// since is the clock of the checkers. It is a variable so tests can move time
+// on without sleeping.
+var since = time.Since
+
+// now returns the current time relative to the base, read from the monotonic
+// clock so wall clock steps cannot move entries between buckets.
+func (d *DupeChecker) now() int64 { return int64(since(d.base)) }
 
-	// Prune expired entries at most once per window.
-	if now.Sub(d.lastSweep) >= d.window {
-		d.pruneLocked(now)
+// store records h as seen at t (nanoseconds since the base), reusing the bucket of t's
+// period once the entries it holds have left the window. Entries older than the
+// bucket's current period are dropped. The caller must hold the shard's lock.
+func (s *dupeShard) store(h uint64, t int64, d *DupeChecker) {
+	epoch := t / d.width
+	b := &s.buckets[epoch%dupeBuckets]
+	switch {
+	case b.seen == nil:
+		b.seen = make(map[uint64]int64)
+		b.epoch = epoch
+	case b.epoch < epoch:
+		clear(b.seen)
+		b.epoch = epoch
+	case b.epoch > epoch:
+		return
 	}
+	if t > b.seen[h] {
+		b.seen[h] = t
+	}
+}
 
-	// Compute the normalised variants once and reuse for both the match check
-	// and the store below.
-	variants := mangleVariants(key)
+// Seen reports whether packet was seen within the window; otherwise it records
+// it (and its normalised variants) and returns false. The canonical hash is
+// checked and recorded under one shard lock, so of several identical copies
+// only one is ever accepted. Variants live in their own shards and are checked
+// and recorded shard by shard.
+func (d *DupeChecker) Seen(packet string) bool {
+	keyHash := dupeHash(packet)
+	var variants []string
+	if mangled(packet) {
+		variants = mangleVariants(dupeKey(packet))
+	}
+	now := d.now()
+	if epoch, last := now/d.width, d.epoch.Load(); epoch > last && d.epoch.CompareAndSwap(last, epoch) {
+		d.expire(epoch, false)
+	}
 
 	// Variant match within the window? (A previously stored packet whose
 	// normalised form equals this one's canonical key.)
-	for _, v := range variants {
-		vh := hashKey(v)
-		if t, ok := d.seen[vh]; ok && now.Sub(t) < d.window {
+	hashes := make([]uint64, len(variants))
+	for i, v := range variants {
+		hashes[i] = hashKey(v)
+		s := d.shard(hashes[i])
+		s.mu.Lock()
+		dup := s.lookup(hashes[i], now, d)
+		s.mu.Unlock()
+		if dup {
 			return true
 		}
 	}
 
-	// Not a duplicate: record the canonical key and all its variants so a
-	// later corrupted copy is recognised.
-	d.seen[keyHash] = now
-	for _, v := range variants {
-		d.seen[hashKey(v)] = now
+	// Exact (canonical) match within the window? If not, record it.
+	s := d.shard(keyHash)
+	s.mu.Lock()
+	if s.lookup(keyHash, now, d) {
+		s.mu.Unlock()
+		return true
+	}
+	s.store(keyHash, now, d)
+	s.mu.Unlock()
+
+	// Record the variants so a later corrupted copy is recognised.
+	for _, h := range hashes {
+		s := d.shard(h)
+		s.mu.Lock()
+		s.store(h, now, d)
+		s.mu.Unlock()
 	}
 	return false
 }
 
-// Cleanup removes all entries older than the window. It is safe for concurrent
-// use and intended to be called periodically (e.g. from cron) for long-lived
-// checkers so the map does not retain expired keys indefinitely.
+// expire empties the buckets that have left the window by bucket period
+// epoch, locking one shard at a time. With release their memory is freed too;
+// otherwise it is kept for reuse.
+func (d *DupeChecker) expire(epoch int64, release bool) {
+	oldest := epoch - (dupeBuckets - 1)
+	for i := range d.shards {
+		s := &d.shards[i]
+		s.mu.Lock()
+		for j := range s.buckets {
+			switch b := &s.buckets[j]; {
+			case b.epoch >= oldest:
+			case release:
+				b.seen = nil
+			default:
+				clear(b.seen)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Cleanup releases the buckets whose entries have all left the window. Expired
+// buckets are emptied as packets arrive anyway; this frees the memory of a
+// checker that has gone quiet. It is safe for concurrent use and intended to be
+// called periodically (e.g. from cron) for long-lived checkers.
 func (d *DupeChecker) Cleanup() {
-	now := time.Now()
-	d.mu.Lock()
-	defer d.mu.Unlock()
-	d.pruneLocked(now)
-}
-
-// pruneLocked deletes expired entries and records the sweep time. The caller
-// must hold d.mu.
-func (d *DupeChecker) pruneLocked(now time.Time) {
-	for k, t := range d.seen {
-		if now.Sub(t) >= d.window {
-			delete(d.seen, k)
+	d.expire(d.now()/d.width, true)
+}
+
+// Snapshot returns the hashes seen within the window and when, for saving
+// across restarts.
+func (d *DupeChecker) Snapshot() map[uint64]time.Time {
+	now := d.now()
+	out := make(map[uint64]time.Time)
+	for i := range d.shards {
+		s := &d.shards[i]
+		s.mu.Lock()
+		for _, b := range s.buckets {
+			for h, t := range b.seen {
+				at := d.base.Add(time.Duration(t)).Round(0)
+				if now-t < int64(d.window) && at.After(out[h]) {
+					out[h] = at
+				}
+			}
+		}
+		s.mu.Unlock()
+	}
+	return out
+}
+
+// Restore loads hashes saved by Snapshot that are still within the window, so
+// copies of packets seen before a restart are still suppressed.
+func (d *DupeChecker) Restore(seen map[uint64]time.Time) {
+	now := d.now()
+	for h, t := range seen {
+		ts := min(int64(t.Sub(d.base)), now) // saved by a clock ahead: now
+		if now-ts >= int64(d.window) {
+			continue
 		}
+		s := d.shard(h)
+		s.mu.Lock()
+		s.store(h, ts, d)
+		s.mu.Unlock()
 	}
-	d.lastSweep = now
 }
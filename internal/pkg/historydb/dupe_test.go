@@ -1,6 +1,9 @@
 package historydb
 
 import (
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -92,3 +95,157 @@ func TestDupeCheckerNoInfoField(t *testing.T) {
 		t.Error("exact repeat should be a duplicate")
 	}
 }
+
+// A restored checker suppresses packets seen before the restart, within the
+// window only.
+func TestDupeCheckerSnapshotRestore(t *testing.T) {
+	d := NewDupeChecker(time.Minute)
+	d.Seen("A>B:>hi")
+	saved := d.Snapshot()
+	saved[1] = time.Now().Add(-2 * time.Minute) // expired entry
+
+	r := NewDupeChecker(time.Minute)
+	r.Restore(saved)
+	if !r.Seen("A>B,qAC,X:>hi") {
+		t.Error("packet seen before the restore should be a duplicate")
+	}
+	if _, ok := r.Snapshot()[1]; ok {
+		t.Error("expired entry restored")
+	}
+}
+
+// entries counts the hashes held by a checker, expired or not.
+func (d *DupeChecker) entries() int {
+	n := 0
+	for i := range d.shards {
+		s := &d.shards[i]
+		s.mu.Lock()
+		for _, b := range s.buckets {
+			n += len(b.seen)
+		}
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Buckets are reused as time moves on, so a checker fed for many windows
+// holds no more than about a window's worth of packets, and Cleanup releases
+// them once the feed stops.
+func TestDupeCheckerBoundedMemory(t *testing.T) {
+	var elapsed time.Duration
+	defer func(f func(time.Time) time.Duration) { since = f }(since)
+	since = func(t time.Time) time.Duration { return time.Since(t) + elapsed }
+
+	const window = time.Minute
+	const step = window / 100
+	d := NewDupeChecker(window)
+	for i := 0; i < 500; i++ {
+		d.Seen(fmt.Sprintf("N%d>APRS:>bounded", i))
+		elapsed += step
+	}
+	// Entries are held for the window plus at most two bucket periods.
+	if n, limit := d.entries(), int((window+2*window/(dupeBuckets-1))/step); n > limit {
+		t.Errorf("holding %d entries of 500, want at most %d", n, limit)
+	}
+
+	elapsed += window + window/(dupeBuckets-1)
+	d.Cleanup()
+	if n := d.entries(); n != 0 {
+		t.Errorf("%d entries left after cleanup", n)
+	}
+}
+
+// Of many identical copies arriving concurrently, exactly one is accepted.
+func TestDupeCheckerConcurrent(t *testing.T) {
+	d := NewDupeChecker(time.Minute)
+	for p := 0; p < 100; p++ {
+		var accepted atomic.Int32
+		var wg sync.WaitGroup
+		for c := 0; c < 8; c++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if !d.Seen(fmt.Sprintf("C%d>APRS,qAR,IGATE%d:>race", p, c)) {
+					accepted.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+		if n := accepted.Load(); n != 1 {
+			t.Fatalf("packet %d accepted %d times", p, n)
+		}
+	}
+}
+
+// feed returns n distinct full-feed style packet lines.
+func feed(n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = fmt.Sprintf("N%dCALL-%d>APRS,TCPIP*,qAC,T2TEST:!3959.12N/11619.34E>%d test packet", i%5000, i%16, i)
+	}
+	return out
+}
+
+// BenchmarkDupeCheckerSeen measures one writer checking new packets.
+func BenchmarkDupeCheckerSeen(b *testing.B) {
+	d := NewDupeChecker(30 * time.Second)
+	pkts := feed(1 << 16)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.Seen(pkts[i%len(pkts)])
+	}
+}
+
+// BenchmarkDupeCheckerParallel measures many concurrent clients each
+// submitting the full feed, so most packets are dupes of another client's
+// copy, as on a busy server with core peers and a full-feed uplink.
+func BenchmarkDupeCheckerParallel(b *testing.B) {
+	d := NewDupeChecker(30 * time.Second)
+	pkts := feed(1 << 16)
+	var next atomic.Uint64
+	b.ReportAllocs()
+	b.SetParallelism(64) // 64 clients per CPU
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := next.Add(1) * 7919
+		for pb.Next() {
+			d.Seen(pkts[i%uint64(len(pkts))])
+			i++
+		}
+	})
+}
+
+// BenchmarkDupeCheckerFullFeed replays a 250 packets/s full feed, each packet
+// arriving from 4 of 200 concurrent clients, over one 30-second window and
+// reports the time spent deduplicating per second of feed.
+func BenchmarkDupeCheckerFullFeed(b *testing.B) {
+	const (
+		rate    = 250
+		seconds = 30
+		clients = 200
+		copies  = 4
+	)
+	pkts := feed(rate * seconds)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		d := NewDupeChecker(seconds * time.Second)
+		var wg sync.WaitGroup
+		for c := 0; c < clients; c++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				// Packet p arrives from clients p%clients and the
+				// copies-1 clients after it.
+				for k := 0; k < copies; k++ {
+					for p := (c - k + clients) % clients; p < len(pkts); p += clients {
+						d.Seen(pkts[p])
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+	b.ReportMetric(float64(b.Elapsed().Microseconds())/float64(b.N*seconds), "µs/feed-s")
+}
@@ -107,3 +107,38 @@ func (h *HeardList) Len() int {
 	defer h.mu.Unlock()
 	return len(h.d)
 }
+
+// Clear forgets every station.
+func (h *HeardList) Clear() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	clear(h.d)
+}
+
+// Snapshot returns the unexpired stations with their last-heard times.
+func (h *HeardList) Snapshot() map[string]time.Time {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make(map[string]time.Time, len(h.d))
+	for k, t := range h.d {
+		if time.Since(t) <= h.ttl {
+			out[k] = t
+		}
+	}
+	return out
+}
+
+// Restore adds stations with their last-heard times, e.g. from a Snapshot
+// taken by another process. Expired entries are skipped and growth stays
+// bounded by maxHeard.
+func (h *HeardList) Restore(entries map[string]time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for k, t := range entries {
+		k = strings.ToUpper(strings.TrimSpace(k))
+		if k == "" || time.Since(t) > h.ttl || len(h.d) >= maxHeard {
+			continue
+		}
+		h.d[k] = t
+	}
+}
@@ -1,6 +1,9 @@
 package historydb
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestHeardAddAndExpiry(t *testing.T) {
 	h := NewHeardList()
@@ -22,6 +25,10 @@ func TestHeardAddAndExpiry(t *testing.T) {
 	if h.Len() != 1 {
 		t.Errorf("Len = %d after empty add, want 1", h.Len())
 	}
+	h.Clear()
+	if h.Len() != 0 || h.Heard("N0CALL-9") {
+		t.Error("Clear should forget every station")
+	}
 }
 
 func TestHeardBounded(t *testing.T) {
@@ -34,6 +41,19 @@ func TestHeardBounded(t *testing.T) {
 	}
 }
 
+func TestHeardSnapshotRestore(t *testing.T) {
+	h := NewHeardListTTL(time.Hour)
+	h.Add("N0CALL")
+	snap := h.Snapshot()
+	snap["OLD"] = time.Now().Add(-2 * time.Hour)
+
+	r := NewHeardListTTL(time.Hour)
+	r.Restore(snap)
+	if !r.Heard("N0CALL") || r.Heard("OLD") || r.Len() != 1 {
+		t.Errorf("restored list = %v, want only N0CALL", r.Snapshot())
+	}
+}
+
 func itoa(n int) string {
 	if n == 0 {
 		return "0"
@@ -52,3 +52,26 @@ func (d *MapFloat64History) ToSlice() [][2]any {
 	}
 	return ret
 }
+
+// Points returns the recorded (time, value) pairs, for saving across
+// restarts.
+func (d *MapFloat64History) Points() [][2]float64 {
+	d.l.RLock()
+	defer d.l.RUnlock()
+	ret := make([][2]float64, 0, len(d.D))
+	for k, v := range d.D {
+		if t, ok := k.(float64); ok {
+			ret = append(ret, [2]float64{t, v})
+		}
+	}
+	return ret
+}
+
+// Restore records (time, value) pairs saved by Points.
+func (d *MapFloat64History) Restore(points [][2]float64) {
+	d.l.Lock()
+	defer d.l.Unlock()
+	for _, p := range points {
+		d.D[p[0]] = p[1]
+	}
+}
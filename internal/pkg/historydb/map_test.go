@@ -0,0 +1,15 @@
+package historydb
+
+import "testing"
+
+func TestMapFloat64HistoryPointsRestore(t *testing.T) {
+	d := NewMapFloat64History()
+	d.Record(100.0, 1.5)
+	d.Record(160.0, 2.5)
+
+	r := NewMapFloat64History()
+	r.Restore(d.Points())
+	if len(r.D) != 2 || r.D[100.0] != 1.5 || r.D[160.0] != 2.5 {
+		t.Errorf("restored series = %v, want %v", r.D, d.D)
+	}
+}
@@ -0,0 +1,395 @@
+package historydb
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.gh.ink/json"
+)
+
+// Defaults for the packet history store, used when the corresponding config
+// value is 0.
+const (
+	// DefaultPacketDepth is the number of packets kept per station/object.
+	DefaultPacketDepth = 10
+	// DefaultPacketMaxAge is how long a station is kept after it was last
+	// heard.
+	DefaultPacketMaxAge = 48 * time.Hour
+)
+
+// PacketRecord is a single stored packet.
+type PacketRecord struct {
+	Time time.Time `json:"time"`
+	Raw  string    `json:"raw"`
+}
+
+// packetRing is a fixed-size ring of the most recent packets for one key.
+type packetRing struct {
+	items []PacketRecord
+	next  int // slot the next record is written to
+	n     int // number of valid records
+}
+
+// add stores rec, overwriting the oldest record once the ring is full.
+func (r *packetRing) add(rec PacketRecord) {
+	r.items[r.next] = rec
+	r.next = (r.next + 1) % len(r.items)
+	if r.n < len(r.items) {
+		r.n++
+	}
+}
+
+// newest returns up to limit records, newest first (limit <= 0 means all).
+func (r *packetRing) newest(limit int) []PacketRecord {
+	if limit <= 0 || limit > r.n {
+		limit = r.n
+	}
+	out := make([]PacketRecord, 0, limit)
+	for i := 1; i <= limit; i++ {
+		out = append(out, r.items[(r.next-i+len(r.items))%len(r.items)])
+	}
+	return out
+}
+
+// last returns the most recent record (the ring must not be empty).
+func (r *packetRing) last() PacketRecord {
+	return r.items[(r.next-1+len(r.items))%len(r.items)]
+}
+
+// journalEntry is one line of the on-disk journal: a packet stored under one
+// or more keys.
+type journalEntry struct {
+	Keys []string `json:"k"`
+	Time int64    `json:"t"` // unix nanoseconds
+	Raw  string   `json:"r"`
+}
+
+// PacketHistory keeps the last packets per source callsign and per object/item
+// name, in memory and (after Open) in an append-only journal. It is safe for
+// concurrent use.
+type PacketHistory struct {
+	mu     sync.RWMutex
+	d      map[string]*packetRing
+	depth  int
+	maxAge time.Duration
+
+	// Journal state (nil file = in-memory only). lines counts the journal
+	// entries written since the last compaction, stored the records held in
+	// memory; Cleanup rewrites the journal when it has grown well past that.
+	// While it does, pending collects the entries recorded meanwhile.
+	path    string
+	f       *os.File
+	w       *bufio.Writer
+	lines   int
+	stored  int
+	pending []journalEntry
+}
+
+// NewPacketHistory creates an in-memory packet history keeping depth packets
+// per key for maxAge after the key was last heard. Non-positive values fall
+// back to the defaults.
+func NewPacketHistory(depth int, maxAge time.Duration) *PacketHistory {
+	if depth <= 0 {
+		depth = DefaultPacketDepth
+	}
+	if maxAge <= 0 {
+		maxAge = DefaultPacketMaxAge
+	}
+	return &PacketHistory{d: make(map[string]*packetRing), depth: depth, maxAge: maxAge}
+}
+
+// Packets is the process-wide packet history, fed by the distribution stream
+// and queried by the station API.
+var Packets = NewPacketHistory(DefaultPacketDepth, DefaultPacketMaxAge)
+
+// OpenPackets replaces the process-wide packet history with one using the
+// given depth and maximum age, backed by the journal at path (empty = in
+// memory only). It must be called before packets start flowing.
+func OpenPackets(path string, depth int, maxAge time.Duration) error {
+	h := NewPacketHistory(depth, maxAge)
+	Packets = h
+	return h.Open(path)
+}
+
+// Open attaches the on-disk journal at path, replaying any existing entries
+// into memory and compacting the file. An empty path keeps the store in
+// memory only.
+func (h *PacketHistory) Open(path string) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	h.mu.Lock()
+	err := h.replayLocked(path)
+	if err == nil {
+		h.path = path
+	}
+	h.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return h.compact()
+}
+
+// replayLocked loads the journal at path into memory, skipping unparseable
+// lines (e.g. a torn final write). The caller must hold h.mu.
+func (h *PacketHistory) replayLocked(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	cutoff := time.Now().Add(-h.maxAge)
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 4096), 64*1024)
+	for sc.Scan() {
+		var e journalEntry
+		if json.Unmarshal(sc.Bytes(), &e) != nil {
+			continue
+		}
+		at := time.Unix(0, e.Time)
+		if at.Before(cutoff) {
+			continue
+		}
+		h.addLocked(e.Keys, PacketRecord{Time: at, Raw: e.Raw})
+	}
+	return sc.Err()
+}
+
+// addLocked stores rec under each (normalised, non-empty) key. The caller
+// must hold h.mu.
+func (h *PacketHistory) addLocked(keys []string, rec PacketRecord) {
+	for _, k := range keys {
+		r, ok := h.d[k]
+		if !ok {
+			r = &packetRing{items: make([]PacketRecord, h.depth)}
+			h.d[k] = r
+		}
+		if r.n < len(r.items) {
+			h.stored++
+		}
+		r.add(rec)
+	}
+}
+
+// Record stores a packet under the given keys (typically the source callsign
+// and, for objects/items, the object name). Empty and repeated keys are
+// ignored.
+func (h *PacketHistory) Record(raw string, keys ...string) {
+	norm := make([]string, 0, len(keys))
+	for _, k := range keys {
+		k = normalise(k)
+		if k == "" {
+			continue
+		}
+		dup := false
+		for _, n := range norm {
+			if n == k {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			norm = append(norm, k)
+		}
+	}
+	if len(norm) == 0 {
+		return
+	}
+	now := time.Now()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.addLocked(norm, PacketRecord{Time: now, Raw: raw})
+	if h.w != nil {
+		e := journalEntry{Keys: norm, Time: now.UnixNano(), Raw: raw}
+		h.writeLocked(e)
+		if h.pending != nil {
+			h.pending = append(h.pending, e)
+		}
+	}
+}
+
+// writeLocked appends a journal entry. A write error sticks to the writer and
+// is returned by the next Flush. The caller must hold h.mu.
+func (h *PacketHistory) writeLocked(e journalEntry) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	_, _ = h.w.Write(b)
+	_ = h.w.WriteByte('\n')
+	h.lines++
+}
+
+// Last returns the most recent packet stored for call and the number of
+// packets held for it. ok is false when the key is unknown or expired.
+func (h *PacketHistory) Last(call string) (rec PacketRecord, count int, ok bool) {
+	call = normalise(call)
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	r, found := h.d[call]
+	if !found || r.n == 0 {
+		return PacketRecord{}, 0, false
+	}
+	rec = r.last()
+	if time.Since(rec.Time) > h.maxAge {
+		return PacketRecord{}, 0, false
+	}
+	return rec, r.n, true
+}
+
+// Get returns up to limit packets stored for call, newest first (limit <= 0
+// returns all). It returns nil when the key is unknown or expired.
+func (h *PacketHistory) Get(call string, limit int) []PacketRecord {
+	call = normalise(call)
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	r, found := h.d[call]
+	if !found || r.n == 0 || time.Since(r.last().Time) > h.maxAge {
+		return nil
+	}
+	return r.newest(limit)
+}
+
+// Len returns the number of stations/objects currently stored.
+func (h *PacketHistory) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.d)
+}
+
+// Flush writes buffered journal entries to disk. On an error the buffered
+// entries are dropped, so journaling resumes with the next one.
+func (h *PacketHistory) Flush() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.w == nil {
+		return nil
+	}
+	err := h.w.Flush()
+	if err != nil {
+		h.w.Reset(h.f)
+	}
+	return err
+}
+
+// Cleanup drops keys not heard within the maximum age and, when the journal
+// has grown well beyond the live records, rewrites it compacted. It is
+// intended to be called periodically.
+func (h *PacketHistory) Cleanup() error {
+	cutoff := time.Now().Add(-h.maxAge)
+	h.mu.Lock()
+	for k, r := range h.d {
+		if r.n == 0 || r.last().Time.Before(cutoff) {
+			h.stored -= r.n
+			delete(h.d, k)
+		}
+	}
+	grown := h.path != "" && h.lines > 2*h.stored+1024
+	h.mu.Unlock()
+	if !grown {
+		return nil
+	}
+	return h.compact()
+}
+
+// compact rewrites the journal from the in-memory state. Only taking the
+// snapshot and switching to the new file hold the lock; until then packets
+// are still appended to the old file, which is kept if the rewrite fails.
+func (h *PacketHistory) compact() error {
+	h.mu.Lock()
+	if h.pending != nil {
+		// Another compaction is running.
+		h.mu.Unlock()
+		return nil
+	}
+	path := h.path
+	var snap []journalEntry
+	for k, r := range h.d {
+		recs := r.newest(0)
+		// Oldest first, so a replay restores the ring order.
+		for i := len(recs) - 1; i >= 0; i-- {
+			snap = append(snap, journalEntry{Keys: []string{k}, Time: recs[i].Time.UnixNano(), Raw: recs[i].Raw})
+		}
+	}
+	h.pending = make([]journalEntry, 0)
+	h.mu.Unlock()
+
+	f, w, err := writeJournal(path, snap)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	pending := h.pending
+	h.pending = nil
+	if err != nil {
+		return err
+	}
+	if h.path == "" {
+		// Closed meanwhile.
+		return f.Close()
+	}
+	if h.f != nil {
+		_ = h.w.Flush()
+		_ = h.f.Close()
+	}
+	h.f, h.w = f, w
+	h.lines = len(snap)
+	for _, e := range pending {
+		h.writeLocked(e)
+	}
+	return nil
+}
+
+// writeJournal writes entries to a temporary file and renames it to path,
+// returning the file open for appending.
+func writeJournal(path string, entries []journalEntry) (*os.File, *bufio.Writer, error) {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return nil, nil, err
+	}
+	w := bufio.NewWriter(f)
+	for _, e := range entries {
+		b, err := json.Marshal(e)
+		if err != nil {
+			continue
+		}
+		_, _ = w.Write(b)
+		_ = w.WriteByte('\n')
+	}
+	if err = w.Flush(); err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return nil, nil, err
+	}
+	return f, w, nil
+}
+
+// Close flushes and closes the journal. The in-memory history stays usable.
+func (h *PacketHistory) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.path = ""
+	if h.f == nil {
+		return nil
+	}
+	err := h.w.Flush()
+	if cerr := h.f.Close(); err == nil {
+		err = cerr
+	}
+	h.f, h.w = nil, nil
+	return err
+}
@@ -0,0 +1,151 @@
+package historydb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPacketHistory(t *testing.T) {
+	h := NewPacketHistory(3, time.Hour)
+	for _, raw := range []string{"p1", "p2", "p3", "p4"} {
+		h.Record(raw, "n0call")
+	}
+
+	recs := h.Get("N0CALL", 0)
+	if len(recs) != 3 {
+		t.Fatalf("len = %d, want 3 (depth)", len(recs))
+	}
+	if recs[0].Raw != "p4" || recs[2].Raw != "p2" {
+		t.Errorf("order = %v, want newest first p4..p2", recs)
+	}
+	if got := h.Get("N0CALL", 1); len(got) != 1 || got[0].Raw != "p4" {
+		t.Errorf("limit 1 = %v, want [p4]", got)
+	}
+
+	// Keys are case-insensitive; an object is stored under its name as well
+	// as its source, and a key repeated in one Record counts once.
+	h.Record("SRC>APRS:;OBJ      *...", "SRC", "OBJ", "src")
+	cases := []struct {
+		key   string
+		last  string
+		count int
+		ok    bool
+	}{
+		{"n0call", "p4", 3, true},
+		{"N0CALL", "p4", 3, true},
+		{"SRC", "SRC>APRS:;OBJ      *...", 1, true},
+		{"obj", "SRC>APRS:;OBJ      *...", 1, true},
+		{"OTHER", "", 0, false},
+	}
+	for _, tc := range cases {
+		last, count, ok := h.Last(tc.key)
+		if ok != tc.ok || last.Raw != tc.last || count != tc.count {
+			t.Errorf("Last(%s) = (%q,%d,%v), want (%q,%d,%v)", tc.key, last.Raw, count, ok, tc.last, tc.count, tc.ok)
+		}
+	}
+}
+
+func TestPacketHistoryJournalReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+
+	h := NewPacketHistory(2, time.Hour)
+	if err := h.Open(path); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	h.Record("a1", "A")
+	h.Record("a2", "A")
+	h.Record("a3", "A")
+	h.Record("b1", "B")
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	r := NewPacketHistory(2, time.Hour)
+	if err := r.Open(path); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer r.Close()
+	if got := r.Get("A", 0); len(got) != 2 || got[0].Raw != "a3" || got[1].Raw != "a2" {
+		t.Errorf("replayed A = %v, want [a3 a2]", got)
+	}
+	if _, _, ok := r.Last("B"); !ok {
+		t.Error("replayed B missing")
+	}
+}
+
+func TestPacketHistoryCleanup(t *testing.T) {
+	h := NewPacketHistory(2, time.Hour)
+	h.Record("old", "OLD")
+	h.mu.Lock()
+	r := h.d["OLD"]
+	r.items[0].Time = r.items[0].Time.Add(-2 * time.Hour)
+	h.mu.Unlock()
+
+	if err := h.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if h.Len() != 0 {
+		t.Errorf("Len = %d after cleanup, want 0", h.Len())
+	}
+}
+
+func TestPacketHistoryCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	h := NewPacketHistory(2, time.Hour)
+	if err := h.Open(path); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer h.Close()
+	for i := 0; i < 1100; i++ {
+		h.Record(fmt.Sprintf("a%d", i), "A")
+	}
+
+	// A failed rewrite keeps journaling to the old file.
+	if err := os.Mkdir(path+".tmp", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Cleanup(); err == nil {
+		t.Fatal("Cleanup succeeded with the temporary file blocked")
+	}
+	h.Record("b1", "B")
+	if err := h.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	// reopened replays the journal as it is on disk, leaving it untouched.
+	reopened := func() *PacketHistory {
+		r := NewPacketHistory(2, time.Hour)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if err := r.replayLocked(path); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	if _, _, ok := reopened().Last("B"); !ok {
+		t.Fatal("entry recorded after a failed compaction not journaled")
+	}
+
+	if err := os.Remove(path + ".tmp"); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Cleanup(); err != nil {
+		t.Fatalf("Cleanup: %v", err)
+	}
+	if h.lines != 3 {
+		t.Errorf("journal holds %d entries after compaction, want 3", h.lines)
+	}
+	h.Record("b2", "B")
+	if err := h.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	r := reopened()
+	if got := r.Get("B", 0); len(got) != 2 || got[0].Raw != "b2" {
+		t.Errorf("replayed B = %v, want [b2 b1]", got)
+	}
+	if got := r.Get("A", 0); len(got) != 2 || got[0].Raw != "a1099" {
+		t.Errorf("replayed A = %v, want [a1099 a1098]", got)
+	}
+}
@@ -1,32 +1,80 @@
 package historydb
 
 import (
+	"math"
+	"slices"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/APRSCN/aprsutils"
 )
 
 // posTTL is how long a station's last-known position is retained, used by the
 // range filters (m/, f/, t/.../call/km).
 const posTTL = 48 * time.Hour
 
+// kmPerDegree is a length of one degree of latitude, slightly short so a
+// radius' bounding box always covers its circle.
+const kmPerDegree = 111.0
+
 // posEntry is a single station's last-known position.
 type posEntry struct {
 	lat, lon float64
 	at       time.Time
 }
 
+// cell is a 1°×1° square of the grid index, by floored latitude and
+// longitude.
+type cell struct{ lat, lon int }
+
+// cellOf returns the grid cell holding a position.
+func cellOf(lat, lon float64) cell {
+	return cell{lat: cellLat(lat), lon: cellLon(lon)}
+}
+
+// cellLat returns the grid row of a latitude, clamped to the poles.
+func cellLat(lat float64) int {
+	return int(math.Floor(math.Max(-90, math.Min(90, lat))))
+}
+
+// cellLon returns the grid column of a longitude, 180° folded onto -180°.
+func cellLon(lon float64) int {
+	c := int(math.Floor(lon))
+	if c >= 180 {
+		c -= 360
+	}
+	if c < -180 {
+		c += 360
+	}
+	return c
+}
+
+// Position is a station's last-known position as returned by the spatial
+// queries.
+type Position struct {
+	Call string    `json:"call"`
+	Lat  float64   `json:"lat"`
+	Lon  float64   `json:"lon"`
+	At   time.Time `json:"at"`
+	Km   float64   `json:"-"` // distance from the query point (Within only)
+}
+
 // PositionHistory records the last-known position of stations by callsign so
-// position-aware filters can resolve friend/own positions. It is safe for
-// concurrent use.
+// position-aware filters can resolve friend/own positions. Positions are also
+// indexed on a 1° grid for area queries. It is safe for concurrent use.
 type PositionHistory struct {
-	mu sync.RWMutex
-	d  map[string]posEntry
+	mu   sync.RWMutex
+	d    map[string]posEntry
+	grid map[cell]map[string]struct{}
 }
 
 // NewPositionHistory creates an empty position history store.
 func NewPositionHistory() *PositionHistory {
-	return &PositionHistory{d: make(map[string]posEntry)}
+	return &PositionHistory{
+		d:    make(map[string]posEntry),
+		grid: make(map[cell]map[string]struct{}),
+	}
 }
 
 // Positions is the process-wide station position store, shared by the packet
@@ -47,10 +95,67 @@ func (h *PositionHistory) Update(call string, lat, lon float64) {
 		return
 	}
 	h.mu.Lock()
-	h.d[call] = posEntry{lat: lat, lon: lon, at: time.Now()}
+	h.setLocked(call, posEntry{lat: lat, lon: lon, at: time.Now()})
 	h.mu.Unlock()
 }
 
+// setLocked stores a station's entry and moves it to its grid cell. The
+// caller holds mu.
+func (h *PositionHistory) setLocked(call string, e posEntry) {
+	if old, ok := h.d[call]; ok {
+		if cellOf(old.lat, old.lon) != cellOf(e.lat, e.lon) {
+			h.unindex(call, old)
+		}
+	}
+	h.d[call] = e
+	c := cellOf(e.lat, e.lon)
+	if h.grid[c] == nil {
+		h.grid[c] = make(map[string]struct{})
+	}
+	h.grid[c][call] = struct{}{}
+}
+
+// Snapshot returns every unexpired position, for saving across restarts.
+func (h *PositionHistory) Snapshot() []Position {
+	cutoff := time.Now().Add(-posTTL)
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	out := make([]Position, 0, len(h.d))
+	for call, e := range h.d {
+		if !e.at.Before(cutoff) {
+			out = append(out, Position{Call: call, Lat: e.lat, Lon: e.lon, At: e.at})
+		}
+	}
+	return out
+}
+
+// Restore loads positions saved by Snapshot, keeping their times. Expired
+// positions and ones older than the station's current record are skipped.
+func (h *PositionHistory) Restore(ps []Position) {
+	cutoff := time.Now().Add(-posTTL)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, p := range ps {
+		call := normalise(p.Call)
+		if call == "" || p.At.Before(cutoff) {
+			continue
+		}
+		if cur, ok := h.d[call]; ok && !cur.at.Before(p.At) {
+			continue
+		}
+		h.setLocked(call, posEntry{lat: p.Lat, lon: p.Lon, at: p.At})
+	}
+}
+
+// unindex removes a station from its grid cell. The caller holds mu.
+func (h *PositionHistory) unindex(call string, e posEntry) {
+	c := cellOf(e.lat, e.lon)
+	delete(h.grid[c], call)
+	if len(h.grid[c]) == 0 {
+		delete(h.grid, c)
+	}
+}
+
 // Get returns the last-known position of a station. ok is false when the
 // station is unknown or its record has expired.
 func (h *PositionHistory) Get(call string) (lat, lon float64, ok bool) {
@@ -67,6 +172,114 @@ func (h *PositionHistory) Get(call string) (lat, lon float64, ok bool) {
 	return e.lat, e.lon, true
 }
 
+// InBox returns the stations inside a bounding box, sorted by callsign. A box
+// with minLon > maxLon crosses the antimeridian.
+func (h *PositionHistory) InBox(minLat, minLon, maxLat, maxLon float64) []Position {
+	in := func(e posEntry) bool {
+		if e.lat < minLat || e.lat > maxLat {
+			return false
+		}
+		if minLon <= maxLon {
+			return e.lon >= minLon && e.lon <= maxLon
+		}
+		return e.lon >= minLon || e.lon <= maxLon
+	}
+	out := h.scan(minLat, minLon, maxLat, maxLon, func(p *Position, e posEntry) bool { return in(e) })
+	slices.SortFunc(out, func(a, b Position) int { return strings.Compare(a.Call, b.Call) })
+	return out
+}
+
+// Within returns the stations within km of a point, nearest first.
+func (h *PositionHistory) Within(lat, lon, km float64) []Position {
+	dLat := km / kmPerDegree
+	minLat, maxLat := lat-dLat, lat+dLat
+	minLon, maxLon := -180.0, 180.0
+	// Near a pole the circle spans every meridian; elsewhere widen the
+	// longitude range by the parallel's shrink factor.
+	if minLat > -90 && maxLat < 90 {
+		if dLon := dLat / math.Cos(math.Max(math.Abs(minLat), math.Abs(maxLat))*math.Pi/180); dLon < 180 {
+			minLon, maxLon = wrapLon(lon-dLon), wrapLon(lon+dLon)
+		}
+	}
+	out := h.scan(minLat, minLon, maxLat, maxLon, func(p *Position, e posEntry) bool {
+		p.Km = aprsutils.CalculateDistanceHaversine(lat, lon, e.lat, e.lon)
+		return p.Km <= km
+	})
+	slices.SortFunc(out, func(a, b Position) int {
+		if a.Km != b.Km {
+			if a.Km < b.Km {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Call, b.Call)
+	})
+	return out
+}
+
+// wrapLon folds a longitude into [-180, 180).
+func wrapLon(lon float64) float64 {
+	return math.Mod(math.Mod(lon+180, 360)+360, 360) - 180
+}
+
+// scan collects the unexpired stations of the grid cells covering a box
+// (crossing the antimeridian when minLon > maxLon) that match keep, which may
+// fill in fields of the Position it is given.
+func (h *PositionHistory) scan(minLat, minLon, maxLat, maxLon float64, keep func(*Position, posEntry) bool) []Position {
+	lat0, lat1 := cellLat(minLat), cellLat(maxLat)
+	lon0, lon1 := cellLon(minLon), cellLon(maxLon)
+	if maxLon >= 180 {
+		lon1 = 179
+	}
+	cols := lon1 - lon0 + 1
+	if minLon > maxLon {
+		cols += 360
+	}
+	cols = min(cols, 360)
+	inRange := func(c cell) bool {
+		if c.lat < lat0 || c.lat > lat1 {
+			return false
+		}
+		return (c.lon-lon0+360)%360 < cols
+	}
+
+	cutoff := time.Now().Add(-posTTL)
+	var out []Position
+	collect := func(calls map[string]struct{}) {
+		for call := range calls {
+			e := h.d[call]
+			if e.at.Before(cutoff) {
+				continue
+			}
+			p := Position{Call: call, Lat: e.lat, Lon: e.lon, At: e.at}
+			if keep(&p, e) {
+				out = append(out, p)
+			}
+		}
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if lat1 < lat0 {
+		return nil
+	}
+	// Walk the covered cells, or the occupied ones when there are fewer.
+	if (lat1-lat0+1)*cols > len(h.grid) {
+		for c, calls := range h.grid {
+			if inRange(c) {
+				collect(calls)
+			}
+		}
+		return out
+	}
+	for la := lat0; la <= lat1; la++ {
+		for i := range cols {
+			collect(h.grid[cell{lat: la, lon: (lon0+180+i)%360 - 180}])
+		}
+	}
+	return out
+}
+
 // Len returns the number of currently stored stations (including any not yet
 // expired-out by Cleanup).
 func (h *PositionHistory) Len() int {
@@ -82,6 +295,7 @@ func (h *PositionHistory) Cleanup() {
 	defer h.mu.Unlock()
 	for k, e := range h.d {
 		if e.at.Before(cutoff) {
+			h.unindex(k, e)
 			delete(h.d, k)
 		}
 	}
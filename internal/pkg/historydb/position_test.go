@@ -1,6 +1,10 @@
 package historydb
 
-import "testing"
+import (
+	"slices"
+	"testing"
+	"time"
+)
 
 func TestPositionHistoryBasic(t *testing.T) {
 	h := NewPositionHistory()
@@ -49,3 +53,107 @@ func TestPositionHistoryCleanup(t *testing.T) {
 		t.Errorf("Len = %d after cleanup, want 0", h.Len())
 	}
 }
+
+func TestPositionHistoryInBox(t *testing.T) {
+	h := NewPositionHistory()
+	h.Update("HEL", 60.17, 24.94)
+	h.Update("TLL", 59.44, 24.75)
+	h.Update("STO", 59.33, 18.07)
+	h.Update("FJI", -17.7, 178.9)
+	h.Update("SAM", -13.8, -171.8)
+
+	names := func(ps []Position) []string {
+		var out []string
+		for _, p := range ps {
+			out = append(out, p.Call)
+		}
+		return out
+	}
+	if got := names(h.InBox(59, 24, 61, 26)); !slices.Equal(got, []string{"HEL", "TLL"}) {
+		t.Errorf("InBox = %v, want [HEL TLL]", got)
+	}
+	// A box across the antimeridian.
+	if got := names(h.InBox(-20, 170, -10, -170)); !slices.Equal(got, []string{"FJI", "SAM"}) {
+		t.Errorf("antimeridian InBox = %v, want [FJI SAM]", got)
+	}
+	if got := h.InBox(-90, -180, 90, 180); len(got) != 5 {
+		t.Errorf("world InBox = %d stations, want 5", len(got))
+	}
+
+	// A station moving out of the box leaves its old cell.
+	h.Update("TLL", 40, -74)
+	if got := names(h.InBox(59, 24, 61, 26)); !slices.Equal(got, []string{"HEL"}) {
+		t.Errorf("InBox after move = %v, want [HEL]", got)
+	}
+}
+
+func TestPositionHistoryWithin(t *testing.T) {
+	h := NewPositionHistory()
+	h.Update("HEL", 60.17, 24.94)
+	h.Update("TLL", 59.44, 24.75) // ~82 km from Helsinki
+	h.Update("STO", 59.33, 18.07) // ~400 km
+	h.Update("FJI", -17.7, 179.9)
+	h.Update("SAM", -17.7, -179.9) // ~21 km across the antimeridian
+
+	got := h.Within(60.17, 24.94, 100)
+	if len(got) != 2 || got[0].Call != "HEL" || got[1].Call != "TLL" {
+		t.Fatalf("Within 100 km = %+v, want HEL then TLL", got)
+	}
+	if got[1].Km < 75 || got[1].Km > 90 {
+		t.Errorf("TLL distance = %.1f km, want ~82", got[1].Km)
+	}
+	if got := h.Within(60.17, 24.94, 500); len(got) != 3 {
+		t.Errorf("Within 500 km = %d stations, want 3", len(got))
+	}
+	if got := h.Within(-17.7, 179.9, 50); len(got) != 2 {
+		t.Errorf("Within across the antimeridian = %+v, want FJI and SAM", got)
+	}
+	// Near the pole every meridian is in range.
+	h.Update("NP1", 89.9, 0)
+	h.Update("NP2", 89.9, 180)
+	if got := h.Within(89.95, 90, 50); len(got) != 2 {
+		t.Errorf("Within at the pole = %+v, want NP1 and NP2", got)
+	}
+}
+
+func TestPositionHistoryCleanupUnindexes(t *testing.T) {
+	h := NewPositionHistory()
+	h.Update("AA1AA", 1, 2)
+	h.mu.Lock()
+	e := h.d["AA1AA"]
+	e.at = e.at.Add(-posTTL - 1)
+	h.d["AA1AA"] = e
+	h.mu.Unlock()
+
+	if got := h.InBox(0, 0, 5, 5); len(got) != 0 {
+		t.Errorf("expired station returned by InBox: %+v", got)
+	}
+	h.Cleanup()
+	if len(h.grid) != 0 {
+		t.Errorf("grid holds %d cells after cleanup, want 0", len(h.grid))
+	}
+}
+
+func TestPositionHistorySnapshotRestore(t *testing.T) {
+	h := NewPositionHistory()
+	h.Update("HEL", 60.17, 24.94)
+	h.Update("TLL", 59.44, 24.75)
+	saved := h.Snapshot()
+	old := Position{Call: "OLD", Lat: 1, Lon: 1, At: saved[0].At.Add(-posTTL - time.Minute)}
+
+	r := NewPositionHistory()
+	r.Update("TLL", 10, 10) // newer than the saved record
+	r.Restore(append(saved, old))
+	if lat, lon, ok := r.Get("HEL"); !ok || lat != 60.17 || lon != 24.94 {
+		t.Errorf("HEL = %v,%v,%v after restore", lat, lon, ok)
+	}
+	if lat, _, _ := r.Get("TLL"); lat != 10 {
+		t.Errorf("restore overwrote a newer position of TLL (lat %v)", lat)
+	}
+	if r.Len() != 2 {
+		t.Errorf("Len = %d, want 2 (expired position must be skipped)", r.Len())
+	}
+	if got := r.InBox(60, 24, 61, 25); len(got) != 1 || got[0].Call != "HEL" {
+		t.Errorf("restored position not indexed: %+v", got)
+	}
+}
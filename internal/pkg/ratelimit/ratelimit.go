@@ -0,0 +1,169 @@
+// Package ratelimit implements token-bucket limiting of packets and bytes per
+// second. A Limiter guards a single sender (e.g. one TCP connection); Keyed
+// keeps one Limiter per key (e.g. per source IP for connectionless submits).
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// burstSeconds is how many seconds' worth of allowance a bucket holds, so a
+// sender may briefly exceed its rate (e.g. a burst of beacons after a
+// reconnect) without being throttled.
+const burstSeconds = 2
+
+// Limits is a packets-per-second and bytes-per-second allowance. A zero field
+// leaves that dimension unlimited.
+type Limits struct {
+	Packets int
+	Bytes   int
+}
+
+// Enabled reports whether any limit is set.
+func (l Limits) Enabled() bool {
+	return l.Packets > 0 || l.Bytes > 0
+}
+
+// Limiter is a pair of token buckets, one counting packets and one counting
+// bytes. A nil *Limiter allows everything. It is safe for concurrent use.
+type Limiter struct {
+	mu      sync.Mutex
+	lim     Limits
+	packets float64
+	bytes   float64
+	last    time.Time
+}
+
+// NewLimiter creates a limiter with full buckets. It returns nil (no limit)
+// when lim is not enabled.
+func NewLimiter(lim Limits) *Limiter {
+	if !lim.Enabled() {
+		return nil
+	}
+	return &Limiter{
+		lim:     lim,
+		packets: float64(lim.Packets * burstSeconds),
+		bytes:   float64(lim.Bytes * burstSeconds),
+	}
+}
+
+// Allow charges packets packets totalling size bytes at now and reports
+// whether they are within the limits. Rejected traffic is not charged.
+func (l *Limiter) Allow(now time.Time, packets, size int) bool {
+	if l == nil {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refillLocked(now)
+
+	if l.lim.Packets > 0 && l.packets < float64(packets) {
+		return false
+	}
+	// A bucket smaller than one payload would never admit it: accept the
+	// payload when the bucket is full and let the balance go negative, so
+	// the average still holds.
+	if l.lim.Bytes > 0 && l.bytes < min(float64(size), l.capacity(l.lim.Bytes)) {
+		return false
+	}
+	if l.lim.Packets > 0 {
+		l.packets -= float64(packets)
+	}
+	if l.lim.Bytes > 0 {
+		l.bytes -= float64(size)
+	}
+	return true
+}
+
+// full reports whether both buckets are full at now, i.e. the limiter holds no
+// state worth keeping.
+func (l *Limiter) full(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refillLocked(now)
+	return l.packets >= l.capacity(l.lim.Packets) && l.bytes >= l.capacity(l.lim.Bytes)
+}
+
+// SetLimits changes the allowance, keeping the current balances (clamped to
+// the new capacities).
+func (l *Limiter) SetLimits(lim Limits) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.lim == lim {
+		return
+	}
+	l.lim = lim
+	l.packets = min(l.packets, l.capacity(lim.Packets))
+	l.bytes = min(l.bytes, l.capacity(lim.Bytes))
+}
+
+// refillLocked adds the allowance accrued since the last call. The caller must
+// hold l.mu.
+func (l *Limiter) refillLocked(now time.Time) {
+	if !l.last.IsZero() {
+		if dt := now.Sub(l.last).Seconds(); dt > 0 {
+			l.packets = min(l.packets+dt*float64(l.lim.Packets), l.capacity(l.lim.Packets))
+			l.bytes = min(l.bytes+dt*float64(l.lim.Bytes), l.capacity(l.lim.Bytes))
+		}
+	}
+	if now.After(l.last) {
+		l.last = now
+	}
+}
+
+// capacity is the bucket size for a per-second rate.
+func (l *Limiter) capacity(rate int) float64 {
+	return float64(rate * burstSeconds)
+}
+
+// Keyed holds one Limiter per key, created on first use. It is safe for
+// concurrent use.
+type Keyed struct {
+	mu sync.Mutex
+	m  map[string]*Limiter
+}
+
+// NewKeyed creates an empty keyed limiter.
+func NewKeyed() *Keyed {
+	return &Keyed{m: make(map[string]*Limiter)}
+}
+
+// Allow charges packets packets totalling size bytes to key under lim and
+// reports whether they are within the limits. A disabled lim allows
+// everything; a key's limits follow lim when it changes.
+func (k *Keyed) Allow(key string, lim Limits, packets, size int) bool {
+	if !lim.Enabled() {
+		return true
+	}
+	k.mu.Lock()
+	l, ok := k.m[key]
+	if !ok {
+		l = NewLimiter(lim)
+		k.m[key] = l
+	}
+	k.mu.Unlock()
+
+	l.SetLimits(lim)
+	return l.Allow(time.Now(), packets, size)
+}
+
+// Sweep forgets keys whose buckets have refilled completely; they would start
+// from a full bucket anyway. It is intended to be called periodically.
+func (k *Keyed) Sweep() {
+	now := time.Now()
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for key, l := range k.m {
+		if l.full(now) {
+			delete(k.m, key)
+		}
+	}
+}
+
+// Len returns the number of tracked keys.
+func (k *Keyed) Len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.m)
+}
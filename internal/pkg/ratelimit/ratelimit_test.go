@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNilLimiterAllowsAll(t *testing.T) {
+	l := NewLimiter(Limits{})
+	if l != nil {
+		t.Fatal("disabled limits should yield a nil limiter")
+	}
+	if !l.Allow(time.Now(), 1000, 1<<20) {
+		t.Fatal("nil limiter should allow all")
+	}
+}
+
+func TestPacketLimit(t *testing.T) {
+	now := time.Now()
+	l := NewLimiter(Limits{Packets: 5})
+	// The bucket holds burstSeconds worth of packets.
+	for i := 0; i < 5*burstSeconds; i++ {
+		if !l.Allow(now, 1, 100) {
+			t.Fatalf("packet %d rejected inside the burst", i)
+		}
+	}
+	if l.Allow(now, 1, 100) {
+		t.Fatal("packet over the burst allowed")
+	}
+	// One second refills five packets.
+	now = now.Add(time.Second)
+	for i := 0; i < 5; i++ {
+		if !l.Allow(now, 1, 100) {
+			t.Fatalf("packet %d rejected after refill", i)
+		}
+	}
+	if l.Allow(now, 1, 100) {
+		t.Fatal("packet over the refill allowed")
+	}
+}
+
+func TestByteLimit(t *testing.T) {
+	now := time.Now()
+	l := NewLimiter(Limits{Bytes: 100})
+	if !l.Allow(now, 1, 150) || l.Allow(now, 1, 100) {
+		t.Fatal("byte bucket of 200 should admit 150 then reject 100")
+	}
+	// A payload larger than the whole bucket passes once the bucket is full.
+	l = NewLimiter(Limits{Bytes: 10})
+	if !l.Allow(now, 1, 500) {
+		t.Fatal("oversized payload rejected on a full bucket")
+	}
+	if l.Allow(now.Add(time.Second), 1, 500) {
+		t.Fatal("oversized payload allowed while in debt")
+	}
+}
+
+func TestKeyed(t *testing.T) {
+	k := NewKeyed()
+	lim := Limits{Packets: 1}
+	for i := 0; i < burstSeconds; i++ {
+		if !k.Allow("a", lim, 1, 10) {
+			t.Fatalf("packet %d rejected inside the burst", i)
+		}
+	}
+	if k.Allow("a", lim, 1, 10) {
+		t.Fatal("key a over its limit allowed")
+	}
+	if !k.Allow("b", lim, 1, 10) {
+		t.Fatal("key b limited by key a's traffic")
+	}
+	if !k.Allow("a", Limits{}, 1, 10) {
+		t.Fatal("disabled limits should allow all")
+	}
+
+	k.Sweep()
+	if k.Len() != 2 {
+		t.Fatalf("swept keys with drained buckets: len = %d", k.Len())
+	}
+	k.m["a"].last = time.Now().Add(-time.Minute)
+	k.m["b"].last = time.Now().Add(-time.Minute)
+	k.Sweep()
+	if k.Len() != 0 {
+		t.Fatalf("refilled keys not swept: len = %d", k.Len())
+	}
+}
@@ -0,0 +1,331 @@
+package security
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/APRSCN/aprsgo/internal/pkg/acl"
+	"go.gh.ink/json"
+)
+
+// BanKind selects what a ban matches.
+type BanKind string
+
+const (
+	// BanLogin rejects logins whose callsign matches a glob.
+	BanLogin BanKind = "login"
+	// BanSource drops packets whose source callsign matches a glob.
+	BanSource BanKind = "source"
+	// BanIP rejects connections and submissions from an IP or CIDR.
+	BanIP BanKind = "ip"
+)
+
+// ErrInvalidBan is wrapped by the errors of bans rejected by validation: an
+// empty or malformed pattern, or an unknown kind.
+var ErrInvalidBan = errors.New("invalid ban")
+
+// Ban is a single runtime ban. A zero Expires means the ban is permanent.
+type Ban struct {
+	ID      uint64    `json:"id"`
+	Kind    BanKind   `json:"kind"`
+	Pattern string    `json:"pattern"`
+	Reason  string    `json:"reason"`
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+// active reports whether the ban is in force at now.
+func (b Ban) active(now time.Time) bool {
+	return b.Expires.IsZero() || now.Before(b.Expires)
+}
+
+// matchesCall reports whether the ban's glob matches a callsign.
+func (b Ban) matchesCall(call string) bool {
+	return globMatch(b.Pattern, call)
+}
+
+// normaliseBan validates a ban's pattern for its kind and brings it into
+// canonical form (upper-case globs, masked CIDR prefixes; a bare IP becomes a
+// single-address prefix).
+func normaliseBan(b *Ban) error {
+	b.Pattern = strings.TrimSpace(b.Pattern)
+	if b.Pattern == "" {
+		return fmt.Errorf("%w: empty pattern", ErrInvalidBan)
+	}
+	switch b.Kind {
+	case BanLogin, BanSource:
+		b.Pattern = strings.ToUpper(b.Pattern)
+		if _, err := path.Match(b.Pattern, ""); err != nil {
+			return fmt.Errorf("%w: callsign glob %q: %v", ErrInvalidBan, b.Pattern, err)
+		}
+	case BanIP:
+		p, err := netip.ParsePrefix(b.Pattern)
+		if err != nil {
+			ip, ierr := netip.ParseAddr(b.Pattern)
+			if ierr != nil {
+				return fmt.Errorf("%w: IP/CIDR %q", ErrInvalidBan, b.Pattern)
+			}
+			ip = ip.Unmap()
+			p = netip.PrefixFrom(ip, ip.BitLen())
+		}
+		b.Pattern = p.Masked().String()
+	default:
+		return fmt.Errorf("%w: kind %q", ErrInvalidBan, b.Kind)
+	}
+	return nil
+}
+
+// BanHook is called after a ban was added, e.g. to disconnect clients it
+// matches.
+type BanHook func(Ban)
+
+// BanList is the runtime-mutable ban store consulted by LoginAllowed,
+// SourceAllowed and AddrAllowed, saved to its file (if any) on every change.
+// It is safe for concurrent use.
+type BanList struct {
+	mu     sync.RWMutex
+	bans   []Ban
+	nextID uint64
+	// ipACL is the IP bans compiled as deny rules ahead of a catch-all allow
+	// (nil = no IP bans).
+	ipACL *acl.List
+	path  string
+	hooks []BanHook
+}
+
+// NewBanList creates an empty, in-memory ban list.
+func NewBanList() *BanList {
+	return &BanList{nextID: 1}
+}
+
+// Bans is the process-wide ban list.
+var Bans = NewBanList()
+
+// OpenBans replaces the process-wide ban list with one persisted at path
+// (empty = in memory only), loading any bans saved there.
+func OpenBans(path string) error {
+	b := NewBanList()
+	Bans = b
+	return b.Open(path)
+}
+
+// Open attaches the ban file at path and loads the bans still in force.
+func (l *BanList) Open(path string) error {
+	if path == "" {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.path = path
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var bans []Ban
+	if err = json.Unmarshal(data, &bans); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+	now := time.Now()
+	for _, b := range bans {
+		if normaliseBan(&b) != nil || !b.active(now) {
+			continue
+		}
+		l.bans = append(l.bans, b)
+		if b.ID >= l.nextID {
+			l.nextID = b.ID + 1
+		}
+	}
+	l.rebuildLocked()
+	return nil
+}
+
+// RegisterHook adds a function called (outside the lock) after each Add.
+func (l *BanList) RegisterHook(h BanHook) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, h)
+}
+
+// Add validates and stores a ban, replacing an existing ban of the same kind
+// and pattern, and returns it with its id and creation time filled in. A
+// non-positive ttl makes it permanent. A ban failing validation is rejected
+// with an error wrapping ErrInvalidBan; any other error means the ban is in
+// force but could not be saved.
+func (l *BanList) Add(kind BanKind, pattern, reason string, ttl time.Duration) (Ban, error) {
+	b := Ban{Kind: kind, Pattern: pattern, Reason: strings.TrimSpace(reason), Created: time.Now()}
+	if err := normaliseBan(&b); err != nil {
+		return Ban{}, err
+	}
+	if ttl > 0 {
+		b.Expires = b.Created.Add(ttl)
+	}
+
+	l.mu.Lock()
+	kept := l.bans[:0]
+	for _, old := range l.bans {
+		if old.Kind != b.Kind || old.Pattern != b.Pattern {
+			kept = append(kept, old)
+		}
+	}
+	b.ID = l.nextID
+	l.nextID++
+	l.bans = append(kept, b)
+	l.rebuildLocked()
+	err := l.saveLocked()
+	hooks := append([]BanHook(nil), l.hooks...)
+	l.mu.Unlock()
+
+	for _, h := range hooks {
+		h(b)
+	}
+	return b, err
+}
+
+// Remove deletes the ban with the given id. found is false when there is no
+// such ban.
+func (l *BanList) Remove(id uint64) (found bool, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, b := range l.bans {
+		if b.ID == id {
+			l.bans = append(l.bans[:i], l.bans[i+1:]...)
+			l.rebuildLocked()
+			return true, l.saveLocked()
+		}
+	}
+	return false, nil
+}
+
+// List returns the bans in force, ordered by id.
+func (l *BanList) List() []Ban {
+	now := time.Now()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	out := make([]Ban, 0, len(l.bans))
+	for _, b := range l.bans {
+		if b.active(now) {
+			out = append(out, b)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Cleanup drops expired bans, saving the list if anything changed.
+func (l *BanList) Cleanup() error {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	kept := l.bans[:0]
+	for _, b := range l.bans {
+		if b.active(now) {
+			kept = append(kept, b)
+		}
+	}
+	if len(kept) == len(l.bans) {
+		return nil
+	}
+	l.bans = kept
+	l.rebuildLocked()
+	return l.saveLocked()
+}
+
+// callBanned returns the active ban of the given kind matching call, if any.
+func (l *BanList) callBanned(kind BanKind, call string) (Ban, bool) {
+	now := time.Now()
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, b := range l.bans {
+		if b.Kind == kind && b.active(now) && b.matchesCall(call) {
+			return b, true
+		}
+	}
+	return Ban{}, false
+}
+
+// LoginBanned returns the active login ban matching callsign, if any.
+func (l *BanList) LoginBanned(callsign string) (Ban, bool) {
+	return l.callBanned(BanLogin, callsign)
+}
+
+// SourceBanned returns the active source ban matching srccall, if any.
+func (l *BanList) SourceBanned(srccall string) (Ban, bool) {
+	return l.callBanned(BanSource, srccall)
+}
+
+// AddrBanned reports whether addr (an IP, "ip:port" or "[ip]:port") is
+// covered by an IP ban in force.
+func (l *BanList) AddrBanned(addr string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	// Fast path: the precompiled list (all IP bans) lets the address through.
+	if l.ipACL.Allow(addr) {
+		return false
+	}
+	// Denied: confirm against the bans still in force, so a lapsed ban stops
+	// applying before Cleanup removes it.
+	return !compileIPBans(l.bans, time.Now()).Allow(addr)
+}
+
+// rebuildLocked recompiles the IP ban ACL. The caller must hold l.mu.
+func (l *BanList) rebuildLocked() {
+	l.ipACL = compileIPBans(l.bans, time.Time{})
+}
+
+// compileIPBans builds an acl.List denying every IP ban in force at now (zero
+// now = all of them) and allowing everything else. It returns nil when no ban
+// applies.
+func compileIPBans(bans []Ban, now time.Time) *acl.List {
+	var rules []string
+	for _, b := range bans {
+		if b.Kind == BanIP && (now.IsZero() || b.active(now)) {
+			rules = append(rules, "deny "+b.Pattern)
+		}
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	rules = append(rules, "allow 0.0.0.0/0", "allow ::/0")
+	list, err := acl.Compile(rules)
+	if err != nil {
+		// Patterns are validated on Add/Open, so this cannot happen.
+		return nil
+	}
+	return list
+}
+
+// saveLocked writes the bans to the ban file, if any. The caller must hold
+// l.mu.
+func (l *BanList) saveLocked() error {
+	if l.path == "" {
+		return nil
+	}
+	bans := l.bans
+	if bans == nil {
+		bans = []Ban{}
+	}
+	data, err := json.MarshalIndent(bans, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return err
+	}
+	tmp := l.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
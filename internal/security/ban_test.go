@@ -0,0 +1,129 @@
+package security
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBanListMatching(t *testing.T) {
+	l := NewBanList()
+	Bans = l
+	defer func() { Bans = NewBanList() }()
+	if _, err := l.Add(BanLogin, "bad-*", "abuse", 0); err != nil {
+		t.Fatalf("add login ban: %v", err)
+	}
+	if _, err := l.Add(BanSource, "SPAM", "", 0); err != nil {
+		t.Fatalf("add source ban: %v", err)
+	}
+	if _, err := l.Add(BanIP, "192.0.2.0/24", "", 0); err != nil {
+		t.Fatalf("add ip ban: %v", err)
+	}
+	if _, err := l.Add(BanIP, "2001:db8::1", "", 0); err != nil {
+		t.Fatalf("add single-address ban: %v", err)
+	}
+
+	if _, ok := l.LoginBanned("BAD-7"); !ok {
+		t.Error("BAD-7 login should be banned")
+	}
+	if _, ok := l.LoginBanned("GOOD"); ok {
+		t.Error("GOOD login should not be banned")
+	}
+	if _, ok := l.SourceBanned("spam"); !ok {
+		t.Error("SPAM source should be banned (case-insensitive)")
+	}
+	cases := map[string]bool{
+		"192.0.2.7:14580":      true,
+		"[::ffff:192.0.2.9]:1": true, // IPv4-mapped
+		"198.51.100.1:14580":   false,
+		"[2001:db8::1]:14580":  true,
+		"2001:db8::2":          false,
+	}
+	for addr, want := range cases {
+		if got := l.AddrBanned(addr); got != want {
+			t.Errorf("AddrBanned(%s) = %v, want %v", addr, got, want)
+		}
+		if got := AddrAllowed(addr); got == want {
+			t.Errorf("AddrAllowed(%s) = %v, want %v", addr, got, !want)
+		}
+	}
+
+	for _, bad := range []struct {
+		kind    BanKind
+		pattern string
+	}{{BanIP, "not-an-ip"}, {BanLogin, "[x"}, {BanKind("nope"), "X"}, {BanLogin, " "}} {
+		if _, err := l.Add(bad.kind, bad.pattern, "", 0); !errors.Is(err, ErrInvalidBan) {
+			t.Errorf("Add(%s, %q) = %v, want ErrInvalidBan", bad.kind, bad.pattern, err)
+		}
+	}
+}
+
+func TestBanListExpiryAndRemove(t *testing.T) {
+	l := NewBanList()
+	b, _ := l.Add(BanIP, "203.0.113.5", "", time.Hour)
+	if !l.AddrBanned("203.0.113.5") {
+		t.Fatal("address should be banned")
+	}
+
+	// Let the ban lapse: it stops applying before Cleanup runs.
+	l.mu.Lock()
+	l.bans[0].Expires = time.Now().Add(-time.Second)
+	l.mu.Unlock()
+	if l.AddrBanned("203.0.113.5") || len(l.List()) != 0 {
+		t.Fatal("expired ban still applies")
+	}
+	if err := l.Cleanup(); err != nil {
+		t.Fatalf("cleanup: %v", err)
+	}
+	if found, _ := l.Remove(b.ID); found {
+		t.Fatal("expired ban survived cleanup")
+	}
+
+	b, _ = l.Add(BanLogin, "X1", "", 0)
+	if found, _ := l.Remove(b.ID); !found {
+		t.Fatal("remove: ban not found")
+	}
+	if _, ok := l.LoginBanned("X1"); ok {
+		t.Fatal("removed ban still applies")
+	}
+}
+
+func TestBanListPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "bans.json")
+
+	l := NewBanList()
+	if err := l.Open(path); err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	first, _ := l.Add(BanLogin, "A1", "one", 0)
+	if _, err := l.Add(BanSource, "B1", "two", time.Hour); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	// Re-adding the same pattern replaces the earlier ban.
+	if _, err := l.Add(BanLogin, "a1", "updated", 0); err != nil {
+		t.Fatalf("re-add: %v", err)
+	}
+
+	r := NewBanList()
+	if err := r.Open(path); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	bans := r.List()
+	if len(bans) != 2 {
+		t.Fatalf("reloaded %d bans, want 2: %+v", len(bans), bans)
+	}
+	if got, ok := r.LoginBanned("A1"); !ok || got.Reason != "updated" || got.ID == first.ID {
+		t.Fatalf("reloaded login ban = %+v", got)
+	}
+	if bans[0].Expires.IsZero() == bans[1].Expires.IsZero() {
+		t.Fatal("expiry not persisted")
+	}
+	// New ids continue after the persisted ones.
+	next, _ := r.Add(BanIP, "10.0.0.0/8", "", 0)
+	for _, b := range bans {
+		if next.ID <= b.ID {
+			t.Fatalf("new id %d does not follow persisted id %d", next.ID, b.ID)
+		}
+	}
+}
@@ -1,6 +1,6 @@
 // Package security implements the configurable safety policies: callsign
-// blacklists (login and source), the unverified-client relay restriction, and
-// helpers shared by the inbound paths.
+// blacklists (login and source), the runtime ban list, the unverified-client
+// relay restriction, and helpers shared by the inbound paths.
 package security
 
 import (
@@ -22,8 +22,11 @@ func globMatch(pattern, name string) bool {
 }
 
 // LoginAllowed reports whether a login callsign is permitted (not matched by
-// any disallow_login_call glob).
+// any disallow_login_call glob nor by a runtime login ban).
 func LoginAllowed(callsign string) bool {
+	if _, banned := Bans.LoginBanned(callsign); banned {
+		return false
+	}
 	for _, pat := range config.Get().Server.DisallowLoginCall {
 		if globMatch(pat, callsign) {
 			return false
@@ -34,7 +37,7 @@ func LoginAllowed(callsign string) bool {
 
 // SourceAllowed reports whether a packet source callsign is permitted. The
 // built-in bogus list always applies; the configured disallow_source_call
-// globs are checked in addition.
+// globs and the runtime source bans are checked in addition.
 func SourceAllowed(srccall string) bool {
 	up := strings.ToUpper(strings.TrimSpace(srccall))
 	if up == "" {
@@ -55,9 +58,18 @@ func SourceAllowed(srccall string) bool {
 			return false
 		}
 	}
+	if _, banned := Bans.SourceBanned(up); banned {
+		return false
+	}
 	return true
 }
 
+// AddrAllowed reports whether a remote address (an IP, "ip:port" or
+// "[ip]:port") is permitted, i.e. not covered by a runtime IP ban.
+func AddrAllowed(addr string) bool {
+	return !Bans.AddrBanned(addr)
+}
+
 // DisallowUnverified reports whether unverified clients are barred from
 // relaying packets.
 func DisallowUnverified() bool {
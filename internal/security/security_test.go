@@ -19,12 +19,16 @@ func setSecurityConfig(login, source []string, unverified, disallowOtherQ bool,
 func TestLoginAllowed(t *testing.T) {
 	setSecurityConfig([]string{"N0CALL", "TEST-*"}, nil, false, false, "")
 	defer config2.Set(config2.StaticConfig{})
+	Bans = NewBanList()
+	defer func() { Bans = NewBanList() }()
+	_, _ = Bans.Add(BanLogin, "BANNED", "", 0)
 
 	cases := map[string]bool{
 		"N0CALL":    false,
 		"n0call":    false, // case-insensitive
 		"TEST-1":    false, // glob
 		"TEST-99":   false,
+		"BANNED":    false, // runtime ban
 		"VALIDCALL": true,
 	}
 	for call, want := range cases {
@@ -37,12 +41,16 @@ func TestLoginAllowed(t *testing.T) {
 func TestSourceAllowed(t *testing.T) {
 	setSecurityConfig(nil, []string{"BAD-*"}, false, false, "")
 	defer config2.Set(config2.StaticConfig{})
+	Bans = NewBanList()
+	defer func() { Bans = NewBanList() }()
+	_, _ = Bans.Add(BanSource, "BANNED", "", 0)
 
 	cases := map[string]bool{
 		"N0CALL":   false, // built-in
 		"NOCALL-1": false, // built-in root match (SSID stripped)
 		"SERVER":   false, // built-in
 		"BAD-7":    false, // configured glob
+		"BANNED":   false, // runtime ban
 		"GOODCALL": true,
 		"":         false, // empty
 	}
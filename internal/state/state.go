@@ -0,0 +1,112 @@
+// Package state saves the server's in-memory state — station positions, the
+// statistics time series, listener peak client counts and the duplicate
+// windows — to a file, and restores it on start, so a restart or upgrade does
+// not wipe the graphs or leave range filters without positions until stations
+// beacon again.
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/APRSCN/aprsgo/internal/network/listener"
+	"github.com/APRSCN/aprsgo/internal/network/peer"
+	"github.com/APRSCN/aprsgo/internal/network/uplink"
+	"github.com/APRSCN/aprsgo/internal/pkg/historydb"
+	"github.com/APRSCN/aprsgo/internal/system"
+	"go.gh.ink/json"
+)
+
+// snapshot is the content of the state file.
+type snapshot struct {
+	Saved       time.Time                       `json:"saved"`
+	Positions   []historydb.Position            `json:"positions"`
+	Series      map[string][][2]float64         `json:"series"`
+	PeakClients map[string]int                  `json:"peak_clients"`
+	Dupes       map[string]map[uint64]time.Time `json:"dupes"` // hashes of the duplicate filter
+}
+
+// series returns the statistics time series by name. Series whose daemon is
+// not running are nil.
+func series() map[string]*historydb.MapFloat64History {
+	return map[string]*historydb.MapFloat64History{
+		"memory":           system.StatsMemory,
+		"uplink_packet_rx": uplink.StatsPacketRX,
+		"uplink_packet_tx": uplink.StatsPacketTX,
+		"uplink_bytes_rx":  uplink.StatsBytesRX,
+		"uplink_bytes_tx":  uplink.StatsBytesTX,
+		"peer_packet_rx":   peer.StatsPacketRX,
+		"peer_packet_tx":   peer.StatsPacketTX,
+		"peer_bytes_rx":    peer.StatsBytesRX,
+		"peer_bytes_tx":    peer.StatsBytesTX,
+	}
+}
+
+// Save writes the current state to path (empty does nothing), replacing the
+// file atomically.
+func Save(path string) error {
+	if path == "" {
+		return nil
+	}
+	snap := snapshot{
+		Saved:       time.Now(),
+		Positions:   historydb.Positions.Snapshot(),
+		Series:      make(map[string][][2]float64),
+		PeakClients: listener.PeakClients(),
+	}
+	for name, s := range series() {
+		if s != nil {
+			snap.Series[name] = s.Points()
+		}
+	}
+	if uplink.Stream != nil {
+		snap.Dupes = uplink.Stream.Dedup().Snapshot()
+	}
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Restore loads the state saved at path into the running stores. A missing
+// file (or empty path) is not an error. It must be called after the daemons
+// owning the stores have been initialised.
+func Restore(path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var snap snapshot
+	if err = json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	historydb.Positions.Restore(snap.Positions)
+	for name, s := range series() {
+		if s != nil {
+			s.Restore(snap.Series[name])
+		}
+	}
+	listener.RestorePeakClients(snap.PeakClients)
+	if uplink.Stream != nil {
+		uplink.Stream.Dedup().Restore(snap.Dupes)
+	}
+	return nil
+}
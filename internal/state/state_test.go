@@ -0,0 +1,73 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/APRSCN/aprsgo/internal/network/uplink"
+	"github.com/APRSCN/aprsgo/internal/pkg/historydb"
+	"github.com/APRSCN/aprsgo/internal/system"
+	"github.com/APRSCN/aprsutils/parser"
+	"go.gh.ink/json"
+)
+
+func TestSaveRestore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "state.json")
+	system.StatsMemory = historydb.NewMapFloat64History()
+	uplink.StatsPacketRX = historydb.NewMapFloat64History()
+
+	now := float64(time.Now().Unix())
+	system.StatsMemory.Record(now, 42)
+	uplink.StatsPacketRX.Record(now, 7)
+	historydb.Positions.Update("STATE1", 60.17, 24.94)
+	uplink.Stream = uplink.NewDataStream(10)
+	dupe, _ := parser.Parse("STATE1>APRS,TCPIP*:>saved")
+	uplink.Stream.Ingest(dupe, "STATE1")
+	if err := Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snap snapshot
+	if err = json.Unmarshal(data, &snap); err != nil {
+		t.Fatalf("state file: %v", err)
+	}
+	if len(snap.Dupes) == 0 {
+		t.Error("dupe window not saved")
+	}
+
+	// Start over as after a restart.
+	system.StatsMemory = historydb.NewMapFloat64History()
+	uplink.StatsPacketRX = historydb.NewMapFloat64History()
+	historydb.Positions = historydb.NewPositionHistory()
+	uplink.Stream = uplink.NewDataStream(10)
+	if err := Restore(path); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	if v := system.StatsMemory.D[now]; v != 42 {
+		t.Errorf("memory series value = %v, want 42", v)
+	}
+	if v := uplink.StatsPacketRX.D[now]; v != 7 {
+		t.Errorf("packet rx series value = %v, want 7", v)
+	}
+	if lat, lon, ok := historydb.Positions.Get("STATE1"); !ok || lat != 60.17 || lon != 24.94 {
+		t.Errorf("position = %v,%v,%v after restore", lat, lon, ok)
+	}
+	if uplink.Stream.Ingest(dupe, "uplink") {
+		t.Error("packet seen before the restart not recognised as a dupe")
+	}
+}
+
+func TestRestoreMissing(t *testing.T) {
+	if err := Restore(filepath.Join(t.TempDir(), "none.json")); err != nil {
+		t.Errorf("Restore of a missing file: %v", err)
+	}
+	if err := Save(""); err != nil {
+		t.Errorf("Save with no path: %v", err)
+	}
+}
@@ -9,18 +9,90 @@
 // the socket for later handoff; after an upgrade they transparently adopt the
 // inherited socket matching their address.
 //
+// Established client connections can be handed over as well: listeners
+// register a session source that freezes their clients and returns each
+// connection with its serialized state, and the child resumes them through
+// InheritedSessions, so connected stations do not have to reconnect. If the
+// child cannot be started, the sessions are given back to their source to be
+// resumed in this process.
+//
 // Socket inheritance relies on passing file descriptors to a child process,
 // which is only available on Unix-like systems. On other platforms an upgrade
 // request returns an error and ListenTCP / ListenUDP simply bind fresh.
 package upgrade
 
-import "net"
+import (
+	"net"
+	"os"
+	"sync"
+)
 
 // envFDList is the environment variable carrying the inherited-socket map from
 // parent to child: a comma-separated list of "key" entries, in the same order
 // as the extra file descriptors (which start at fd 3 in the child).
 const envFDList = "APRSGO_UPGRADE_FDS"
 
+// envSessions is the environment variable carrying the child fd of the session
+// state file, which lists each handed-over connection with its fd and state.
+const envSessions = "APRSGO_UPGRADE_SESSIONS"
+
+// Session is an established connection handed to the child during an upgrade.
+type Session struct {
+	// Addr is the listen address (as passed to ListenTCP) the connection was
+	// accepted on; the child's listener for that address resumes it.
+	Addr string
+	// File is a duplicate of the connection's socket.
+	File *os.File
+	// State is the listener's serialized session state.
+	State []byte
+}
+
+// SessionSource collects the connections to hand over. It is called by
+// Perform just before the child is started; the returned connections must no
+// longer be served by this process.
+type SessionSource func() []Session
+
+// SessionResume serves again, in this process, the connections its source
+// handed over for a child that could not be started. It owns their files.
+type SessionResume func([]Session)
+
+// sessionSource is a registered source with its resume function.
+type sessionSource struct {
+	collect SessionSource
+	resume  SessionResume
+}
+
+var (
+	sourcesMu sync.Mutex
+	sources   []sessionSource
+)
+
+// RegisterSessionSource adds a source of connections to hand over on Perform,
+// and the function that takes them back if the upgrade fails.
+func RegisterSessionSource(src SessionSource, resume SessionResume) {
+	sourcesMu.Lock()
+	defer sourcesMu.Unlock()
+	sources = append(sources, sessionSource{collect: src, resume: resume})
+}
+
+// collected is the sessions one source handed over.
+type collected struct {
+	resume   SessionResume
+	sessions []Session
+}
+
+// collectSessions calls every registered session source.
+func collectSessions() []collected {
+	sourcesMu.Lock()
+	srcs := append([]sessionSource(nil), sources...)
+	sourcesMu.Unlock()
+	out := make([]collected, 0, len(srcs))
+	for _, src := range srcs {
+		out = append(out, collected{resume: src.resume, sessions: src.collect()})
+	}
+	return out
+}
+
 func tcpKey(addr string) string { return "tcp:" + addr }
 func udpKey(addr string) string { return "udp:" + addr }
 
@@ -40,8 +112,21 @@ func ListenUDP(addr string) (*net.UDPConn, error) {
 // Supported reports whether socket handoff is available on this platform.
 func Supported() bool { return supported() }
 
+// InheritedSessions returns the connections the parent handed over for the TCP
+// listen address addr. Each session is returned once; the caller owns the
+// files.
+func InheritedSessions(addr string) []Session { return inheritedSessions(addr) }
+
+// CloseUnclaimedSessions closes the connections the parent handed over that
+// no InheritedSessions call has claimed, e.g. those of a listener the new
+// config no longer has, and returns how many it closed. Call it once every
+// listener has started.
+func CloseUnclaimedSessions() int { return closeUnclaimedSessions() }
+
 // Perform spawns a child process that inherits the registered listening
-// sockets and starts serving on them. It returns the started child's PID. The
-// caller should then gracefully drain and exit the current process. On
+// sockets and the connections of the registered session sources, and starts
+// serving on them. It returns the started child's PID. The caller should then
+// gracefully drain and exit the current process. If the child cannot be
+// started, the collected sessions are resumed here and the error returned. On
 // platforms without FD passing it returns an error.
 func Perform() (pid int, err error) { return perform() }
@@ -24,6 +24,10 @@ func listenUDP(addr string) (*net.UDPConn, error) {
 	return net.ListenUDP("udp", udpAddr)
 }
 
+func inheritedSessions(string) []Session { return nil }
+
+func closeUnclaimedSessions() int { return 0 }
+
 func perform() (int, error) {
 	return 0, errors.New("live upgrade is not supported on this platform")
 }
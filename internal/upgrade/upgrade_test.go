@@ -3,10 +3,15 @@
 package upgrade
 
 import (
+	"errors"
+	"io"
 	"net"
 	"os"
+	"strconv"
 	"sync"
+	"syscall"
 	"testing"
+	"time"
 )
 
 // resetState clears the package registries between tests.
@@ -18,7 +23,10 @@ func resetState(t *testing.T) {
 	mu.Unlock()
 	inheritOnce = sync.Once{}
 	inheritedMap = nil
+	sessOnce = sync.Once{}
+	inheritedSess = nil
 	_ = os.Unsetenv(envFDList)
+	_ = os.Unsetenv(envSessions)
 }
 
 func TestListenTCPFreshBindAndRegister(t *testing.T) {
@@ -101,3 +109,166 @@ func TestListenTCPAdoptsInherited(t *testing.T) {
 		t.Errorf("adopted listener addr = %q, want %q", got.Addr().String(), addr)
 	}
 }
+
+// Sessions written to the state file must be returned, with their state and a
+// usable socket, by InheritedSessions for the matching listen address only.
+func TestInheritedSessionsFromStateFile(t *testing.T) {
+	resetState(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	f, err := server.(*net.TCPConn).File()
+	_ = server.Close()
+	if err != nil {
+		t.Fatalf("conn File: %v", err)
+	}
+
+	// parseSessions takes ownership of the descriptors it is given, so hand
+	// it duplicates that no *os.File here owns.
+	connFD := dupFD(t, f)
+
+	const addr = "[::]:14580"
+	sf, err := writeSessionFile([]sessionEntry{{Key: tcpKey(addr), FD: connFD, State: []byte("state")}})
+	if err != nil {
+		t.Fatalf("writeSessionFile: %v", err)
+	}
+	t.Setenv(envSessions, strconv.Itoa(dupFD(t, sf)))
+
+	if got := InheritedSessions("[::]:10152"); len(got) != 0 {
+		t.Fatalf("sessions for another address: %v", got)
+	}
+	got := InheritedSessions(addr)
+	if len(got) != 1 || string(got[0].State) != "state" || got[0].Addr != addr {
+		t.Fatalf("inherited sessions = %+v", got)
+	}
+	conn, err := net.FileConn(got[0].File)
+	_ = got[0].File.Close()
+	if err != nil {
+		t.Fatalf("FileConn: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("x")); err != nil {
+		t.Fatalf("write on inherited session: %v", err)
+	}
+	buf := make([]byte, 1)
+	if _, err := client.Read(buf); err != nil || buf[0] != 'x' {
+		t.Fatalf("client read %q, %v", buf, err)
+	}
+	if len(InheritedSessions(addr)) != 0 {
+		t.Fatal("sessions returned twice")
+	}
+}
+
+// Sessions handed over for an address no listener claims are closed, so their
+// stations reconnect instead of hanging on a socket nobody reads.
+func TestCloseUnclaimedSessions(t *testing.T) {
+	resetState(t)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer l.Close()
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	f, err := server.(*net.TCPConn).File()
+	_ = server.Close()
+	if err != nil {
+		t.Fatalf("conn File: %v", err)
+	}
+
+	sf, err := writeSessionFile([]sessionEntry{{Key: tcpKey("[::]:14580"), FD: dupFD(t, f), State: []byte("state")}})
+	if err != nil {
+		t.Fatalf("writeSessionFile: %v", err)
+	}
+	t.Setenv(envSessions, strconv.Itoa(dupFD(t, sf)))
+
+	if got := InheritedSessions("[::]:10152"); len(got) != 0 {
+		t.Fatalf("sessions for another address: %v", got)
+	}
+	if n := CloseUnclaimedSessions(); n != 1 {
+		t.Fatalf("closed %d unclaimed sessions, want 1", n)
+	}
+	_ = client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err := client.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+		t.Fatalf("client read after close: %v, want EOF", err)
+	}
+	if n := CloseUnclaimedSessions(); n != 0 {
+		t.Fatalf("closed %d sessions twice", n)
+	}
+}
+
+// dupFD duplicates f's descriptor and closes f.
+func dupFD(t *testing.T, f *os.File) int {
+	t.Helper()
+	fd, err := syscall.Dup(int(f.Fd()))
+	_ = f.Close()
+	if err != nil {
+		t.Fatalf("dup: %v", err)
+	}
+	return fd
+}
+
+// A child that fails to start gives the collected sessions back to their
+// source, files still open, instead of dropping them.
+func TestPerformResumesSessionsOnFailure(t *testing.T) {
+	resetState(t)
+	l, err := ListenTCP("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenTCP: %v", err)
+	}
+	defer l.Close()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	sourcesMu.Lock()
+	saved := sources
+	sourcesMu.Unlock()
+	var resumed []Session
+	RegisterSessionSource(
+		func() []Session { return []Session{{Addr: "127.0.0.1:0", File: w, State: []byte("state")}} },
+		func(s []Session) { resumed = s },
+	)
+	startProcess = func(string, []string, *os.ProcAttr) (*os.Process, error) {
+		return nil, errors.New("exec failed")
+	}
+	defer func() {
+		startProcess = os.StartProcess
+		sourcesMu.Lock()
+		sources = saved
+		sourcesMu.Unlock()
+	}()
+
+	if _, err := Perform(); err == nil {
+		t.Fatal("Perform succeeded without a child")
+	}
+	if len(resumed) != 1 || string(resumed[0].State) != "state" {
+		t.Fatalf("resumed = %+v, want the collected session", resumed)
+	}
+	if _, err := resumed[0].File.Write([]byte("x")); err != nil {
+		t.Fatalf("resumed session file closed: %v", err)
+	}
+	_ = resumed[0].File.Close()
+}
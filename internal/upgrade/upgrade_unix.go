@@ -5,12 +5,19 @@ package upgrade
 import (
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
+
+	"go.gh.ink/json"
 )
 
+// startProcess starts the child; tests replace it.
+var startProcess = os.StartProcess
+
 // registered tracks the live listeners eligible for handoff, keyed by a stable
 // address key ("tcp:host:port" / "udp:host:port"). Guarded by mu.
 var (
@@ -55,6 +62,104 @@ func parseInherited() map[string]*os.File {
 	return inheritedMap
 }
 
+// sessionEntry is one handed-over connection in the session state file.
+type sessionEntry struct {
+	Key   string `json:"key"`
+	FD    int    `json:"fd"`
+	State []byte `json:"state"`
+}
+
+// inheritedSess maps a listen address key to the sessions handed over for it.
+// Populated once at startup from the session state file; entries are removed
+// as they are claimed.
+var (
+	sessOnce      sync.Once
+	sessMu        sync.Mutex
+	inheritedSess map[string][]Session
+)
+
+// parseSessions reads the session state file the parent passed (its fd is in
+// the environment) and reconstructs the handed-over connections.
+func parseSessions() {
+	sessOnce.Do(func() {
+		inheritedSess = make(map[string][]Session)
+		fd, err := strconv.Atoi(os.Getenv(envSessions))
+		if err != nil || fd < 3 {
+			return
+		}
+		f := os.NewFile(uintptr(fd), "sessions")
+		if f == nil {
+			return
+		}
+		data, err := io.ReadAll(f)
+		_ = f.Close()
+		if err != nil {
+			return
+		}
+		var entries []sessionEntry
+		if json.Unmarshal(data, &entries) != nil {
+			return
+		}
+		for _, e := range entries {
+			cf := os.NewFile(uintptr(e.FD), e.Key)
+			if cf == nil {
+				continue
+			}
+			inheritedSess[e.Key] = append(inheritedSess[e.Key], Session{File: cf, State: e.State})
+		}
+	})
+}
+
+func inheritedSessions(addr string) []Session {
+	parseSessions()
+	key := tcpKey(addr)
+	sessMu.Lock()
+	defer sessMu.Unlock()
+	out := inheritedSess[key]
+	delete(inheritedSess, key)
+	for i := range out {
+		out[i].Addr = addr
+	}
+	return out
+}
+
+func closeUnclaimedSessions() int {
+	parseSessions()
+	sessMu.Lock()
+	defer sessMu.Unlock()
+	n := 0
+	for key, sessions := range inheritedSess {
+		for _, s := range sessions {
+			_ = s.File.Close()
+			n++
+		}
+		delete(inheritedSess, key)
+	}
+	return n
+}
+
+// writeSessionFile stores the session entries in an unlinked temporary file
+// positioned at its start, ready to be passed to the child.
+func writeSessionFile(entries []sessionEntry) (*os.File, error) {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp("", "aprsgo-sessions-*")
+	if err != nil {
+		return nil, err
+	}
+	_ = os.Remove(f.Name())
+	if _, err = f.Write(data); err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
 func supported() bool { return true }
 
 // adoptListener returns a net.Listener built from an inherited socket for key,
@@ -155,6 +260,8 @@ func listenUDP(addr string) (*net.UDPConn, error) {
 // perform spawns a child copy of this binary, passing the registered listening
 // sockets as extra file descriptors and the address-key list via the
 // environment. The child adopts the sockets and starts serving immediately.
+// Handed-over connections follow the listening sockets, preceded by the
+// session state file describing them.
 func perform() (int, error) {
 	mu.Lock()
 	keys := append([]string(nil), order...)
@@ -174,15 +281,55 @@ func perform() (int, error) {
 	}
 
 	// Build the child environment: inherit ours, then set/replace the FD map.
-	env := make([]string, 0, len(os.Environ())+1)
+	env := make([]string, 0, len(os.Environ())+2)
 	for _, e := range os.Environ() {
-		if strings.HasPrefix(e, envFDList+"=") {
+		if strings.HasPrefix(e, envFDList+"=") || strings.HasPrefix(e, envSessions+"=") {
 			continue
 		}
 		env = append(env, e)
 	}
 	env = append(env, envFDList+"="+strings.Join(keys, ","))
 
+	// Hand over established connections: the state file takes the fd after
+	// the listening sockets and the connections follow it. Until the child
+	// has started, a failure gives them back to their sources.
+	handoffs := collectSessions()
+	var sessions []Session
+	for _, h := range handoffs {
+		sessions = append(sessions, h.sessions...)
+	}
+	started := false
+	defer func() {
+		if started {
+			for _, s := range sessions {
+				_ = s.File.Close()
+			}
+			return
+		}
+		for _, h := range handoffs {
+			if len(h.sessions) > 0 {
+				h.resume(h.sessions)
+			}
+		}
+	}()
+	if len(sessions) > 0 {
+		stateFD := 3 + len(files)
+		entries := make([]sessionEntry, len(sessions))
+		for i, s := range sessions {
+			entries[i] = sessionEntry{Key: tcpKey(s.Addr), FD: stateFD + 1 + i, State: s.State}
+		}
+		sf, err := writeSessionFile(entries)
+		if err != nil {
+			return 0, fmt.Errorf("write session state: %w", err)
+		}
+		defer sf.Close()
+		files = append(files, sf)
+		for _, s := range sessions {
+			files = append(files, s.File)
+		}
+		env = append(env, envSessions+"="+strconv.Itoa(stateFD))
+	}
+
 	attr := &os.ProcAttr{
 		Dir: "",
 		Env: env,
@@ -190,9 +337,10 @@ func perform() (int, error) {
 		Files: append([]*os.File{os.Stdin, os.Stdout, os.Stderr}, files...),
 	}
 
-	proc, err := os.StartProcess(exe, os.Args, attr)
+	proc, err := startProcess(exe, os.Args, attr)
 	if err != nil {
 		return 0, fmt.Errorf("start child: %w", err)
 	}
+	started = true
 	return proc.Pid, nil
 }
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
@@ -13,6 +16,9 @@ import (
 	"github.com/APRSCN/aprsgo/internal/network/listener"
 	"github.com/APRSCN/aprsgo/internal/network/peer"
 	"github.com/APRSCN/aprsgo/internal/network/uplink"
+	"github.com/APRSCN/aprsgo/internal/pkg/historydb"
+	"github.com/APRSCN/aprsgo/internal/security"
+	"github.com/APRSCN/aprsgo/internal/state"
 	"github.com/APRSCN/aprsgo/internal/system"
 	"github.com/APRSCN/aprsgo/internal/upgrade"
 	"github.com/gofiber/fiber/v3"
@@ -20,8 +26,24 @@ import (
 )
 
 func main() {
+	// Parse the command line before anything is started
+	cmd, err := parseCommand(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Print(usage)
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	config.SetPath(cmd.config)
+	if cmd.tool() {
+		os.Exit(runTool(cmd))
+	}
+
 	// Init embed
-	InitEmbed()
+	InitEmbed(cmd.config)
 
 	// Load public config
 	config.Init()
@@ -31,9 +53,34 @@ func main() {
 	logger.Init()
 	defer logger.Cleanup()
 
+	// Record the pid for the reload command
+	removePIDFile, err := writePIDFile(config.Get().Server.PIDFile)
+	if err != nil {
+		logger.L.Error("failed to write pid file", zap.Error(err))
+	} else {
+		defer removePIDFile()
+	}
+
 	// Init system daemon
 	system.Init()
 
+	// Open the packet history store before packets start flowing
+	hc := config.Get().Server.History
+	if err := historydb.OpenPackets(hc.File, hc.Depth, time.Duration(hc.MaxAge)*time.Hour); err != nil {
+		logger.L.Error("failed to open packet history, keeping it in memory", zap.Error(err))
+	}
+	defer func() { _ = historydb.Packets.Close() }()
+
+	// Load the runtime ban list before any connection is accepted
+	if err := security.OpenBans(config.Get().Server.BanFile); err != nil {
+		logger.L.Error("failed to load ban list", zap.Error(err))
+	}
+
+	// A replay runs without uplinks and core peers
+	if cmd.name == "replay" {
+		offline()
+	}
+
 	// Init uplink
 	uplink.Init()
 
@@ -43,8 +90,16 @@ func main() {
 	// Init core peers
 	peer.Init()
 
+	// Restore the state saved by the previous run
+	if err := state.Restore(config.Get().Server.StateFile); err != nil {
+		logger.L.Error("failed to restore saved state", zap.Error(err))
+	}
+
 	// Apply configuration changes on SIGHUP: rebuild listeners, restart the
 	// uplink manager and core peers so new settings take effect live.
+	if cmd.name == "replay" {
+		config.RegisterReloadHook(offline)
+	}
 	config.RegisterReloadHook(listener.Reload)
 	config.RegisterReloadHook(uplink.Reload)
 	config.RegisterReloadHook(peer.Reload)
@@ -55,6 +110,21 @@ func main() {
 	// Run main server (serving the embedded web bundle)
 	app := handler.Run(WebFS())
 
+	// Start capturing or replaying the stream
+	var replayDone <-chan struct{}
+	switch cmd.name {
+	case "record":
+		stopRecorder, err := startRecorder(cmd)
+		if err != nil {
+			logger.L.Fatal("failed to start stream capture", zap.Error(err))
+		}
+		defer stopRecorder()
+	case "replay":
+		stopReplay := make(chan struct{})
+		defer close(stopReplay)
+		replayDone = startReplay(cmd, stopReplay)
+	}
+
 	// Setup signal handling for graceful shutdown and live upgrade.
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
@@ -71,10 +141,15 @@ func main() {
 		select {
 		case sig := <-upgradeChan:
 			logger.L.Info("received upgrade signal", zap.Any("signal", sig))
+			if cmd.name == "replay" {
+				logger.L.Warn("live upgrade would restart the replay; ignoring")
+				continue
+			}
 			if !upgrade.Supported() {
 				logger.L.Warn("live upgrade not supported on this platform; ignoring")
 				continue
 			}
+			saveState()
 			pid, err := upgrade.Perform()
 			if err != nil {
 				logger.L.Error("live upgrade failed; continuing to run", zap.Error(err))
@@ -87,6 +162,14 @@ func main() {
 		case sig := <-sigChan:
 			logger.L.Info("received shutdown signal", zap.Any("signal", sig))
 			shutdown(app)
+			saveState()
+			return
+		case <-replayDone:
+			if !cmd.exit {
+				replayDone = nil
+				continue
+			}
+			shutdown(app)
 			return
 		}
 	}
@@ -106,3 +189,10 @@ func shutdown(app *fiber.App) {
 		logger.L.Error("error during graceful shutdown", zap.Error(err))
 	}
 }
+
+// saveState saves the in-memory state for the next run.
+func saveState() {
+	if err := state.Save(config.Get().Server.StateFile); err != nil {
+		logger.L.Error("failed to save state", zap.Error(err))
+	}
+}
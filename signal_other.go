@@ -7,3 +7,6 @@ import "os"
 // upgradeSignal returns nil on platforms without a live-upgrade signal, so no
 // upgrade handler is registered.
 func upgradeSignal() os.Signal { return nil }
+
+// reloadSignal returns nil on platforms without a reload signal.
+func reloadSignal() os.Signal { return nil }
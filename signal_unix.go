@@ -10,3 +10,7 @@ import (
 // upgradeSignal returns the OS signal that triggers a live upgrade (SIGUSR2 on
 // Unix-like systems).
 func upgradeSignal() os.Signal { return syscall.SIGUSR2 }
+
+// reloadSignal returns the OS signal that makes a running server reload its
+// configuration (SIGHUP on Unix-like systems).
+func reloadSignal() os.Signal { return syscall.SIGHUP }
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/APRSCN/aprsgo/internal/infra/config"
+	"github.com/APRSCN/aprsutils"
+	"go.gh.ink/json"
+)
+
+// statusTimeout bounds the status command's request.
+const statusTimeout = 10 * time.Second
+
+// runTool runs a one-shot command and returns the process exit code.
+func runTool(cmd command) int {
+	var err error
+	switch cmd.name {
+	case "check-config":
+		err = checkConfig(os.Stdout)
+	case "passcode":
+		fmt.Println(aprsutils.Passcode(cmd.call))
+	case "status":
+		err = printStatus(os.Stdout, cmd.url)
+	case "reload":
+		err = signalReload(cmd.pid)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cmd.name, err)
+		return 1
+	}
+	return 0
+}
+
+// checkConfig loads the config and reports every problem that would make the
+// server refuse it or that would otherwise only show up in the log once it is
+// applied. Warnings, such as peer hosts that do not resolve, are printed but
+// do not fail the check.
+func checkConfig(w io.Writer) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	errs := config.Problems(config.Check(cfg))
+	for _, e := range errs {
+		_, _ = fmt.Fprintln(w, e)
+	}
+	for _, e := range config.Warnings(cfg) {
+		_, _ = fmt.Fprintln(w, "warning:", e)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d problem(s) found", len(errs))
+	}
+	_, _ = fmt.Fprintln(w, "config OK")
+	return nil
+}
+
+// statusURL returns the /api/status URL of the instance the config describes,
+// falling back to the default status port.
+func statusURL() string {
+	host, port := "127.0.0.1", 14501
+	if cfg, err := config.Load(); err == nil {
+		if h := strings.Trim(cfg.Server.Status.Host, "[]"); h != "" && !net.ParseIP(h).IsUnspecified() {
+			host = h
+		}
+		if cfg.Server.Status.Port > 0 {
+			port = cfg.Server.Status.Port
+		}
+	}
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(port)) + "/api/status"
+}
+
+// printStatus queries a running instance's status API and prints the status
+// as indented JSON.
+func printStatus(w io.Writer, url string) error {
+	if url == "" {
+		url = statusURL()
+	}
+	client := &http.Client{Timeout: statusTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	var body struct {
+		Data any `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return err
+	}
+	out, err := json.MarshalIndent(body.Data, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(out))
+	return err
+}
+
+// signalReload asks a running instance to reload its configuration. Without
+// an explicit pid it is read from the configured pid file.
+func signalReload(pid int) error {
+	sig := reloadSignal()
+	if sig == nil {
+		return errors.New("not supported on this platform")
+	}
+	if pid <= 0 {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		if cfg.Server.PIDFile == "" {
+			return errors.New("no pid_file configured; pass -pid")
+		}
+		if pid, err = readPIDFile(cfg.Server.PIDFile); err != nil {
+			return err
+		}
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return proc.Signal(sig)
+}
+
+// readPIDFile returns the process id recorded in path.
+func readPIDFile(path string) (int, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("invalid pid file %s", path)
+	}
+	return pid, nil
+}
+
+// writePIDFile records this process's id in path and returns a function that
+// removes the file again, unless another process (e.g. the child of a live
+// upgrade) has taken it over meanwhile.
+func writePIDFile(path string) (func(), error) {
+	if path == "" {
+		return func() {}, nil
+	}
+	pid := os.Getpid()
+	if err := os.WriteFile(path, []byte(strconv.Itoa(pid)+"\n"), 0o644); err != nil {
+		return nil, err
+	}
+	return func() {
+		if cur, err := readPIDFile(path); err == nil && cur == pid {
+			_ = os.Remove(path)
+		}
+	}, nil
+}